@@ -0,0 +1,247 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/linxGnu/gosmpp/pdu"
+)
+
+// Type of number values (SMPP 5.2.5).
+const (
+	TONUnknown         byte = 0
+	TONInternational   byte = 1
+	TONNational        byte = 2
+	TONNetworkSpecific byte = 3
+	TONSubscriber      byte = 4
+	TONAlphanumeric    byte = 5
+	TONAbbreviated     byte = 6
+)
+
+// Numbering plan indicator values (SMPP 5.2.6).
+const (
+	NPIUnknown  byte = 0
+	NPIISDN     byte = 1 // E.163/E.164
+	NPIData     byte = 3
+	NPITelex    byte = 4
+	NPILand     byte = 6
+	NPINational byte = 8
+	NPIPrivate  byte = 9
+	NPIERMES    byte = 10
+	NPIInternet byte = 14
+	NPIWAP      byte = 18
+)
+
+// maxAddrLen is the longest source/destination address in submit_sm,
+// excluding the C-string terminator.
+const maxAddrLen = 20
+
+// SMEAddress is an address with its type of number and numbering plan.
+type SMEAddress struct {
+	TON  byte
+	NPI  byte
+	Addr string
+}
+
+func (a SMEAddress) String() string {
+	return fmt.Sprintf("%s (TON %d, NPI %d)", a.Addr, a.TON, a.NPI)
+}
+
+// pduAddress converts a to a gosmpp address.
+func (a SMEAddress) pduAddress() (pdu.Address, error) {
+	addr := pdu.NewAddress()
+	addr.SetTon(a.TON)
+	addr.SetNpi(a.NPI)
+	if err := addr.SetAddress(a.Addr); err != nil {
+		return addr, fmt.Errorf("address %q: %w", a.Addr, err)
+	}
+	return addr, nil
+}
+
+func isDigits(s string) bool {
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return s != ""
+}
+
+// ValidateAddress checks a against the rules for its TON/NPI: alphanumeric
+// senders are at most 11 GSM characters, international numbers are E.164
+// (digits only, at most 15, no leading zero) and abbreviated or
+// network-specific short codes are 3 to 8 digits.
+func ValidateAddress(a SMEAddress) error {
+	if a.Addr == "" {
+		return fmt.Errorf("address is empty")
+	}
+	if len(a.Addr) > maxAddrLen {
+		return fmt.Errorf("address %q is longer than %d characters", a.Addr, maxAddrLen)
+	}
+	switch a.TON {
+	case TONAlphanumeric:
+		septets, err := gsm7SeptetCount(a.Addr)
+		if err != nil {
+			return fmt.Errorf("alphanumeric address %q: %w", a.Addr, err)
+		}
+		if septets > 11 {
+			return fmt.Errorf("alphanumeric address %q is %d GSM characters, at most 11 are allowed", a.Addr, septets)
+		}
+		if a.NPI != NPIUnknown {
+			return fmt.Errorf("alphanumeric address %q must use NPI %d, not %d", a.Addr, NPIUnknown, a.NPI)
+		}
+		return nil
+	case TONInternational:
+		if !isDigits(a.Addr) {
+			return fmt.Errorf("international number %q must contain digits only (no '+')", a.Addr)
+		}
+		if len(a.Addr) > 15 {
+			return fmt.Errorf("international number %q has %d digits, E.164 allows at most 15", a.Addr, len(a.Addr))
+		}
+		if a.Addr[0] == '0' {
+			return fmt.Errorf("international number %q must start with a country code, not 0", a.Addr)
+		}
+		if a.NPI != NPIISDN && a.NPI != NPIUnknown {
+			return fmt.Errorf("international number %q must use NPI %d (E.164), not %d", a.Addr, NPIISDN, a.NPI)
+		}
+		return nil
+	case TONAbbreviated, TONNetworkSpecific:
+		if !isDigits(a.Addr) || len(a.Addr) < 3 || len(a.Addr) > 8 {
+			return fmt.Errorf("short code %q must be 3 to 8 digits", a.Addr)
+		}
+		return nil
+	case TONNational, TONSubscriber:
+		if !isDigits(a.Addr) {
+			return fmt.Errorf("number %q must contain digits only", a.Addr)
+		}
+		return nil
+	case TONUnknown:
+		if a.NPI == NPIISDN && !isDigits(a.Addr) {
+			return fmt.Errorf("E.164 number %q must contain digits only", a.Addr)
+		}
+		return nil
+	}
+	return fmt.Errorf("unsupported TON %d for address %q", a.TON, a.Addr)
+}
+
+// countryCallingCodes maps ISO 3166 alpha-2 codes to calling codes for the
+// default country of NormalizeE164; numeric calling codes are accepted too.
+var countryCallingCodes = map[string]string{
+	"AE": "971", "AR": "54", "AT": "43", "AU": "61", "BD": "880", "BE": "32",
+	"BR": "55", "CA": "1", "CH": "41", "CN": "86", "DE": "49", "DK": "45",
+	"EG": "20", "ES": "34", "FI": "358", "FR": "33", "GB": "44", "GR": "30",
+	"ID": "62", "IE": "353", "IN": "91", "IT": "39", "JP": "81", "KE": "254",
+	"KR": "82", "MX": "52", "MY": "60", "NG": "234", "NL": "31", "NO": "47",
+	"NP": "977", "NZ": "64", "PH": "63", "PK": "92", "PL": "48", "PT": "351",
+	"RU": "7", "SA": "966", "SE": "46", "SG": "65", "TH": "66", "TR": "90",
+	"UA": "380", "US": "1", "VN": "84", "ZA": "27",
+}
+
+func callingCode(country string) (string, error) {
+	country = strings.TrimPrefix(strings.TrimSpace(country), "+")
+	if isDigits(country) {
+		return country, nil
+	}
+	if cc, ok := countryCallingCodes[strings.ToUpper(country)]; ok {
+		return cc, nil
+	}
+	return "", fmt.Errorf("unknown default country %q", country)
+}
+
+// NormalizeE164 converts a phone number written with '+', '00' or a national
+// trunk prefix '0' into E.164 digits (without '+'). defaultCountry, an ISO
+// code such as "GB" or a calling code such as "44", is used for national
+// numbers; it may be empty when every number is international. Numbers
+// without any prefix are taken as E.164 already.
+func NormalizeE164(number, defaultCountry string) (string, error) {
+	var b strings.Builder
+	for i, r := range strings.TrimSpace(number) {
+		switch {
+		case r >= '0' && r <= '9':
+			b.WriteRune(r)
+		case r == '+' && i == 0:
+			b.WriteRune(r)
+		case r == ' ' || r == '-' || r == '.' || r == '(' || r == ')':
+		default:
+			return "", fmt.Errorf("number %q contains %q", number, r)
+		}
+	}
+	n := b.String()
+
+	a := SMEAddress{TON: TONInternational, NPI: NPIISDN, Addr: n}
+	switch {
+	case strings.HasPrefix(n, "+"):
+		a.Addr = n[1:]
+	case strings.HasPrefix(n, "00"):
+		a.Addr = n[2:]
+	case strings.HasPrefix(n, "0"):
+		a.TON = TONNational
+	}
+	return a.E164(defaultCountry)
+}
+
+// E164 returns the E.164 digits of an international or national number.
+// National numbers get the calling code of defaultCountry in place of their
+// trunk prefix, if any.
+func (a SMEAddress) E164(defaultCountry string) (string, error) {
+	digits := a.Addr
+	switch a.TON {
+	case TONInternational:
+	case TONNational:
+		if defaultCountry == "" {
+			return "", fmt.Errorf("national number %q needs a default country", a.Addr)
+		}
+		cc, err := callingCode(defaultCountry)
+		if err != nil {
+			return "", err
+		}
+		digits = cc + strings.TrimPrefix(a.Addr, "0")
+	default:
+		return "", fmt.Errorf("address %q with TON %d is not an international or national number", a.Addr, a.TON)
+	}
+	if err := ValidateAddress(SMEAddress{TON: TONInternational, NPI: NPIISDN, Addr: digits}); err != nil {
+		return "", err
+	}
+	return digits, nil
+}
+
+var phoneFormatting = strings.NewReplacer(" ", "", "-", "", ".", "", "(", "", ")", "")
+
+// InferAddress picks TON/NPI from the shape of addr: '+' or '00' prefixed
+// numbers are international, other numbers starting with 0 are national,
+// 3-8 digit numbers are short codes, longer digit strings are taken as
+// E.164 without '+', and anything with letters is alphanumeric.
+// Spaces, dashes, dots and parentheses in phone numbers are dropped.
+func InferAddress(addr string) SMEAddress {
+	addr = strings.TrimSpace(addr)
+	if compact := phoneFormatting.Replace(addr); isDigits(strings.TrimPrefix(compact, "+")) {
+		addr = compact
+	}
+	switch {
+	case strings.HasPrefix(addr, "+") && isDigits(addr[1:]):
+		return SMEAddress{TON: TONInternational, NPI: NPIISDN, Addr: addr[1:]}
+	case strings.HasPrefix(addr, "00") && isDigits(addr[2:]):
+		return SMEAddress{TON: TONInternational, NPI: NPIISDN, Addr: addr[2:]}
+	case isDigits(addr) && addr[0] == '0':
+		return SMEAddress{TON: TONNational, NPI: NPIISDN, Addr: addr}
+	case isDigits(addr) && len(addr) <= 8:
+		return SMEAddress{TON: TONNetworkSpecific, NPI: NPIUnknown, Addr: addr}
+	case isDigits(addr):
+		return SMEAddress{TON: TONInternational, NPI: NPIISDN, Addr: addr}
+	}
+	return SMEAddress{TON: TONAlphanumeric, NPI: NPIUnknown, Addr: addr}
+}
+
+// resolveAddress infers TON/NPI for addr, normalizes phone numbers to E.164
+// when defaultCountry is set, and validates the result.
+func resolveAddress(addr, defaultCountry string) (SMEAddress, error) {
+	a := InferAddress(addr)
+	if defaultCountry != "" && (a.TON == TONNational || a.TON == TONInternational) {
+		e164, err := a.E164(defaultCountry)
+		if err != nil {
+			return a, err
+		}
+		a = SMEAddress{TON: TONInternational, NPI: NPIISDN, Addr: e164}
+	}
+	return a, ValidateAddress(a)
+}
@@ -0,0 +1,47 @@
+package main
+
+import "testing"
+
+func TestNormalizeE164(t *testing.T) {
+	for _, tc := range []struct {
+		number, country, want string
+	}{
+		{"+44 7700 900123", "", "447700900123"},
+		{"0044 7700 900123", "US", "447700900123"},
+		{"07700 900123", "GB", "447700900123"},
+		{"(030) 1234-5678", "49", "493012345678"},
+		// Without a prefix the number is E.164 already, however short.
+		{"4930123456", "GB", "4930123456"},
+		{"1415555267", "GB", "1415555267"},
+	} {
+		got, err := NormalizeE164(tc.number, tc.country)
+		if err != nil || got != tc.want {
+			t.Errorf("NormalizeE164(%q, %q) = %q, %v; want %q", tc.number, tc.country, got, err, tc.want)
+		}
+	}
+	for _, number := range []string{"07700 900123", "+44 7700 900123x", "+0123456"} {
+		if got, err := NormalizeE164(number, ""); err == nil {
+			t.Errorf("NormalizeE164(%q) = %q, want an error", number, got)
+		}
+	}
+}
+
+func TestSMEAddressE164(t *testing.T) {
+	for _, tc := range []struct {
+		a    SMEAddress
+		want string
+	}{
+		{SMEAddress{TON: TONInternational, NPI: NPIISDN, Addr: "447700900123"}, "447700900123"},
+		{SMEAddress{TON: TONNational, NPI: NPIISDN, Addr: "07700900123"}, "447700900123"},
+		// An explicit national TON needs no trunk prefix.
+		{SMEAddress{TON: TONNational, NPI: NPIISDN, Addr: "7700900123"}, "447700900123"},
+	} {
+		got, err := tc.a.E164("GB")
+		if err != nil || got != tc.want {
+			t.Errorf("%+v.E164(GB) = %q, %v; want %q", tc.a, got, err, tc.want)
+		}
+	}
+	if got, err := (SMEAddress{TON: TONAlphanumeric, Addr: "ACME"}).E164("GB"); err == nil {
+		t.Errorf("alphanumeric E164 = %q, want an error", got)
+	}
+}
@@ -0,0 +1,372 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/linxGnu/gosmpp/data"
+	"github.com/linxGnu/gosmpp/pdu"
+)
+
+// UDH information elements for application port addressing (3GPP TS 23.040 9.2.3.24.3/4).
+const (
+	ieiPorts8  byte = 0x04
+	ieiPorts16 byte = 0x05
+)
+
+// Well-known destination ports.
+const (
+	PortWAPPush      = 2948
+	PortWAPPushWSP   = 9200 // WAP connectionless session service, used as source port
+	PortVCard        = 9204
+	PortVCalendar    = 9205
+	binaryDataCoding = 0x04
+)
+
+// BinaryMessage is an 8-bit payload with optional port addressing.
+type BinaryMessage struct {
+	Data []byte
+	// SourcePort and DestPort add a port addressing IE when DestPort is set.
+	// Ports above 255 (or Wide) use the 16-bit form.
+	SourcePort int
+	DestPort   int
+	Wide       bool
+	// RawUDH is passed through verbatim, including its length octet.
+	RawUDH []byte
+	// DataCoding and ProtocolID default to 0x04 and 0x00; SIM OTA uses
+	// 0xF6 (class 2, 8-bit) and 0x7F.
+	DataCoding byte
+	ProtocolID byte
+}
+
+// decodePayload decodes hex (spaces allowed) or base64 payload text.
+func decodePayload(s string, base64Encoded bool) ([]byte, error) {
+	if base64Encoded {
+		return base64.StdEncoding.DecodeString(strings.TrimSpace(s))
+	}
+	return hex.DecodeString(strings.Join(strings.Fields(s), ""))
+}
+
+// portIE returns the application port addressing information element.
+func portIE(src, dst int, wide bool) (pdu.InfoElement, error) {
+	if src < 0 || dst < 0 || src > 0xFFFF || dst > 0xFFFF {
+		return pdu.InfoElement{}, fmt.Errorf("ports %d/%d out of range", src, dst)
+	}
+	if !wide && src <= 0xFF && dst <= 0xFF {
+		return pdu.InfoElement{ID: ieiPorts8, Data: []byte{byte(dst), byte(src)}}, nil
+	}
+	return pdu.InfoElement{ID: ieiPorts16, Data: []byte{byte(dst >> 8), byte(dst), byte(src >> 8), byte(src)}}, nil
+}
+
+// messagePorts returns the ports addressed by udh, if any.
+func messagePorts(udh pdu.UDH) (src, dst int, ok bool) {
+	for _, ie := range udh {
+		switch {
+		case ie.ID == ieiPorts8 && len(ie.Data) == 2:
+			return int(ie.Data[1]), int(ie.Data[0]), true
+		case ie.ID == ieiPorts16 && len(ie.Data) == 4:
+			return int(ie.Data[2])<<8 | int(ie.Data[3]), int(ie.Data[0])<<8 | int(ie.Data[1]), true
+		}
+	}
+	return 0, 0, false
+}
+
+// parseUDH splits a raw UDH (length octet followed by IEs) into elements.
+func parseUDH(raw []byte) (pdu.UDH, error) {
+	if len(raw) == 0 {
+		return nil, nil
+	}
+	if int(raw[0]) != len(raw)-1 {
+		return nil, fmt.Errorf("UDH length octet %d does not match %d following octets", raw[0], len(raw)-1)
+	}
+	var udh pdu.UDH
+	for i := 1; i < len(raw); {
+		if i+2 > len(raw) || i+2+int(raw[i+1]) > len(raw) {
+			return nil, fmt.Errorf("UDH information element at offset %d is truncated", i)
+		}
+		n := int(raw[i+1])
+		udh = append(udh, pdu.InfoElement{ID: raw[i], Data: append([]byte(nil), raw[i+2:i+2+n]...)})
+		i += 2 + n
+	}
+	return udh, nil
+}
+
+// udh returns the information elements for m, excluding concatenation.
+func (m BinaryMessage) udh() (pdu.UDH, error) {
+	udh, err := parseUDH(m.RawUDH)
+	if err != nil {
+		return nil, err
+	}
+	if m.DestPort != 0 {
+		ie, err := portIE(m.SourcePort, m.DestPort, m.Wide)
+		if err != nil {
+			return nil, err
+		}
+		udh = append(udh, ie)
+	}
+	return udh, nil
+}
+
+func udhLen(udh pdu.UDH) int {
+	n := 0
+	for _, ie := range udh {
+		n += 2 + len(ie.Data)
+	}
+	if n > 0 {
+		n++ // UDHL
+	}
+	return n
+}
+
+// udhBytes encodes udh with its length octet.
+func udhBytes(udh pdu.UDH) []byte {
+	if len(udh) == 0 {
+		return nil
+	}
+	out := []byte{byte(udhLen(udh) - 1)}
+	for _, ie := range udh {
+		out = append(out, ie.ID, byte(len(ie.Data)))
+		out = append(out, ie.Data...)
+	}
+	return out
+}
+
+// NewBinarySubmitSM builds SubmitSM PDUs for an 8-bit payload, adding
+// concatenation IEs when the payload and UDH exceed one segment.
+func NewBinarySubmitSM(src, dest string, m BinaryMessage) ([]*pdu.SubmitSM, error) {
+	udh, err := m.udh()
+	if err != nil {
+		return nil, err
+	}
+	dc := m.DataCoding
+	if dc == 0 {
+		dc = binaryDataCoding
+	}
+	enc, err := DecodeDCS(dc).Encoding()
+	if err != nil {
+		return nil, err
+	}
+
+	chunks := [][]byte{m.Data}
+	if udhLen(udh)+len(m.Data) > 140 {
+		capacity := 140 - udhLen(append(udh, pdu.InfoElement{ID: concatIEI, Data: make([]byte, 3)}))
+		chunks = nil
+		for rest := m.Data; len(rest) > 0; {
+			n := capacity
+			if n > len(rest) {
+				n = len(rest)
+			}
+			chunks = append(chunks, rest[:n])
+			rest = rest[n:]
+		}
+		if len(chunks) > 255 {
+			return nil, fmt.Errorf("payload needs %d segments, at most 255 are allowed", len(chunks))
+		}
+	}
+
+	ref := byte(concatRef.Add(1))
+	parts := make([]*pdu.SubmitSM, 0, len(chunks))
+	for i, chunk := range chunks {
+		submit, err := newSubmitBase(src, dest, "")
+		if err != nil {
+			return nil, err
+		}
+		submit.ProtocolID = m.ProtocolID
+		if err := submit.Message.SetMessageDataWithEncoding(chunk, enc); err != nil {
+			return nil, err
+		}
+		ies := udh
+		if len(chunks) > 1 {
+			ies = append(pdu.UDH{{ID: concatIEI, Data: []byte{ref, byte(len(chunks)), byte(i + 1)}}}, udh...)
+		}
+		if len(ies) > 0 {
+			submit.Message.SetUDH(ies)
+			submit.EsmClass |= 0x40 // UDHI
+		}
+		parts = append(parts, submit)
+	}
+	return parts, nil
+}
+
+// WAP Push action values for service indication (SI) and service loading (SL).
+const (
+	SIActionNone   = "signal-none"
+	SIActionLow    = "signal-low"
+	SIActionMedium = "signal-medium"
+	SIActionHigh   = "signal-high"
+	SIActionDelete = "delete"
+	SLActionLow    = "execute-low"
+	SLActionHigh   = "execute-high"
+	SLActionCache  = "cache"
+)
+
+// WSP content types (WAP-230 Assigned Numbers), with the short-integer bit set.
+const (
+	wspContentTypeSI byte = 0xAE // application/vnd.wap.sic
+	wspContentTypeSL byte = 0xB0 // application/vnd.wap.slc
+)
+
+// wapPush wraps a WBXML body in a WSP connectionless push PDU with a UTF-8 charset.
+func wapPush(contentType byte, body []byte) BinaryMessage {
+	header := []byte{
+		0x01,        // transaction id
+		0x06,        // PDU type: push
+		0x04,        // headers length
+		0x03,        // content-type value length
+		contentType, // media type
+		0x81,        // charset parameter
+		0xEA,        // utf-8
+	}
+	return BinaryMessage{
+		Data:       append(header, body...),
+		SourcePort: PortWAPPushWSP,
+		DestPort:   PortWAPPush,
+		Wide:       true,
+	}
+}
+
+// wbxmlString encodes an inline string.
+func wbxmlString(s string) []byte {
+	return append(append([]byte{0x03}, s...), 0x00)
+}
+
+// wbxmlHref encodes an href attribute using the URL prefix tokens of the
+// document type. prefixes maps "http://", "http://www.", "https://" and
+// "https://www." to their tokens; plain is the bare href token.
+func wbxmlHref(url string, plain byte, prefixes map[string]byte) []byte {
+	best := ""
+	for p := range prefixes {
+		if strings.HasPrefix(url, p) && len(p) > len(best) {
+			best = p
+		}
+	}
+	if best == "" {
+		return append([]byte{plain}, wbxmlString(url)...)
+	}
+	return append([]byte{prefixes[best]}, wbxmlString(url[len(best):])...)
+}
+
+// WAPPushSI builds a service indication pointing at url with the given text.
+func WAPPushSI(url, text, action string) (BinaryMessage, error) {
+	actions := map[string]byte{SIActionNone: 0x05, SIActionLow: 0x06, SIActionMedium: 0x07, SIActionHigh: 0x08, SIActionDelete: 0x09}
+	if action == "" {
+		action = SIActionMedium
+	}
+	act, ok := actions[action]
+	if !ok {
+		return BinaryMessage{}, fmt.Errorf("unknown SI action %q", action)
+	}
+	var b bytes.Buffer
+	b.Write([]byte{0x02, 0x05, 0x6A, 0x00}) // WBXML 1.2, SI 1.0, UTF-8, no string table
+	b.WriteByte(0x45)                       // <si> with content
+	b.WriteByte(0xC6)                       // <indication> with attributes and content
+	b.Write(wbxmlHref(url, 0x0B, map[string]byte{"http://": 0x0C, "http://www.": 0x0D, "https://": 0x0E, "https://www.": 0x0F}))
+	b.WriteByte(act)
+	b.WriteByte(0x01) // end of attributes
+	b.Write(wbxmlString(text))
+	b.Write([]byte{0x01, 0x01}) // </indication></si>
+	return wapPush(wspContentTypeSI, b.Bytes()), nil
+}
+
+// WAPPushSL builds a service loading message for url.
+func WAPPushSL(url, action string) (BinaryMessage, error) {
+	actions := map[string]byte{SLActionLow: 0x05, SLActionHigh: 0x06, SLActionCache: 0x07}
+	if action == "" {
+		action = SLActionLow
+	}
+	act, ok := actions[action]
+	if !ok {
+		return BinaryMessage{}, fmt.Errorf("unknown SL action %q", action)
+	}
+	var b bytes.Buffer
+	b.Write([]byte{0x02, 0x06, 0x6A, 0x00}) // WBXML 1.2, SL 1.0, UTF-8, no string table
+	b.WriteByte(0x85)                       // <sl> with attributes, no content
+	b.Write(wbxmlHref(url, 0x08, map[string]byte{"http://": 0x09, "http://www.": 0x0A, "https://": 0x0B, "https://www.": 0x0C}))
+	b.WriteByte(act)
+	b.WriteByte(0x01) // end of attributes
+	return wapPush(wspContentTypeSL, b.Bytes()), nil
+}
+
+// VCard is a minimal vCard 2.1 contact.
+type VCard struct {
+	Name  string
+	Tel   string
+	Email string
+}
+
+// Message returns the vCard addressed to port 9204.
+func (v VCard) Message() BinaryMessage {
+	var b strings.Builder
+	b.WriteString("BEGIN:VCARD\r\nVERSION:2.1\r\n")
+	fmt.Fprintf(&b, "N:%s\r\n", v.Name)
+	if v.Tel != "" {
+		fmt.Fprintf(&b, "TEL;PREF:%s\r\n", v.Tel)
+	}
+	if v.Email != "" {
+		fmt.Fprintf(&b, "EMAIL;INTERNET:%s\r\n", v.Email)
+	}
+	b.WriteString("END:VCARD\r\n")
+	return BinaryMessage{Data: []byte(b.String()), SourcePort: PortVCard, DestPort: PortVCard, Wide: true}
+}
+
+// VCalendar is a minimal vCalendar 1.0 event.
+type VCalendar struct {
+	Summary string
+	Start   time.Time
+	End     time.Time
+}
+
+// Message returns the vCalendar addressed to port 9205.
+func (v VCalendar) Message() BinaryMessage {
+	const layout = "20060102T150405Z"
+	var b strings.Builder
+	b.WriteString("BEGIN:VCALENDAR\r\nVERSION:1.0\r\nBEGIN:VEVENT\r\n")
+	fmt.Fprintf(&b, "SUMMARY:%s\r\n", v.Summary)
+	fmt.Fprintf(&b, "DTSTART:%s\r\n", v.Start.UTC().Format(layout))
+	if !v.End.IsZero() {
+		fmt.Fprintf(&b, "DTEND:%s\r\n", v.End.UTC().Format(layout))
+	}
+	b.WriteString("END:VEVENT\r\nEND:VCALENDAR\r\n")
+	return BinaryMessage{Data: []byte(b.String()), SourcePort: PortVCalendar, DestPort: PortVCalendar, Wide: true}
+}
+
+// describeBinary summarises a port-addressed or 8-bit payload for logging.
+func describeBinary(udh pdu.UDH, payload []byte) string {
+	src, dst, ok := messagePorts(udh)
+	if !ok {
+		return fmt.Sprintf("binary payload %d octets: %X", len(payload), payload)
+	}
+	prefix := fmt.Sprintf("port %d -> %d, %d octets", src, dst, len(payload))
+	switch dst {
+	case PortWAPPush:
+		if len(payload) >= 5 && payload[1] == 0x06 {
+			kind := "WAP push"
+			switch {
+			case bytes.IndexByte(payload[3:], wspContentTypeSI) >= 0:
+				kind = "WAP push SI"
+			case bytes.IndexByte(payload[3:], wspContentTypeSL) >= 0:
+				kind = "WAP push SL"
+			}
+			return fmt.Sprintf("%s, %s: %X", prefix, kind, payload)
+		}
+	case PortVCard, PortVCalendar:
+		return fmt.Sprintf("%s:\n%s", prefix, payload)
+	}
+	return fmt.Sprintf("%s: %X", prefix, payload)
+}
+
+// isBinaryMessage reports whether an inbound message should be shown as binary.
+func isBinaryMessage(m *pdu.ShortMessage, dcs *DataCodingScheme) bool {
+	if _, _, ok := messagePorts(m.UDH()); ok {
+		return true
+	}
+	if dcs != nil {
+		return dcs.Alphabet == Alphabet8Bit
+	}
+	enc := m.Encoding()
+	return enc != nil && (enc.DataCoding() == data.BINARY8BIT1Coding || enc.DataCoding() == data.BINARY8BIT2Coding)
+}
@@ -0,0 +1,182 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/linxGnu/gosmpp/pdu"
+)
+
+// reparse marshals sm and parses it back, as the SMSC would receive it,
+// returning the user data without UDH.
+func reparse(t *testing.T, sm *pdu.SubmitSM) (*pdu.SubmitSM, []byte) {
+	t.Helper()
+	buf := pdu.NewBuffer(nil)
+	sm.Marshal(buf)
+	p, err := pdu.Parse(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := p.(*pdu.SubmitSM)
+	payload, err := got.Message.GetMessageData()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return got, payload
+}
+
+func TestPortUDH(t *testing.T) {
+	cases := []struct {
+		src, dst int
+		wide     bool
+		raw      []byte
+	}{
+		{0, 0x10, false, []byte{0x04, 0x04, 0x02, 0x10, 0x00}},
+		{0x20, 0x10, true, []byte{0x06, 0x05, 0x04, 0x00, 0x10, 0x00, 0x20}},
+		{PortWAPPushWSP, PortWAPPush, false, []byte{0x06, 0x05, 0x04, 0x0B, 0x84, 0x23, 0xF0}},
+	}
+	for _, tc := range cases {
+		ie, err := portIE(tc.src, tc.dst, tc.wide)
+		if err != nil {
+			t.Fatal(err)
+		}
+		raw, err := pdu.UDH{ie}.MarshalBinary()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(raw, tc.raw) {
+			t.Errorf("ports %d -> %d (wide %v): UDH %X, want %X", tc.src, tc.dst, tc.wide, raw, tc.raw)
+		}
+		udh, err := parseUDH(raw)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if src, dst, ok := messagePorts(udh); !ok || src != tc.src || dst != tc.dst {
+			t.Errorf("UDH %X addresses %d -> %d (%v)", raw, src, dst, ok)
+		}
+	}
+	if _, err := portIE(0, 0x10000, true); err == nil {
+		t.Error("port 65536 accepted")
+	}
+	if _, _, ok := messagePorts(pdu.UDH{{ID: concatIEI, Data: []byte{1, 2, 1}}}); ok {
+		t.Error("a concatenation IE was read as ports")
+	}
+	for _, raw := range [][]byte{{0x05, 0x00, 0x03, 0x01}, {0x03, 0x00, 0x03, 0x01}} {
+		if udh, err := parseUDH(raw); err == nil {
+			t.Errorf("parseUDH(%X) = %v", raw, udh)
+		}
+	}
+}
+
+func TestWAPPush(t *testing.T) {
+	si, err := WAPPushSI("http://www.example.com/", "Hi", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []byte{
+		0x01, 0x06, 0x04, 0x03, 0xAE, 0x81, 0xEA, // WSP push, application/vnd.wap.sic, UTF-8
+		0x02, 0x05, 0x6A, 0x00, 0x45, 0xC6, // WBXML header, <si><indication
+		0x0D, 0x03, 'e', 'x', 'a', 'm', 'p', 'l', 'e', '.', 'c', 'o', 'm', '/', 0x00, // href="http://www."...
+		0x07, 0x01, // action="signal-medium">
+		0x03, 'H', 'i', 0x00, 0x01, 0x01, // Hi</indication></si>
+	}
+	if !bytes.Equal(si.Data, want) {
+		t.Errorf("SI = %X, want %X", si.Data, want)
+	}
+	if si.SourcePort != PortWAPPushWSP || si.DestPort != PortWAPPush {
+		t.Errorf("SI ports %d -> %d", si.SourcePort, si.DestPort)
+	}
+
+	sl, err := WAPPushSL("https://example.com/x", SLActionCache)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if body := sl.Data[7:]; !bytes.Equal(body[:6], []byte{0x02, 0x06, 0x6A, 0x00, 0x85, 0x0B}) || body[len(body)-2] != 0x07 {
+		t.Errorf("SL = %X", sl.Data)
+	}
+	if _, err := WAPPushSI("http://example.com", "", "shout"); err == nil {
+		t.Error("unknown SI action accepted")
+	}
+	if _, err := WAPPushSL("http://example.com", "run"); err == nil {
+		t.Error("unknown SL action accepted")
+	}
+
+	parts, err := NewBinarySubmitSM("ACME", "447700900001", si)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(parts) != 1 {
+		t.Fatalf("SI sent in %d parts", len(parts))
+	}
+	got, payload := reparse(t, parts[0])
+	if got.EsmClass&0x40 == 0 || got.Message.Encoding().DataCoding() != binaryDataCoding {
+		t.Errorf("esm_class 0x%02X, data_coding 0x%02X", got.EsmClass, got.Message.Encoding().DataCoding())
+	}
+	if !isBinaryMessage(&got.Message, nil) {
+		t.Error("received SI is not shown as binary")
+	}
+	if desc := describeBinary(got.Message.UDH(), payload); !strings.HasPrefix(desc, "port 9200 -> 2948, 36 octets, WAP push SI: ") {
+		t.Errorf("describeBinary = %s", desc)
+	}
+}
+
+func TestVCard(t *testing.T) {
+	m := VCard{Name: "Doe;Jane", Tel: "+447700900001"}.Message()
+	want := "BEGIN:VCARD\r\nVERSION:2.1\r\nN:Doe;Jane\r\nTEL;PREF:+447700900001\r\nEND:VCARD\r\n"
+	if string(m.Data) != want {
+		t.Errorf("vCard = %q, want %q", m.Data, want)
+	}
+	parts, err := NewBinarySubmitSM("ACME", "447700900001", m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, payload := reparse(t, parts[0])
+	desc := describeBinary(got.Message.UDH(), payload)
+	if desc != "port 9204 -> 9204, 73 octets:\n"+want {
+		t.Errorf("describeBinary = %q", desc)
+	}
+}
+
+func TestBinaryConcatenation(t *testing.T) {
+	data := bytes.Repeat([]byte{0xA5, 0x5A, 0x00}, 100)
+	m := BinaryMessage{Data: data, SourcePort: 0x1234, DestPort: 0x5678, ProtocolID: 0x7F, DataCoding: 0xF6}
+	parts, err := NewBinarySubmitSM("ACME", "447700900001", m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 140 octets less a UDH of concatenation and 16-bit ports: 128 per part.
+	if len(parts) != 3 {
+		t.Fatalf("%d octets sent in %d parts, want 3", len(data), len(parts))
+	}
+	var joined []byte
+	var ref byte
+	for i, part := range parts {
+		got, payload := reparse(t, part)
+		udh := got.Message.UDH()
+		if len(udh) != 2 || udh[0].ID != concatIEI {
+			t.Fatalf("part %d UDH %v", i+1, udh)
+		}
+		if i == 0 {
+			ref = udh[0].Data[0]
+		}
+		if c := udh[0].Data; c[0] != ref || c[1] != 3 || c[2] != byte(i+1) {
+			t.Errorf("part %d concatenation IE %X", i+1, c)
+		}
+		if src, dst, ok := messagePorts(udh); !ok || src != 0x1234 || dst != 0x5678 {
+			t.Errorf("part %d ports %d -> %d", i+1, src, dst)
+		}
+		// gosmpp cannot parse data_coding 0xF6, so check what was sent.
+		if got.ProtocolID != 0x7F || part.Message.Encoding().DataCoding() != 0xF6 {
+			t.Errorf("part %d protocol_id 0x%02X, data_coding 0x%02X", i+1, got.ProtocolID, part.Message.Encoding().DataCoding())
+		}
+		joined = append(joined, payload...)
+	}
+	if !bytes.Equal(joined, data) {
+		t.Errorf("reassembled payload %X", joined)
+	}
+
+	if _, err := NewBinarySubmitSM("ACME", "447700900001", BinaryMessage{Data: data, RawUDH: []byte{0x09}}); err == nil {
+		t.Error("a malformed raw UDH was accepted")
+	}
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"fmt"
+	"net"
+
+	"github.com/linxGnu/gosmpp"
+	"github.com/linxGnu/gosmpp/data"
+	"github.com/linxGnu/gosmpp/pdu"
+)
+
+// bindConnection sends a bind request of the given type over an already
+// established connection and waits for the matching bind response.
+// It returns the negotiated interface version: the lower of the requested
+// version and the SMSC's sc_interface_version, which per SMPP v3.4 section
+// 5.3.2.25 is taken to be 3.3 when the SMSC omits it.
+// The connection is closed if binding fails.
+func bindConnection(conn net.Conn, bindingType pdu.BindingType, auth gosmpp.Auth, version byte) (*gosmpp.Connection, byte, error) {
+	c := gosmpp.NewConnection(conn)
+
+	req := pdu.NewBindRequest(bindingType)
+	req.SystemID = auth.SystemID
+	req.Password = auth.Password
+	req.SystemType = auth.SystemType
+	req.InterfaceVersion = version
+
+	if _, err := c.WritePDU(req); err != nil {
+		_ = c.Close()
+		return nil, 0, err
+	}
+
+	for {
+		p, err := pdu.Parse(c)
+		if err != nil {
+			_ = c.Close()
+			return nil, 0, err
+		}
+		resp, ok := p.(*pdu.BindResp)
+		if !ok {
+			continue
+		}
+		if resp.CommandStatus != data.ESME_ROK {
+			_ = c.Close()
+			return nil, 0, fmt.Errorf("bind failed: %v", resp.CommandStatus)
+		}
+		negotiated := SMPPVersion33
+		if f, ok := resp.OptionalParameters[pdu.Tag(tagScInterfaceVersion)]; ok && len(f.Data) == 1 {
+			negotiated = f.Data[0]
+		}
+		if version < negotiated {
+			negotiated = version
+		}
+		return c, negotiated, nil
+	}
+}
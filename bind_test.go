@@ -0,0 +1,69 @@
+package main
+
+import (
+	"net"
+	"testing"
+
+	"github.com/linxGnu/gosmpp"
+	"github.com/linxGnu/gosmpp/data"
+	"github.com/linxGnu/gosmpp/pdu"
+)
+
+func TestBindVersionNegotiation(t *testing.T) {
+	cases := []struct {
+		name      string
+		requested byte
+		smsc      byte // sc_interface_version, omitted when 0
+		status    data.CommandStatusType
+		want      byte
+	}{
+		{"5.0 both", SMPPVersion50, SMPPVersion50, data.ESME_ROK, SMPPVersion50},
+		{"SMSC at 3.4", SMPPVersion50, SMPPVersion34, data.ESME_ROK, SMPPVersion34},
+		{"ESME at 3.4", SMPPVersion34, SMPPVersion50, data.ESME_ROK, SMPPVersion34},
+		{"omitted", SMPPVersion50, 0, data.ESME_ROK, SMPPVersion33},
+		{"refused", SMPPVersion34, SMPPVersion34, data.ESME_RBINDFAIL, 0},
+	}
+	for _, tc := range cases {
+		esme, smsc := net.Pipe()
+		requested := make(chan byte, 1)
+		go func() {
+			defer smsc.Close()
+			p, err := pdu.Parse(smsc)
+			if err != nil {
+				return
+			}
+			bind := p.(*pdu.BindRequest)
+			requested <- bind.InterfaceVersion
+			resp := pdu.NewBindResp(*bind)
+			resp.CommandStatus = tc.status
+			if tc.smsc != 0 {
+				resp.RegisterOptionalParam(pdu.Field{Tag: pdu.Tag(tagScInterfaceVersion), Data: []byte{tc.smsc}})
+			}
+			buf := pdu.NewBuffer(nil)
+			resp.Marshal(buf)
+			_, _ = smsc.Write(buf.Bytes())
+			// Hold the connection open until the ESME closes it.
+			_, _ = pdu.Parse(smsc)
+		}()
+
+		conn, version, err := bindConnection(esme, pdu.Transceiver, gosmpp.Auth{SystemID: "test"}, tc.requested)
+		if got := <-requested; got != tc.requested {
+			t.Errorf("%s: bind sent interface_version 0x%02X, want 0x%02X", tc.name, got, tc.requested)
+		}
+		if tc.status != data.ESME_ROK {
+			if err == nil {
+				t.Errorf("%s: bind refused with %v succeeded", tc.name, tc.status)
+				_ = conn.Close()
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", tc.name, err)
+			continue
+		}
+		if version != tc.want {
+			t.Errorf("%s: negotiated 0x%02X, want 0x%02X", tc.name, version, tc.want)
+		}
+		_ = conn.Close()
+	}
+}
@@ -0,0 +1,195 @@
+package main
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+)
+
+// Broadcast area formats for the broadcast_area_identifier TLV.
+const (
+	BroadcastAreaAlias     byte = 0x00
+	BroadcastAreaEllipsoid byte = 0x01
+	BroadcastAreaPolygon   byte = 0x02
+)
+
+// Units for the broadcast_frequency_interval TLV.
+const (
+	FrequencyAsFrequentAsPossible byte = 0x00
+	FrequencySeconds              byte = 0x08
+	FrequencyMinutes              byte = 0x09
+	FrequencyHours                byte = 0x0A
+	FrequencyDays                 byte = 0x0B
+)
+
+// BroadcastArea is a single broadcast_area_identifier value.
+type BroadcastArea struct {
+	Format  byte
+	Details []byte
+}
+
+// BroadcastAreaName returns an area identified by an alias or name.
+func BroadcastAreaName(name string) BroadcastArea {
+	return BroadcastArea{Format: BroadcastAreaAlias, Details: []byte(name)}
+}
+
+func (a BroadcastArea) bytes() []byte {
+	return append([]byte{a.Format}, a.Details...)
+}
+
+// BroadcastRequest holds the fields of a broadcast_sm (SMPP v5.0 section 4.4.1.1).
+type BroadcastRequest struct {
+	ServiceType          string
+	SourceAddrTON        byte
+	SourceAddrNPI        byte
+	SourceAddr           string
+	MessageID            string // set to replace an existing broadcast
+	PriorityFlag         byte
+	ScheduleDeliveryTime string
+	ValidityPeriod       string
+	ReplaceIfPresent     bool
+	DataCoding           byte
+
+	Areas             []BroadcastArea
+	ContentNetwork    byte   // first octet of broadcast_content_type
+	ContentService    uint16 // remaining octets of broadcast_content_type
+	RepNum            uint16
+	FrequencyUnit     byte
+	FrequencyInterval uint16
+	Payload           []byte
+}
+
+// BroadcastResult is the outcome of broadcast_sm or query_broadcast_sm.
+type BroadcastResult struct {
+	MessageID    string
+	Status       CommandStatus
+	ErrorStatus  CommandStatus // broadcast_error_status, if returned
+	MessageState byte          // message_state, query_broadcast_sm only
+	AreaSuccess  []byte        // broadcast_area_success, query_broadcast_sm only
+	EndTime      string        // broadcast_end_time, query_broadcast_sm only
+}
+
+func (r BroadcastRequest) encode(sequence uint32) ([]byte, error) {
+	if len(r.Areas) == 0 {
+		return nil, fmt.Errorf("broadcast_sm requires at least one broadcast area")
+	}
+	var b pduBuilder
+	b.cstring(r.ServiceType)
+	b.octet(r.SourceAddrTON)
+	b.octet(r.SourceAddrNPI)
+	b.cstring(r.SourceAddr)
+	b.cstring(r.MessageID)
+	b.octet(r.PriorityFlag)
+	b.cstring(r.ScheduleDeliveryTime)
+	b.cstring(r.ValidityPeriod)
+	if r.ReplaceIfPresent {
+		b.octet(1)
+	} else {
+		b.octet(0)
+	}
+	b.octet(r.DataCoding)
+	b.octet(0) // sm_default_msg_id
+
+	for _, area := range r.Areas {
+		b.tlv(tagBroadcastAreaIdentifier, area.bytes())
+	}
+	contentType := []byte{r.ContentNetwork, 0, 0}
+	binary.BigEndian.PutUint16(contentType[1:], r.ContentService)
+	b.tlv(tagBroadcastContentType, contentType)
+	rep := make([]byte, 2)
+	binary.BigEndian.PutUint16(rep, r.RepNum)
+	b.tlv(tagBroadcastRepNum, rep)
+	freq := []byte{r.FrequencyUnit, 0, 0}
+	binary.BigEndian.PutUint16(freq[1:], r.FrequencyInterval)
+	b.tlv(tagBroadcastFrequencyInterval, freq)
+	if len(r.Payload) > 0 {
+		b.tlv(tagMessagePayload, r.Payload)
+	}
+	return b.bytes(cmdBroadcastSM, ESME_ROK, sequence), nil
+}
+
+// decodeBroadcastResp decodes broadcast_sm_resp and query_broadcast_sm_resp bodies.
+func decodeBroadcastResp(frame []byte) (BroadcastResult, error) {
+	hdr, err := parsePDUHeader(frame)
+	if err != nil {
+		return BroadcastResult{}, err
+	}
+	res := BroadcastResult{Status: hdr.Status}
+	if hdr.CommandID == cmdGenericNack || hdr.CommandID == cmdCancelBroadcastResp {
+		return res, nil
+	}
+	r := newPDUReader(frame[pduHeaderLen:hdr.Length])
+	if r.remaining() == 0 {
+		return res, nil
+	}
+	res.MessageID = r.cstring("message_id")
+	for _, t := range r.tlvs() {
+		switch t.Tag {
+		case tagBroadcastErrorStatus:
+			if len(t.Value) == 4 {
+				res.ErrorStatus = CommandStatus(binary.BigEndian.Uint32(t.Value))
+			}
+		case tagMessageState:
+			if len(t.Value) == 1 {
+				res.MessageState = t.Value[0]
+			}
+		case tagBroadcastAreaSuccess:
+			res.AreaSuccess = append(res.AreaSuccess, t.Value...)
+		case tagBroadcastEndTime:
+			res.EndTime = string(t.Value)
+		}
+	}
+	return res, r.err
+}
+
+// BroadcastSM submits a cell broadcast message. It requires an SMPP 5.0 bind.
+func (c *Client) BroadcastSM(ctx context.Context, req BroadcastRequest) (BroadcastResult, error) {
+	seq := c.nextRawSequence()
+	frame, err := req.encode(seq)
+	if err != nil {
+		return BroadcastResult{}, err
+	}
+	return c.broadcastRequest(ctx, "broadcast_sm", seq, frame)
+}
+
+// QueryBroadcastSM queries the state of a previously submitted broadcast.
+func (c *Client) QueryBroadcastSM(ctx context.Context, messageID string, srcTON, srcNPI byte, src string) (BroadcastResult, error) {
+	seq := c.nextRawSequence()
+	var b pduBuilder
+	b.cstring(messageID)
+	b.octet(srcTON)
+	b.octet(srcNPI)
+	b.cstring(src)
+	return c.broadcastRequest(ctx, "query_broadcast_sm", seq, b.bytes(cmdQueryBroadcastSM, ESME_ROK, seq))
+}
+
+// CancelBroadcastSM cancels a previously submitted broadcast.
+func (c *Client) CancelBroadcastSM(ctx context.Context, serviceType, messageID string, srcTON, srcNPI byte, src string) error {
+	seq := c.nextRawSequence()
+	var b pduBuilder
+	b.cstring(serviceType)
+	b.cstring(messageID)
+	b.octet(srcTON)
+	b.octet(srcNPI)
+	b.cstring(src)
+	_, err := c.broadcastRequest(ctx, "cancel_broadcast_sm", seq, b.bytes(cmdCancelBroadcastSM, ESME_ROK, seq))
+	return err
+}
+
+func (c *Client) broadcastRequest(ctx context.Context, name string, seq uint32, frame []byte) (BroadcastResult, error) {
+	if v := c.InterfaceVersion(); v < SMPPVersion50 {
+		return BroadcastResult{}, fmt.Errorf("%s requires SMPP 5.0 (negotiated version 0x%02X)", name, v)
+	}
+	resp, err := c.rawRequest(ctx, seq, frame)
+	if err != nil {
+		return BroadcastResult{}, fmt.Errorf("%s: %w", name, err)
+	}
+	res, err := decodeBroadcastResp(resp)
+	if err != nil {
+		return res, fmt.Errorf("%s: %w", name, err)
+	}
+	if res.Status != ESME_ROK {
+		return res, fmt.Errorf("%s failed: %v", name, res.Status)
+	}
+	return res, nil
+}
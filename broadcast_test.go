@@ -0,0 +1,88 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestBroadcastQueryCancel(t *testing.T) {
+	m := newMockSMSC(t)
+	m.setVersion(SMPPVersion50)
+	cfg := m.config(t)
+	cfg.InterfaceVersion = SMPPVersion50
+	client := NewClient(cfg)
+	if err := client.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := client.WaitBound(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if v := client.InterfaceVersion(); v != SMPPVersion50 {
+		t.Fatalf("negotiated version 0x%02X, want 0x50", v)
+	}
+
+	if _, err := client.BroadcastSM(ctx, BroadcastRequest{SourceAddr: "ACME"}); err == nil {
+		t.Error("broadcast_sm without a broadcast area was sent")
+	}
+	res, err := client.BroadcastSM(ctx, BroadcastRequest{
+		SourceAddr:        "ACME",
+		Areas:             []BroadcastArea{BroadcastAreaName("London")},
+		RepNum:            1,
+		FrequencyUnit:     FrequencyMinutes,
+		FrequencyInterval: 5,
+		Payload:           []byte("Flood warning"),
+	})
+	if err != nil {
+		t.Fatalf("broadcast_sm: %v", err)
+	}
+	if res.MessageID == "" || res.Status != ESME_ROK {
+		t.Fatalf("broadcast_sm result %+v", res)
+	}
+
+	q, err := client.QueryBroadcastSM(ctx, res.MessageID, 0, 0, "ACME")
+	if err != nil {
+		t.Fatalf("query_broadcast_sm: %v", err)
+	}
+	if q.MessageID != res.MessageID || q.MessageState != 1 || len(q.AreaSuccess) != 1 || q.AreaSuccess[0] != 100 {
+		t.Errorf("query_broadcast_sm result %+v", q)
+	}
+
+	if err := client.CancelBroadcastSM(ctx, "", res.MessageID, 0, 0, "ACME"); err != nil {
+		t.Fatalf("cancel_broadcast_sm: %v", err)
+	}
+	if q, err := client.QueryBroadcastSM(ctx, res.MessageID, 0, 0, "ACME"); err != nil || q.MessageState != 2 {
+		t.Errorf("query after cancel = %+v, %v", q, err)
+	}
+	err = client.CancelBroadcastSM(ctx, "", res.MessageID, 0, 0, "ACME")
+	if err == nil {
+		t.Error("cancelling a cancelled broadcast succeeded")
+	}
+	if _, err := client.QueryBroadcastSM(ctx, "unknown", 0, 0, "ACME"); err == nil {
+		t.Error("query_broadcast_sm of an unknown message succeeded")
+	}
+}
+
+func TestBroadcastRequiresSMPP50(t *testing.T) {
+	m := newMockSMSC(t)
+	m.setVersion(SMPPVersion34)
+	cfg := m.config(t)
+	cfg.InterfaceVersion = SMPPVersion50
+	client := NewClient(cfg)
+	if err := client.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := client.WaitBound(ctx); err != nil {
+		t.Fatal(err)
+	}
+	_, err := client.BroadcastSM(ctx, BroadcastRequest{SourceAddr: "ACME", Areas: []BroadcastArea{BroadcastAreaName("London")}})
+	if err == nil {
+		t.Error("broadcast_sm was sent on an SMPP 3.4 bind")
+	}
+}
@@ -0,0 +1,406 @@
+package main
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"sync"
+	"time"
+)
+
+// Capture records the traffic of wrapped connections: every PDU as a line
+// of a JSONL journal and, optionally, the byte stream as a pcap file of
+// synthesized IPv4/TCP packets. Connections are wrapped above TLS, so the
+// pcap holds the plaintext SMPP stream and opens in Wireshark's SMPP
+// dissector (use "Decode As... SMPP" for ports other than 2775).
+type Capture struct {
+	mu      sync.Mutex
+	journal *os.File
+	pcap    *os.File
+	conns   int
+	ipID    uint16
+	// shares counts the extra holders from share; each holder closes.
+	shares int
+}
+
+// JournalEntry is one line of the capture journal. Event is "connect",
+// "pdu", "unframed" (octets that do not form a PDU) or "close". Direction
+// is "out" towards the SMSC and "in" from it.
+type JournalEntry struct {
+	Time          time.Time `json:"time"`
+	Conn          int       `json:"conn"`
+	Event         string    `json:"event"`
+	Direction     string    `json:"direction,omitempty"`
+	Local         string    `json:"local,omitempty"`
+	Remote        string    `json:"remote,omitempty"`
+	CommandLength uint32    `json:"command_length,omitempty"`
+	CommandID     string    `json:"command_id,omitempty"`
+	CommandStatus string    `json:"command_status,omitempty"`
+	Sequence      *uint32   `json:"sequence_number,omitempty"`
+	Hex           string    `json:"hex,omitempty"`
+	Error         string    `json:"error,omitempty"`
+}
+
+// maxCaptureFrame bounds the command_length the journal waits for; longer
+// declared lengths are journaled as unframed octets.
+const maxCaptureFrame = 1 << 20
+
+// pcap constants: microsecond timestamps and raw IP packets.
+const (
+	pcapMagic      = 0xA1B2C3D4
+	pcapLinkRaw    = 101
+	pcapSnapLen    = 0x40000
+	captureSegment = 65000 // TCP payload per packet, below the IPv4 limit
+)
+
+// TCP flags used in synthesized packets.
+const (
+	tcpFIN = 0x01
+	tcpSYN = 0x02
+	tcpPSH = 0x08
+	tcpACK = 0x10
+)
+
+// OpenCapture opens the journal and pcap files, appending to existing ones
+// so that successive runs add to the same capture. Either path may be empty.
+func OpenCapture(journalPath, pcapPath string) (*Capture, error) {
+	c := &Capture{}
+	var err error
+	if journalPath != "" {
+		if c.journal, err = os.OpenFile(journalPath, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0o644); err != nil {
+			return nil, err
+		}
+		// Number connections after those already journaled.
+		if c.conns, err = lastJournalConn(c.journal); err != nil {
+			c.Close()
+			return nil, fmt.Errorf("%s: %w", journalPath, err)
+		}
+	}
+	if pcapPath != "" {
+		if c.pcap, err = os.OpenFile(pcapPath, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0o644); err != nil {
+			c.Close()
+			return nil, err
+		}
+		if err := c.writePcapHeader(); err != nil {
+			c.Close()
+			return nil, fmt.Errorf("%s: %w", pcapPath, err)
+		}
+	}
+	return c, nil
+}
+
+// lastJournalConn returns the highest connection number in journal.
+func lastJournalConn(journal *os.File) (int, error) {
+	last := 0
+	dec := json.NewDecoder(io.NewSectionReader(journal, 0, 1<<62))
+	for {
+		var e JournalEntry
+		if err := dec.Decode(&e); err == io.EOF {
+			return last, nil
+		} else if err != nil {
+			return 0, fmt.Errorf("not a capture journal: %w", err)
+		}
+		if e.Conn > last {
+			last = e.Conn
+		}
+	}
+}
+
+// writePcapHeader writes the pcap file header to an empty file, or checks
+// that of an existing file before packets are appended to it.
+func (c *Capture) writePcapHeader() error {
+	hdr := make([]byte, 24)
+	binary.LittleEndian.PutUint32(hdr[0:4], pcapMagic)
+	binary.LittleEndian.PutUint16(hdr[4:6], 2)
+	binary.LittleEndian.PutUint16(hdr[6:8], 4)
+	binary.LittleEndian.PutUint32(hdr[16:20], pcapSnapLen)
+	binary.LittleEndian.PutUint32(hdr[20:24], pcapLinkRaw)
+
+	existing := make([]byte, len(hdr))
+	switch n, err := c.pcap.ReadAt(existing, 0); {
+	case n == 0 && err == io.EOF:
+		_, err = c.pcap.Write(hdr)
+		return err
+	case n == len(hdr) && binary.LittleEndian.Uint32(existing[0:4]) == pcapMagic &&
+		binary.LittleEndian.Uint32(existing[20:24]) == pcapLinkRaw:
+		return nil
+	}
+	return errors.New("not a raw IPv4 pcap written by a capture; refusing to append")
+}
+
+// captureForConfig opens the capture files named in cfg, or returns nil
+// when none are.
+func captureForConfig(cfg Config) (*Capture, error) {
+	if cfg.CaptureJournal == "" && cfg.CapturePcap == "" {
+		return nil, nil
+	}
+	return OpenCapture(cfg.CaptureJournal, cfg.CapturePcap)
+}
+
+// share returns c for another client to record into. Every holder closes
+// it; the files are closed by the last Close.
+func (c *Capture) share() *Capture {
+	if c == nil {
+		return nil
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.shares++
+	return c
+}
+
+// Close closes the capture files once every holder has closed them.
+// Connections still wrapped stop recording.
+func (c *Capture) Close() error {
+	if c == nil {
+		return nil
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.shares > 0 {
+		c.shares--
+		return nil
+	}
+	var errs []error
+	for _, f := range []*os.File{c.journal, c.pcap} {
+		if f != nil {
+			errs = append(errs, f.Close())
+		}
+	}
+	c.journal, c.pcap = nil, nil
+	return errors.Join(errs...)
+}
+
+// Wrap returns conn recording its traffic into c. A nil Capture returns
+// conn unchanged.
+func (c *Capture) Wrap(conn net.Conn) net.Conn {
+	if c == nil {
+		return conn
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.conns++
+	cc := &captureConn{Conn: conn, capture: c, id: c.conns}
+	cc.local = captureAddr(conn.LocalAddr(), [4]byte{10, 0, 0, 1}, uint16(40000+c.conns))
+	cc.remote = captureAddr(conn.RemoteAddr(), [4]byte{10, 0, 0, 2}, 2775)
+	cc.out.dir, cc.in.dir = "out", "in"
+
+	c.writeEntry(JournalEntry{Time: time.Now(), Conn: cc.id, Event: "connect",
+		Local: conn.LocalAddr().String(), Remote: conn.RemoteAddr().String()})
+	// A handshake lets Wireshark follow the stream from its first octet.
+	now := time.Now()
+	c.writePacket(now, cc.local, cc.remote, 0, 0, tcpSYN, nil)
+	c.writePacket(now, cc.remote, cc.local, 0, 1, tcpSYN|tcpACK, nil)
+	c.writePacket(now, cc.local, cc.remote, 1, 1, tcpACK, nil)
+	cc.out.seq, cc.in.seq = 1, 1
+	return cc
+}
+
+// ipPort is an IPv4 address and port in a synthesized packet.
+type ipPort struct {
+	ip   [4]byte
+	port uint16
+}
+
+// captureAddr returns the IPv4 address and port of addr, or the fallback
+// for non-TCP and IPv6 addresses.
+func captureAddr(addr net.Addr, ip [4]byte, port uint16) ipPort {
+	p := ipPort{ip: ip, port: port}
+	if tcp, ok := addr.(*net.TCPAddr); ok {
+		if v4 := tcp.IP.To4(); v4 != nil {
+			copy(p.ip[:], v4)
+		}
+		p.port = uint16(tcp.Port)
+	}
+	return p
+}
+
+// captureStream is one direction of a captured connection: the octets not
+// yet journaled, the next TCP sequence number and whether it has ended.
+type captureStream struct {
+	dir string
+	buf []byte
+	seq uint32
+	fin bool
+}
+
+// captureConn is a net.Conn recorded by a Capture.
+type captureConn struct {
+	net.Conn
+	capture       *Capture
+	id            int
+	local, remote ipPort
+	out, in       captureStream
+	closeOnce     sync.Once
+}
+
+// Read implements net.Conn.
+func (cc *captureConn) Read(b []byte) (int, error) {
+	n, err := cc.Conn.Read(b)
+	if n > 0 {
+		cc.capture.record(cc, &cc.in, b[:n])
+	}
+	if err == io.EOF {
+		cc.capture.finish(cc, &cc.in, "closed by peer")
+	}
+	return n, err
+}
+
+// Write implements net.Conn.
+func (cc *captureConn) Write(b []byte) (int, error) {
+	n, err := cc.Conn.Write(b)
+	if n > 0 {
+		cc.capture.record(cc, &cc.out, b[:n])
+	}
+	return n, err
+}
+
+// Close implements net.Conn.
+func (cc *captureConn) Close() error {
+	cc.closeOnce.Do(func() { cc.capture.finish(cc, &cc.out, "") })
+	return cc.Conn.Close()
+}
+
+// ends returns the source and destination of packets in s's direction.
+func (cc *captureConn) ends(s *captureStream) (src, dst ipPort, ack uint32) {
+	if s == &cc.out {
+		return cc.local, cc.remote, cc.in.seq
+	}
+	return cc.remote, cc.local, cc.out.seq
+}
+
+// record writes data read or written on cc to the pcap and journals the
+// PDUs it completes.
+func (c *Capture) record(cc *captureConn, s *captureStream, data []byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := time.Now()
+	src, dst, ack := cc.ends(s)
+	for rest := data; len(rest) > 0; {
+		n := len(rest)
+		if n > captureSegment {
+			n = captureSegment
+		}
+		c.writePacket(now, src, dst, s.seq, ack, tcpPSH|tcpACK, rest[:n])
+		s.seq += uint32(n)
+		rest = rest[n:]
+	}
+
+	s.buf = append(s.buf, data...)
+	for len(s.buf) >= 4 {
+		length := binary.BigEndian.Uint32(s.buf[0:4])
+		if length < pduHeaderLen || length > maxCaptureFrame {
+			c.writeEntry(JournalEntry{Time: now, Conn: cc.id, Event: "unframed", Direction: s.dir,
+				Hex: hex.EncodeToString(s.buf), Error: fmt.Sprintf("invalid command_length %d", length)})
+			s.buf = nil
+			return
+		}
+		if uint32(len(s.buf)) < length {
+			return
+		}
+		frame := s.buf[:length]
+		h, _ := parsePDUHeader(frame)
+		seq := h.Sequence
+		c.writeEntry(JournalEntry{Time: now, Conn: cc.id, Event: "pdu", Direction: s.dir,
+			CommandLength: h.Length, CommandID: commandName(h.CommandID), CommandStatus: h.Status.String(),
+			Sequence: &seq, Hex: hex.EncodeToString(frame)})
+		s.buf = append([]byte(nil), s.buf[length:]...)
+	}
+}
+
+// finish journals the octets left over in s and the end of the
+// connection, and adds a FIN in s's direction to the pcap.
+func (c *Capture) finish(cc *captureConn, s *captureStream, reason string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if s.fin {
+		return
+	}
+	s.fin = true
+	now := time.Now()
+	for _, st := range []*captureStream{&cc.out, &cc.in} {
+		if len(st.buf) > 0 {
+			c.writeEntry(JournalEntry{Time: now, Conn: cc.id, Event: "unframed", Direction: st.dir,
+				Hex: hex.EncodeToString(st.buf), Error: "incomplete PDU at close"})
+			st.buf = nil
+		}
+	}
+	c.writeEntry(JournalEntry{Time: now, Conn: cc.id, Event: "close", Direction: s.dir, Error: reason})
+	src, dst, ack := cc.ends(s)
+	c.writePacket(now, src, dst, s.seq, ack, tcpFIN|tcpACK, nil)
+	s.seq++
+}
+
+// writeEntry appends e to the journal. Each entry is written at once so
+// the journal survives the process exiting on a failed test.
+func (c *Capture) writeEntry(e JournalEntry) {
+	if c.journal == nil {
+		return
+	}
+	line, _ := json.Marshal(e)
+	_, _ = c.journal.Write(append(line, '\n'))
+}
+
+// writePacket appends an IPv4/TCP packet carrying payload to the pcap.
+func (c *Capture) writePacket(t time.Time, src, dst ipPort, seq, ack uint32, flags byte, payload []byte) {
+	if c.pcap == nil {
+		return
+	}
+	c.ipID++
+	total := 40 + len(payload)
+	pkt := make([]byte, 16+total)
+	binary.LittleEndian.PutUint32(pkt[0:4], uint32(t.Unix()))
+	binary.LittleEndian.PutUint32(pkt[4:8], uint32(t.Nanosecond()/1000))
+	binary.LittleEndian.PutUint32(pkt[8:12], uint32(total))
+	binary.LittleEndian.PutUint32(pkt[12:16], uint32(total))
+
+	ip := pkt[16:36]
+	ip[0] = 0x45
+	binary.BigEndian.PutUint16(ip[2:4], uint16(total))
+	binary.BigEndian.PutUint16(ip[4:6], c.ipID)
+	ip[6] = 0x40 // don't fragment
+	ip[8] = 64
+	ip[9] = 6 // TCP
+	copy(ip[12:16], src.ip[:])
+	copy(ip[16:20], dst.ip[:])
+	binary.BigEndian.PutUint16(ip[10:12], ipChecksum(ip, 0))
+
+	tcp := pkt[36:]
+	binary.BigEndian.PutUint16(tcp[0:2], src.port)
+	binary.BigEndian.PutUint16(tcp[2:4], dst.port)
+	binary.BigEndian.PutUint32(tcp[4:8], seq)
+	if flags&tcpACK != 0 {
+		binary.BigEndian.PutUint32(tcp[8:12], ack)
+	}
+	tcp[12] = 5 << 4
+	tcp[13] = flags
+	binary.BigEndian.PutUint16(tcp[14:16], 0xFFFF)
+	copy(tcp[20:], payload)
+	pseudo := uint32(src.ip[0])<<8 | uint32(src.ip[1])
+	pseudo += uint32(src.ip[2])<<8 | uint32(src.ip[3])
+	pseudo += uint32(dst.ip[0])<<8 | uint32(dst.ip[1])
+	pseudo += uint32(dst.ip[2])<<8 | uint32(dst.ip[3])
+	pseudo += 6 + uint32(len(tcp))
+	binary.BigEndian.PutUint16(tcp[16:18], ipChecksum(tcp, pseudo))
+
+	_, _ = c.pcap.Write(pkt)
+}
+
+// ipChecksum is the Internet checksum of b, starting from sum.
+func ipChecksum(b []byte, sum uint32) uint16 {
+	for i := 0; i+1 < len(b); i += 2 {
+		sum += uint32(b[i])<<8 | uint32(b[i+1])
+	}
+	if len(b)%2 == 1 {
+		sum += uint32(b[len(b)-1]) << 8
+	}
+	for sum > 0xFFFF {
+		sum = sum>>16 + sum&0xFFFF
+	}
+	return ^uint16(sum)
+}
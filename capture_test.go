@@ -0,0 +1,183 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// readJournal returns the entries of a capture journal.
+func readJournal(t *testing.T, path string) []JournalEntry {
+	t.Helper()
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	var entries []JournalEntry
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		var e JournalEntry
+		if err := json.Unmarshal(sc.Bytes(), &e); err != nil {
+			t.Fatalf("%s: %v", path, err)
+		}
+		entries = append(entries, e)
+	}
+	return entries
+}
+
+// captureRun records one connection carrying an enquire_link.
+func captureRun(t *testing.T, journal, pcap string) {
+	t.Helper()
+	c, err := OpenCapture(journal, pcap)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client, server := net.Pipe()
+	go func() {
+		buf := make([]byte, pduHeaderLen)
+		_, _ = server.Read(buf)
+		_ = server.Close()
+	}()
+	conn := c.Wrap(client)
+	var b pduBuilder
+	if _, err := conn.Write(b.bytes(cmdEnquireLink, ESME_ROK, 1)); err != nil {
+		t.Fatal(err)
+	}
+	_ = conn.Close()
+	if err := c.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestOpenCaptureAppends(t *testing.T) {
+	dir := t.TempDir()
+	journal, pcap := filepath.Join(dir, "capture.jsonl"), filepath.Join(dir, "capture.pcap")
+	captureRun(t, journal, pcap)
+	first, _ := os.Stat(pcap)
+	captureRun(t, journal, pcap)
+	second, _ := os.Stat(pcap)
+
+	conns := map[int]bool{}
+	pdus := 0
+	for _, e := range readJournal(t, journal) {
+		conns[e.Conn] = true
+		if e.Event == "pdu" {
+			pdus++
+		}
+	}
+	if pdus != 2 || !conns[1] || !conns[2] {
+		t.Errorf("journal holds %d PDUs on connections %v, want one PDU on each of connections 1 and 2", pdus, conns)
+	}
+	// The second run appends packets without a second file header.
+	if want := 2*first.Size() - 24; second.Size() != want {
+		t.Errorf("pcap is %d octets after two runs, want %d", second.Size(), want)
+	}
+
+	other := filepath.Join(dir, "other.pcap")
+	if err := os.WriteFile(other, []byte("not a pcap file at all, honestly"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := OpenCapture("", other); err == nil {
+		t.Error("OpenCapture appended to a file that is not a pcap")
+	}
+}
+
+func TestOutbindCaptureRecordsRejected(t *testing.T) {
+	journal := filepath.Join(t.TempDir(), "capture.jsonl")
+	capture, err := OpenCapture(journal, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	o := newOutbindConnector(Config{OutbindSystemID: "smsc", OutbindPassword: "secret", ReadTimeout: 5 * time.Second}, ln)
+	o.capture = capture.Wrap
+	rejected := make(chan error, 1)
+	o.onBindFailed = func(err error) { rejected <- err }
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go o.serve(ctx)
+
+	conn, err := net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	outbind, _ := commandIDByName("outbind")
+	var b pduBuilder
+	b.cstring("smsc")
+	b.cstring("wrong")
+	if _, err := conn.Write(b.bytes(outbind, ESME_ROK, 1)); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-rejected:
+	case <-time.After(5 * time.Second):
+		t.Fatal("outbind with a wrong password was not rejected")
+	}
+	_ = capture.Close()
+
+	var events []string
+	for _, e := range readJournal(t, journal) {
+		events = append(events, e.Event+" "+e.CommandID)
+	}
+	want := []string{"connect ", "pdu outbind", "close "}
+	if len(events) != len(want) {
+		t.Fatalf("journal events %q, want %q", events, want)
+	}
+	for i := range want {
+		if events[i] != want[i] {
+			t.Fatalf("journal events %q, want %q", events, want)
+		}
+	}
+}
+
+func TestCaptureSharedUntilLastClose(t *testing.T) {
+	journal := filepath.Join(t.TempDir(), "capture.jsonl")
+	c, err := OpenCapture(journal, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	receiver := c.share()
+	// The transmitter closes first, as Shutdown does before the receiver
+	// is closed: the receiver's traffic, its unbind included, is recorded.
+	if err := c.Close(); err != nil {
+		t.Fatal(err)
+	}
+	client, server := net.Pipe()
+	go func() {
+		buf := make([]byte, pduHeaderLen)
+		_, _ = server.Read(buf)
+		_ = server.Close()
+	}()
+	conn := receiver.Wrap(client)
+	var b pduBuilder
+	if _, err := conn.Write(b.bytes(cmdUnbind, ESME_ROK, 7)); err != nil {
+		t.Fatal(err)
+	}
+	_ = conn.Close()
+	if err := receiver.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	var unbinds int
+	for _, e := range readJournal(t, journal) {
+		if e.Event == "pdu" && e.CommandID == "unbind" {
+			unbinds++
+		}
+	}
+	if unbinds != 1 {
+		t.Errorf("journal holds %d unbinds after the first Close, want 1", unbinds)
+	}
+	if receiver.journal != nil {
+		t.Error("the journal is still open after the last Close")
+	}
+}
@@ -1,10 +1,14 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"log"
+	"net"
 	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/linxGnu/gosmpp"
 	"github.com/linxGnu/gosmpp/pdu"
@@ -14,34 +18,156 @@ import (
 type Client struct {
 	cfg          Config
 	session      *gosmpp.Session
+	connector    *failoverConnector
+	stopFailback chan struct{}
+	stopListener context.CancelFunc
+	state        *stateMachine
+	throttle     congestionThrottle
+	handlers     []func(pdu.PDU)
+	inflight     *inflightTracker
+	draining     atomic.Bool
+	unbindResp   chan struct{}
 	concatMu     sync.Mutex
 	concatenated map[uint8][]string
+	capture      *Capture
+
+	rawMu      sync.Mutex
+	raw        *pduConn
+	version    byte
+	rawPending map[uint32]chan []byte
+	rawSeq     uint32
+	// inboundDCS holds the data_coding octet of received deliver_sm/data_sm
+	// PDUs by sequence number, since gosmpp drops values it does not know.
+	inboundDCS map[uint32]byte
 }
 
 // NewClient creates a new Client with given configuration.
 func NewClient(cfg Config) *Client {
 	return &Client{
 		cfg:          cfg,
+		state:        newStateMachine(),
 		concatenated: make(map[uint8][]string),
+		inflight:     newInflightTracker(),
+		unbindResp:   make(chan struct{}, 1),
+		rawPending:   make(map[uint32]chan []byte),
+		inboundDCS:   make(map[uint32]byte),
+		// Sequence numbers for PDUs sent outside gosmpp start high to stay
+		// clear of the numbers gosmpp assigns.
+		rawSeq: 0x70000000,
 	}
 }
 
-// Connect starts the SMPP session.
+// Connect starts the SMPP session. Configured endpoints are tried in
+// priority order; on connection loss the session rebinds to the next healthy one.
 func (c *Client) Connect() error {
-	auth := gosmpp.Auth{
-		SMSC:       fmt.Sprintf("%s:%s", c.cfg.Host, c.cfg.Port),
-		SystemID:   c.cfg.SystemID,
-		Password:   c.cfg.Password,
-		SystemType: c.cfg.SystemType,
+	if err := c.openCapture(); err != nil {
+		return err
+	}
+	connector := newFailoverConnector(c.cfg, TLSDialer)
+	connector.onSwitch = func(from, to Endpoint) {
+		log.Printf("SMSC failover: switched from %s to %s", from.Addr(), to.Addr())
+	}
+	connector.wrap = c.wrapConn
+	connector.onConnecting = func() { c.state.set(StateConnecting, nil) }
+	connector.onBound = func(_ Endpoint, version byte) { c.bound(connector.GetBindType(), version) }
+	connector.onBindFailed = func(err error) {
+		c.state.bindFailed()
+		c.state.set(StateDisconnected, err)
+	}
+	c.connector = connector
+
+	settings := c.settings(connector.markLost)
+	session, err := gosmpp.NewSession(connector, settings, c.cfg.ReadTimeout)
+	if err != nil {
+		c.state.set(StateDisconnected, err)
+		return err
+	}
+	c.session = session
+
+	if c.cfg.FailbackInterval > 0 && len(c.cfg.endpoints()) > 1 {
+		c.stopFailback = make(chan struct{})
+		go connector.failback(c.cfg.FailbackInterval, c.stopFailback)
+	}
+	return nil
+}
+
+// Listen starts the session in outbind mode: it listens on cfg.OutbindAddr,
+// waits for the SMSC to connect and send an authenticated outbind, and then
+// binds as a receiver on that connection. It returns once the first bind
+// succeeds; after a connection loss the session waits for the next outbind.
+func (c *Client) Listen(ctx context.Context) error {
+	if err := c.openCapture(); err != nil {
+		return err
+	}
+	ln, err := outbindListener(c.cfg)
+	if err != nil {
+		return err
+	}
+	log.Printf("Waiting for outbind on %v", ln.Addr())
+
+	ctx, cancel := context.WithCancel(ctx)
+	connector := newOutbindConnector(c.cfg, ln)
+	connector.capture = c.capture.Wrap
+	connector.wrap = c.rawConn
+	connector.onConnecting = func() { c.state.set(StateConnecting, nil) }
+	connector.onBound = func(version byte) { c.bound(connector.GetBindType(), version) }
+	connector.onBindFailed = func(error) { c.state.bindFailed() }
+	go connector.serve(ctx)
+	c.stopListener = cancel
+
+	session, err := gosmpp.NewSession(connector, c.settings(nil), c.cfg.ReadTimeout)
+	if err != nil {
+		cancel()
+		c.state.set(StateDisconnected, err)
+		return err
+	}
+	c.session = session
+	return nil
+}
+
+// openCapture opens the PDU capture configured in cfg, once per client.
+func (c *Client) openCapture() error {
+	if c.capture != nil {
+		return nil
 	}
+	capture, err := captureForConfig(c.cfg)
+	if err != nil {
+		return fmt.Errorf("opening capture: %w", err)
+	}
+	c.capture = capture
+	return nil
+}
+
+// bound records a successful bind and its negotiated interface version.
+func (c *Client) bound(bindingType pdu.BindingType, version byte) {
+	c.rawMu.Lock()
+	c.version = version
+	c.rawMu.Unlock()
+	c.state.set(boundState(bindingType), nil)
+}
 
-	connector := gosmpp.TRXConnector(TLSDialer, auth)
+// InterfaceVersion returns the SMPP version negotiated on the current bind.
+func (c *Client) InterfaceVersion() byte {
+	c.rawMu.Lock()
+	defer c.rawMu.Unlock()
+	return c.version
+}
 
-	settings := gosmpp.Settings{
+// HandlePDU registers fn to be called with every PDU delivered by the session,
+// before the client's own handling. It must be called before Connect or Listen.
+func (c *Client) HandlePDU(fn func(pdu.PDU)) {
+	c.handlers = append(c.handlers, fn)
+}
+
+// settings builds the gosmpp session settings shared by Connect and Listen.
+// onLost, if set, is told about every connection loss that was not requested by Close.
+func (c *Client) settings(onLost func(error)) gosmpp.Settings {
+	return gosmpp.Settings{
 		EnquireLink: c.cfg.EnquireLink,
 		ReadTimeout: c.cfg.ReadTimeout,
-		OnSubmitError: func(_ pdu.PDU, err error) {
+		OnSubmitError: func(p pdu.PDU, err error) {
 			log.Printf("SubmitPDU error: %v", err)
+			c.inflight.respond(p.GetSequenceNumber(), "", false)
 		},
 		OnReceivingError: func(err error) {
 			log.Printf("Receiving PDU/Network error: %v", err)
@@ -49,24 +175,81 @@ func (c *Client) Connect() error {
 		OnRebindingError: func(err error) {
 			log.Printf("Rebinding error: %v", err)
 		},
-		OnPDU:    c.onPDU,
-		OnClosed: func(state gosmpp.State) { log.Printf("SMPP connection closed: %v", state) },
-	}
-
-	session, err := gosmpp.NewSession(connector, settings, c.cfg.ReadTimeout)
-	if err != nil {
-		return err
+		OnPDU: c.onPDU,
+		OnClosed: func(state gosmpp.State) {
+			log.Printf("SMPP connection closed: %v", state)
+			if state == gosmpp.ExplicitClosing {
+				c.state.set(StateClosed, nil)
+				return
+			}
+			err := fmt.Errorf("connection closed: %v", state)
+			if onLost != nil {
+				onLost(err)
+			}
+			c.state.set(StateDisconnected, err)
+		},
 	}
-	c.session = session
-	return nil
 }
 
 // Close closes the session.
 func (c *Client) Close() error {
+	if c.stopFailback != nil {
+		close(c.stopFailback)
+		c.stopFailback = nil
+	}
+	if c.stopListener != nil {
+		c.stopListener()
+		c.stopListener = nil
+	}
 	if c.session == nil {
+		c.state.set(StateClosed, nil)
+		return c.capture.Close()
+	}
+	c.state.set(StateUnbinding, nil)
+	err := c.session.Close()
+	c.state.set(StateClosed, err)
+	if cerr := c.capture.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// State returns the current lifecycle state of the session.
+func (c *Client) State() ConnState {
+	state, _ := c.state.current()
+	return state
+}
+
+// Subscribe registers fn to be called on every state transition.
+// The returned function removes the subscription.
+func (c *Client) Subscribe(fn func(StateChange)) (unsubscribe func()) {
+	return c.state.subscribe(fn)
+}
+
+// WaitBound blocks until the session is bound, the client is closed or ctx is done.
+func (c *Client) WaitBound(ctx context.Context) error {
+	return c.state.waitBound(ctx)
+}
+
+// Stats returns bind and rebind counters for the session.
+func (c *Client) Stats() SessionStats {
+	return c.state.snapshot()
+}
+
+// ActiveEndpoint returns the SMSC endpoint the session is currently bound to.
+func (c *Client) ActiveEndpoint() (Endpoint, bool) {
+	if c.connector == nil {
+		return Endpoint{}, false
+	}
+	return c.connector.Active()
+}
+
+// Endpoints returns the health of every configured SMSC endpoint.
+func (c *Client) Endpoints() []EndpointStatus {
+	if c.connector == nil {
 		return nil
 	}
-	return c.session.Close()
+	return c.connector.Status()
 }
 
 // SendSMS submits a SubmitSM PDU via the session transceiver.
@@ -74,23 +257,170 @@ func (c *Client) SendSMS(sm *pdu.SubmitSM) error {
 	if c.session == nil {
 		return fmt.Errorf("session not connected")
 	}
-	return c.session.Transceiver().Submit(sm)
+	if c.draining.Load() {
+		return ErrShuttingDown
+	}
+	if state := c.State(); state != StateBoundTX && state != StateBoundTRX {
+		return fmt.Errorf("session cannot submit (state %s)", state)
+	}
+	if d := c.throttle.delay(); d > 0 {
+		time.Sleep(d)
+	}
+	// Only value 1 guarantees a receipt; 2 asks for one on failure only.
+	c.inflight.add(sm.SequenceNumber, sm.RegisteredDelivery&0x03 == 1)
+	if err := c.session.Transceiver().Submit(sm); err != nil {
+		c.inflight.respond(sm.SequenceNumber, "", false)
+		return err
+	}
+	return nil
+}
+
+// wrapConn installs the capture and the raw PDU layer on a freshly dialed
+// or accepted connection. The capture sits below the raw layer so it sees
+// the PDUs that layer consumes too.
+func (c *Client) wrapConn(conn net.Conn) net.Conn {
+	return c.rawConn(c.capture.Wrap(conn))
+}
+
+// rawConn installs the raw PDU layer on conn and makes it the connection
+// raw requests are written to.
+func (c *Client) rawConn(conn net.Conn) net.Conn {
+	p := newPDUConn(conn, c.onRawPDU)
+	c.rawMu.Lock()
+	c.raw = p
+	c.rawMu.Unlock()
+	return p
+}
+
+func (c *Client) nextRawSequence() uint32 {
+	return atomic.AddUint32(&c.rawSeq, 1)
+}
+
+// rawRequest writes a pre-encoded request PDU and waits for the response
+// with the same sequence number.
+func (c *Client) rawRequest(ctx context.Context, seq uint32, frame []byte) ([]byte, error) {
+	ch := make(chan []byte, 1)
+	c.rawMu.Lock()
+	conn := c.raw
+	c.rawPending[seq] = ch
+	c.rawMu.Unlock()
+	defer func() {
+		c.rawMu.Lock()
+		delete(c.rawPending, seq)
+		c.rawMu.Unlock()
+	}()
+
+	if conn == nil {
+		return nil, fmt.Errorf("session not connected")
+	}
+	if err := conn.writeFrame(frame); err != nil {
+		return nil, err
+	}
+	select {
+	case resp := <-ch:
+		return resp, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// onRawPDU sees every inbound PDU before gosmpp does. It feeds congestion_state
+// into the submit throttle and consumes responses to requests sent via rawRequest.
+func (c *Client) onRawPDU(frame []byte) bool {
+	hdr, err := parsePDUHeader(frame)
+	if err != nil {
+		return false
+	}
+
+	switch hdr.CommandID {
+	case cmdSubmitSMResp, cmdDataSMResp:
+		r := newPDUReader(frame[pduHeaderLen:])
+		r.cstring("message_id")
+		if v, ok := findTLV(r.tlvs(), tagCongestionState); ok && len(v) == 1 {
+			c.throttle.observe(int(v[0]))
+		}
+		return false
+	case cmdDeliverSM, cmdDataSM:
+		if dc, ok := smDataCoding(hdr.CommandID, frame[pduHeaderLen:]); ok {
+			c.rawMu.Lock()
+			c.inboundDCS[hdr.Sequence] = dc
+			c.rawMu.Unlock()
+		}
+		return false
+	case cmdBroadcastSMResp, cmdQueryBroadcastSMResp, cmdCancelBroadcastResp, cmdGenericNack:
+		c.rawMu.Lock()
+		ch, ok := c.rawPending[hdr.Sequence]
+		c.rawMu.Unlock()
+		if !ok {
+			return false
+		}
+		ch <- frame
+		return true
+	}
+	return false
+}
+
+// takeInboundDCS returns and forgets the data_coding recorded for the
+// inbound PDU with sequence seq, or nil when it was not seen.
+func (c *Client) takeInboundDCS(seq int32) *DataCodingScheme {
+	c.rawMu.Lock()
+	defer c.rawMu.Unlock()
+	dc, ok := c.inboundDCS[uint32(seq)]
+	if !ok {
+		return nil
+	}
+	delete(c.inboundDCS, uint32(seq))
+	dcs := DecodeDCS(dc)
+	return &dcs
 }
 
 // onPDU handles incoming PDUs.
 func (c *Client) onPDU(p pdu.PDU, _ bool) {
+	for _, fn := range c.handlers {
+		fn(p)
+	}
 	switch pd := p.(type) {
 	case *pdu.SubmitSMResp:
 		log.Printf("SubmitSMResp: %+v", pd)
+		c.inflight.respond(pd.SequenceNumber, pd.MessageID, pd.IsOk())
 	case *pdu.GenericNack:
 		log.Println("GenericNack Received")
+		c.inflight.respond(pd.SequenceNumber, "", false)
+	case *pdu.UnbindResp:
+		log.Println("UnbindResp Received")
+		select {
+		case c.unbindResp <- struct{}{}:
+		default:
+		}
 	case *pdu.EnquireLinkResp:
 		log.Println("EnquireLinkResp Received")
 	case *pdu.DataSM:
 		log.Printf("DataSM: %+v", pd)
+		if dcs := c.takeInboundDCS(pd.SequenceNumber); dcs != nil {
+			log.Printf("DataSM data_coding: %s", dcs)
+		}
 	case *pdu.DeliverSM:
 		log.Printf("DeliverSM: %+v", pd)
-		message, err := pd.Message.GetMessage()
+		if id, ok := receiptMessageID(pd); ok {
+			c.inflight.receipt(id)
+		}
+		dcs := c.takeInboundDCS(pd.SequenceNumber)
+		if dcs != nil {
+			log.Printf("DeliverSM data_coding: %s", dcs)
+			if dcs.IsFlash() {
+				log.Println("DeliverSM is a flash (class 0) message")
+			}
+		}
+		if isBinaryMessage(&pd.Message, dcs) {
+			payload, err := pd.Message.GetMessageData()
+			if err != nil {
+				log.Printf("failed to get message data: %v", err)
+				return
+			}
+			log.Println("Binary message:", describeBinary(pd.Message.UDH(), payload))
+			return
+		}
+		message, err := messageText(&pd.Message, dcs)
 		if err != nil {
 			log.Printf("failed to get message: %v", err)
 			return
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/linxGnu/gosmpp/pdu"
+)
+
+// connectMock binds a client to a mock SMSC and records the message_id of
+// every submit_sm_resp by sequence number.
+func connectMock(t *testing.T, m *mockSMSC) (*Client, func(seq int32) string) {
+	t.Helper()
+	var mu sync.Mutex
+	ids := map[int32]string{}
+	client := NewClient(m.config(t))
+	client.HandlePDU(func(p pdu.PDU) {
+		if resp, ok := p.(*pdu.SubmitSMResp); ok {
+			mu.Lock()
+			ids[resp.SequenceNumber] = resp.MessageID
+			mu.Unlock()
+		}
+	})
+	if err := client.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := client.WaitBound(ctx); err != nil {
+		t.Fatal(err)
+	}
+	return client, func(seq int32) string {
+		mu.Lock()
+		defer mu.Unlock()
+		return ids[seq]
+	}
+}
+
+func TestScheduledMessagesAreDeferred(t *testing.T) {
+	m := newMockSMSC(t)
+	client, messageID := connectMock(t, m)
+
+	const delay = 2 * time.Second
+	cases := []struct {
+		name     string
+		opts     SubmitOptions
+		deferred bool
+	}{
+		{"unscheduled", SubmitOptions{}, false},
+		{"relative", SubmitOptions{ScheduleAfter: delay}, true},
+		{"absolute", SubmitOptions{ScheduleAt: time.Now().Add(delay)}, true},
+	}
+	seqs := make([]int32, len(cases))
+	for i, tc := range cases {
+		sm, _, err := NewSubmitSM("ACME", "447700900001", "scheduled "+tc.name, tc.opts)
+		if err != nil {
+			t.Fatalf("%s: %v", tc.name, err)
+		}
+		sm.RegisteredDelivery = 1
+		if err := client.SendSMS(sm); err != nil {
+			t.Fatalf("%s: %v", tc.name, err)
+		}
+		seqs[i] = sm.SequenceNumber
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	report, err := client.Shutdown(ctx, ShutdownOptions{WaitReceipts: true})
+	if err != nil {
+		t.Fatalf("Shutdown: %v (%s)", err, report)
+	}
+
+	for i, tc := range cases {
+		msg := m.message(messageID(seqs[i]))
+		if msg == nil {
+			t.Errorf("%s: not accepted by the SMSC", tc.name)
+			continue
+		}
+		select {
+		case <-msg.Delivered:
+		default:
+			t.Errorf("%s: receipt received before the message was delivered", tc.name)
+			continue
+		}
+		// Absolute times are sent in tenths of a second.
+		waited := msg.DeliveredAt.Sub(msg.SubmittedAt)
+		switch {
+		case tc.deferred && waited < delay-100*time.Millisecond:
+			t.Errorf("%s: delivered after %s, want it deferred by %s (schedule_delivery_time %q)",
+				tc.name, waited, delay, msg.Submit.ScheduleDeliveryTime)
+		case !tc.deferred && waited > delay/2:
+			t.Errorf("%s: delivered after %s, want it delivered at once", tc.name, waited)
+		}
+	}
+}
+
+func TestShutdownWaitsOnlyForRequestedReceipts(t *testing.T) {
+	m := newMockSMSC(t)
+	client, messageID := connectMock(t, m)
+
+	var seqs []int32
+	for rd := uint8(0); rd <= 2; rd++ {
+		sm, _, err := NewSubmitSM("ACME", "447700900001", "receipt test", SubmitOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		sm.RegisteredDelivery = rd
+		if err := client.SendSMS(sm); err != nil {
+			t.Fatal(err)
+		}
+		seqs = append(seqs, sm.SequenceNumber)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	report, err := client.Shutdown(ctx, ShutdownOptions{WaitReceipts: true})
+	if err != nil {
+		t.Fatalf("Shutdown: %v (%s)", err, report)
+	}
+	if len(report.Unanswered) > 0 || len(report.PendingReceipts) > 0 || !report.UnbindAcked {
+		t.Errorf("Shutdown left %s", report)
+	}
+	// Value 1 was waited for; value 2 (receipt on failure only) was not.
+	if msg := m.message(messageID(seqs[1])); msg == nil {
+		t.Error("registered_delivery 1: not accepted by the SMSC")
+	} else {
+		select {
+		case <-msg.Delivered:
+		default:
+			t.Error("registered_delivery 1: shut down before the receipt arrived")
+		}
+	}
+}
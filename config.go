@@ -2,7 +2,11 @@ package main
 
 import (
 	"fmt"
+	"net"
 	"os"
+	"sort"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/joho/godotenv"
@@ -19,34 +23,207 @@ type Config struct {
 	ReadTimeout time.Duration
 	TLS         bool
 
+	// InterfaceVersion is the SMPP version requested at bind time
+	// (SMPPVersion34 or SMPPVersion50).
+	InterfaceVersion byte
+
+	// Endpoints is the ordered list of SMSCs to bind to. When empty,
+	// Host and Port are used as the only endpoint.
+	Endpoints []Endpoint
+	// FailoverCooldown is how long a failed endpoint is skipped before it is retried.
+	FailoverCooldown time.Duration
+	// FailbackInterval is how often a higher priority endpoint is probed
+	// while bound to a backup. Zero disables fail-back.
+	FailbackInterval time.Duration
+
+	// OutbindAddr is the listen address for SMSC-initiated (outbind) sessions.
+	OutbindAddr string
+	// OutbindSystemID and OutbindPassword are the credentials the SMSC must present in its outbind.
+	OutbindSystemID string
+	OutbindPassword string
+	// OutbindCertFile and OutbindKeyFile enable TLS on the outbind listener when set.
+	OutbindCertFile string
+	OutbindKeyFile  string
+	// TranslitTable is an optional JSON file of GSM transliteration overrides.
+	TranslitTable string
+	// DefaultCountry (ISO code or calling code) normalizes national numbers to E.164.
+	DefaultCountry string
+	// GSMPacking is how the SMSC expects GSM 7-bit short messages: one
+	// septet per octet (the default) or packed.
+	GSMPacking GSMPacking
+	// CaptureJournal and CapturePcap, when set, record every PDU sent and
+	// received into a JSONL journal and a pcap file.
+	CaptureJournal string
+	CapturePcap    string
+
 	// optional defaults for demonstration
 	SourceAddr string
 	DestAddr   string
 }
 
+// SMPP interface_version values.
+const (
+	SMPPVersion33 byte = 0x33
+	SMPPVersion34 byte = 0x34
+	SMPPVersion50 byte = 0x50
+)
+
+// Endpoint is a single SMSC address. Lower Priority values are preferred.
+type Endpoint struct {
+	Host     string
+	Port     string
+	Priority int
+}
+
+// Addr returns the endpoint as host:port.
+func (e Endpoint) Addr() string {
+	return net.JoinHostPort(e.Host, e.Port)
+}
+
 // LoadConfigFromEnv loads configuration from environment variables and .env (if present).
-// Required: SMPP_HOST (SMPP_PORT has a default of 2775)
+// Required: SMPP_HOST, SMPP_ENDPOINTS or SMPP_OUTBIND_LISTEN (SMPP_PORT has a default of 2775)
 func LoadConfigFromEnv() (Config, error) {
 	_ = godotenv.Load() // ignore error; .env is optional
 
 	cfg := Config{
-		Host:        os.Getenv("SMPP_HOST"),
-		Port:        os.Getenv("SMPP_PORT"),
-		SystemID:    os.Getenv("SYSTEM_ID"),
-		Password:    os.Getenv("PASSWORD"),
-		SystemType:  os.Getenv("SYSTEM_TYPE"),
-		EnquireLink: 20 * time.Second,
-		ReadTimeout: 22 * time.Second,
-		TLS:         true,
-		SourceAddr:  os.Getenv("SMPP_SOURCE"),
-		DestAddr:    os.Getenv("SMPP_DEST"),
+		Host:             os.Getenv("SMPP_HOST"),
+		Port:             os.Getenv("SMPP_PORT"),
+		SystemID:         os.Getenv("SYSTEM_ID"),
+		Password:         os.Getenv("PASSWORD"),
+		SystemType:       os.Getenv("SYSTEM_TYPE"),
+		EnquireLink:      20 * time.Second,
+		ReadTimeout:      22 * time.Second,
+		TLS:              true,
+		InterfaceVersion: SMPPVersion34,
+		FailoverCooldown: 30 * time.Second,
+		FailbackInterval: 60 * time.Second,
+		OutbindAddr:      os.Getenv("SMPP_OUTBIND_LISTEN"),
+		OutbindSystemID:  os.Getenv("SMPP_OUTBIND_SYSTEM_ID"),
+		OutbindPassword:  os.Getenv("SMPP_OUTBIND_PASSWORD"),
+		OutbindCertFile:  os.Getenv("SMPP_OUTBIND_TLS_CERT"),
+		OutbindKeyFile:   os.Getenv("SMPP_OUTBIND_TLS_KEY"),
+		TranslitTable:    os.Getenv("SMPP_TRANSLIT_TABLE"),
+		DefaultCountry:   os.Getenv("SMPP_DEFAULT_COUNTRY"),
+		SourceAddr:       os.Getenv("SMPP_SOURCE"),
+		DestAddr:         os.Getenv("SMPP_DEST"),
+		CaptureJournal:   os.Getenv("SMPP_CAPTURE_JOURNAL"),
+		CapturePcap:      os.Getenv("SMPP_CAPTURE_PCAP"),
 	}
 
-	if cfg.Host == "" {
-		return cfg, fmt.Errorf("SMPP_HOST is required")
-	}
 	if cfg.Port == "" {
 		cfg.Port = "2775"
 	}
+
+	if v := os.Getenv("SMPP_VERSION"); v != "" {
+		version, err := parseInterfaceVersion(v)
+		if err != nil {
+			return cfg, err
+		}
+		cfg.InterfaceVersion = version
+	}
+	if v := os.Getenv("SMPP_GSM_PACKING"); v != "" {
+		packing, err := parseGSMPacking(v)
+		if err != nil {
+			return cfg, fmt.Errorf("invalid SMPP_GSM_PACKING: %w", err)
+		}
+		cfg.GSMPacking = packing
+	}
+	if v := os.Getenv("SMPP_ENDPOINTS"); v != "" {
+		endpoints, err := parseEndpoints(v, cfg.Port)
+		if err != nil {
+			return cfg, err
+		}
+		cfg.Endpoints = endpoints
+	}
+	if v := os.Getenv("SMPP_FAILOVER_COOLDOWN"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return cfg, fmt.Errorf("invalid SMPP_FAILOVER_COOLDOWN: %w", err)
+		}
+		cfg.FailoverCooldown = d
+	}
+	if v := os.Getenv("SMPP_FAILBACK_INTERVAL"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return cfg, fmt.Errorf("invalid SMPP_FAILBACK_INTERVAL: %w", err)
+		}
+		cfg.FailbackInterval = d
+	}
+
+	if cfg.Host == "" && len(cfg.Endpoints) == 0 && cfg.OutbindAddr == "" {
+		return cfg, fmt.Errorf("SMPP_HOST, SMPP_ENDPOINTS or SMPP_OUTBIND_LISTEN is required")
+	}
 	return cfg, nil
 }
+
+// LoadProfile loads configuration for a named SMSC profile. The profile's
+// settings are read from .env.<name> and take precedence over .env; variables
+// already set in the process environment take precedence over both.
+// An empty name is equivalent to LoadConfigFromEnv.
+func LoadProfile(name string) (Config, error) {
+	if name != "" {
+		if err := godotenv.Load(".env." + name); err != nil {
+			return Config{}, fmt.Errorf("loading profile %q: %w", name, err)
+		}
+	}
+	return LoadConfigFromEnv()
+}
+
+// parseInterfaceVersion accepts "3.4", "5.0" or a raw interface_version such as "0x50".
+func parseInterfaceVersion(s string) (byte, error) {
+	switch strings.TrimSpace(s) {
+	case "3.3", "33":
+		return SMPPVersion33, nil
+	case "3.4", "34":
+		return SMPPVersion34, nil
+	case "5.0", "5", "50":
+		return SMPPVersion50, nil
+	}
+	v, err := strconv.ParseUint(strings.TrimSpace(s), 0, 8)
+	if err != nil {
+		return 0, fmt.Errorf("invalid SMPP_VERSION %q", s)
+	}
+	return byte(v), nil
+}
+
+// endpoints returns the configured endpoints sorted by priority,
+// falling back to Host/Port when no list is configured.
+func (c Config) endpoints() []Endpoint {
+	if len(c.Endpoints) == 0 {
+		return []Endpoint{{Host: c.Host, Port: c.Port}}
+	}
+	eps := append([]Endpoint(nil), c.Endpoints...)
+	sort.SliceStable(eps, func(i, j int) bool { return eps[i].Priority < eps[j].Priority })
+	return eps
+}
+
+// parseEndpoints parses a comma separated list of host[:port][@priority].
+// Entries without an explicit priority are ranked by their position in the list.
+func parseEndpoints(s, defaultPort string) ([]Endpoint, error) {
+	var endpoints []Endpoint
+	for i, item := range strings.Split(s, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		ep := Endpoint{Port: defaultPort, Priority: i}
+		if at := strings.LastIndex(item, "@"); at >= 0 {
+			prio, err := strconv.Atoi(item[at+1:])
+			if err != nil {
+				return nil, fmt.Errorf("invalid priority in SMPP_ENDPOINTS entry %q", item)
+			}
+			ep.Priority = prio
+			item = item[:at]
+		}
+		if host, port, err := net.SplitHostPort(item); err == nil {
+			ep.Host, ep.Port = host, port
+		} else {
+			ep.Host = item
+		}
+		if ep.Host == "" {
+			return nil, fmt.Errorf("missing host in SMPP_ENDPOINTS entry %q", item)
+		}
+		endpoints = append(endpoints, ep)
+	}
+	return endpoints, nil
+}
@@ -0,0 +1,261 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/fatih/color"
+)
+
+// CostEstimate describes what a message costs on the wire.
+type CostEstimate struct {
+	Text       string          `json:"text"`
+	Encoding   MessageEncoding `json:"-"`
+	DataCoding byte            `json:"data_coding"`
+	// Septets is set for GSM 7-bit. Octets is the user data size of all
+	// segments without their UDH; packed GSM 7-bit includes the fill bits
+	// that align each segment's septets after its UDH.
+	Septets  int `json:"septets,omitempty"`
+	Octets   int `json:"octets"`
+	Segments int `json:"segments"`
+	// SplitPoints are the character offsets at which segments 2..n start.
+	SplitPoints []int `json:"split_points,omitempty"`
+	// UDHOctets is the UDH size per segment, including the length octet.
+	UDHOctets int `json:"udh_octets"`
+	// UCS2Chars lists the characters that can be sent neither in GSM 7-bit
+	// nor in Latin-1.
+	UCS2Chars    []string       `json:"ucs2_chars,omitempty"`
+	LockingShift GSMLanguage    `json:"-"`
+	SingleShift  GSMLanguage    `json:"-"`
+	Unresolved   []string       `json:"unresolved_placeholders,omitempty"`
+	Substituted  []Substitution `json:"substitutions,omitempty"`
+}
+
+// MarshalJSON renders the enum fields by name.
+func (e CostEstimate) MarshalJSON() ([]byte, error) {
+	type plain CostEstimate
+	return json.Marshal(struct {
+		plain
+		Encoding     string `json:"encoding"`
+		LockingShift string `json:"locking_shift"`
+		SingleShift  string `json:"single_shift"`
+	}{plain(e), e.Encoding.String(), e.LockingShift.String(), e.SingleShift.String()})
+}
+
+// EstimateCost reports the encoding, size and segmentation of text as
+// NewSubmitSMParts would send it.
+func EstimateCost(text string, opts SubmitOptions) (CostEstimate, error) {
+	message, report, _, err := prepareMessage(text, opts)
+	est := CostEstimate{
+		Text:         message,
+		Encoding:     report.Encoding,
+		DataCoding:   report.DataCoding,
+		LockingShift: report.LockingShift,
+		SingleShift:  report.SingleShift,
+		Substituted:  report.Substitutions,
+		UCS2Chars:    ucs2OnlyChars(message, opts.Languages),
+	}
+	if err != nil {
+		return est, err
+	}
+
+	segments, err := splitMessage(message, report, opts.Graphemes)
+	if err != nil {
+		return est, err
+	}
+	est.Segments = len(segments)
+	ies := len(report.gsmTables().udh())
+	if len(segments) > 1 {
+		ies += 5
+	}
+	if ies > 0 {
+		est.UDHOctets = 1 + ies
+	}
+	offset := 0
+	for i, seg := range segments {
+		if i > 0 {
+			est.SplitPoints = append(est.SplitPoints, offset)
+		}
+		offset += len([]rune(seg.Text))
+	}
+	if report.Encoding == EncodingGSM7 {
+		est.Septets = report.Length
+	}
+	fill := (7 - est.UDHOctets*8%7) % 7
+	for _, seg := range segments {
+		if report.Encoding == EncodingGSM7 && opts.Packing == GSMPacked {
+			est.Octets += (fill + seg.Length*7 + 7) / 8
+		} else {
+			est.Octets += seg.Length
+		}
+	}
+	return est, nil
+}
+
+// ucs2OnlyChars returns the distinct characters of s outside both the GSM
+// tables and Latin-1.
+func ucs2OnlyChars(s string, languages []GSMLanguage) []string {
+	seen := map[rune]bool{}
+	var out []string
+	for _, r := range s {
+		if seen[r] || r <= 0xFF || gsmRepresentable(r, languages) {
+			continue
+		}
+		seen[r] = true
+		out = append(out, string(r))
+	}
+	return out
+}
+
+var placeholderRE = regexp.MustCompile(`{{\s*([A-Za-z0-9_.-]+)\s*}}`)
+
+// renderTemplate replaces {{name}} placeholders with vars and returns the
+// names that had no value; those are left in the text as written.
+func renderTemplate(tmpl string, vars map[string]string) (string, []string) {
+	missing := map[string]bool{}
+	out := placeholderRE.ReplaceAllStringFunc(tmpl, func(m string) string {
+		name := placeholderRE.FindStringSubmatch(m)[1]
+		if v, ok := vars[name]; ok {
+			return v
+		}
+		missing[name] = true
+		return m
+	})
+	var names []string
+	for name := range missing {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return out, names
+}
+
+// varFlags collects repeated -var name=value flags.
+type varFlags map[string]string
+
+func (v varFlags) String() string {
+	var parts []string
+	for k, val := range v {
+		parts = append(parts, k+"="+val)
+	}
+	sort.Strings(parts)
+	return strings.Join(parts, ",")
+}
+
+func (v varFlags) Set(s string) error {
+	name, value, ok := strings.Cut(s, "=")
+	if !ok || name == "" {
+		return fmt.Errorf("want name=value, got %q", s)
+	}
+	v[name] = value
+	return nil
+}
+
+// runCost implements the "cost" subcommand.
+func runCost(args []string) int {
+	fs := flag.NewFlagSet("cost", flag.ExitOnError)
+	text := fs.String("text", "", "message text or template to estimate")
+	file := fs.String("file", "", "file with one template per line")
+	encoding := fs.String("encoding", "auto", "force an encoding: auto, gsm7, latin1 or ucs2")
+	langs := fs.String("lang", "", "comma-separated GSM national languages to allow (turkish, spanish, portuguese, hindi)")
+	translit := fs.Bool("transliterate", false, "replace non-GSM characters with GSM equivalents")
+	graphemes := fs.Bool("graphemes", false, "keep grapheme clusters within one segment")
+	packing := packingFlag(fs)
+	asJSON := fs.Bool("json", false, "print estimates as JSON lines")
+	vars := varFlags{}
+	fs.Var(vars, "var", "placeholder value as name=value (repeatable)")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: cost [-text TEXT | -file TEMPLATES] [-var name=value ...] [flags]")
+		fs.PrintDefaults()
+	}
+	_ = fs.Parse(args)
+
+	var opts SubmitOptions
+	var err error
+	if opts.Encoding, err = parseMessageEncoding(*encoding); err != nil {
+		color.Red("%v", err)
+		return 2
+	}
+	if opts.Languages, err = parseGSMLanguages(*langs); err != nil {
+		color.Red("%v", err)
+		return 2
+	}
+	opts.Transliterate = *translit
+	opts.Graphemes = *graphemes
+	opts.Packing = *packing
+
+	var templates []string
+	switch {
+	case *file != "":
+		f, err := os.Open(*file)
+		if err != nil {
+			color.Red("%v", err)
+			return 1
+		}
+		defer f.Close()
+		sc := bufio.NewScanner(f)
+		for sc.Scan() {
+			if line := sc.Text(); strings.TrimSpace(line) != "" {
+				templates = append(templates, line)
+			}
+		}
+		if err := sc.Err(); err != nil {
+			color.Red("%v", err)
+			return 1
+		}
+	case *text != "":
+		templates = []string{*text}
+	case fs.NArg() > 0:
+		templates = []string{strings.Join(fs.Args(), " ")}
+	default:
+		fs.Usage()
+		return 2
+	}
+
+	status := 0
+	for i, tmpl := range templates {
+		rendered, missing := renderTemplate(tmpl, vars)
+		est, err := EstimateCost(rendered, opts)
+		est.Unresolved = missing
+		if *asJSON {
+			b, _ := json.Marshal(est)
+			fmt.Println(string(b))
+		} else {
+			printCost(i+1, est)
+		}
+		if err != nil {
+			color.Red("  error: %v", err)
+			status = 1
+		}
+	}
+	return status
+}
+
+func printCost(n int, est CostEstimate) {
+	color.Green("Template #%d:", n)
+	fmt.Printf("  text: %q\n", est.Text)
+	fmt.Printf("  encoding: %s (data_coding 0x%02X)\n", est.Encoding, est.DataCoding)
+	if est.Encoding == EncodingGSM7 {
+		fmt.Printf("  shift tables: locking %s, single %s\n", est.LockingShift, est.SingleShift)
+		fmt.Printf("  septets: %d\n", est.Septets)
+	}
+	fmt.Printf("  octets: %d\n", est.Octets)
+	fmt.Printf("  segments: %d (UDH %d octets per segment)\n", est.Segments, est.UDHOctets)
+	if len(est.SplitPoints) > 0 {
+		fmt.Printf("  split points: %v\n", est.SplitPoints)
+	}
+	if len(est.UCS2Chars) > 0 {
+		color.Yellow("  characters forcing UCS2: %s", strings.Join(est.UCS2Chars, " "))
+	}
+	for _, sub := range est.Substituted {
+		fmt.Printf("  transliterated %s\n", sub)
+	}
+	if len(est.Unresolved) > 0 {
+		color.Yellow("  unresolved placeholders: %s", strings.Join(est.Unresolved, ", "))
+	}
+}
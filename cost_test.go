@@ -0,0 +1,74 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestEstimateCost(t *testing.T) {
+	cases := []struct {
+		text      string
+		packing   GSMPacking
+		encoding  MessageEncoding
+		octets    int
+		segments  int
+		ucs2Chars []string
+	}{
+		{strings.Repeat("a", 160), GSMUnpacked, EncodingGSM7, 160, 1, nil},
+		{strings.Repeat("a", 160), GSMPacked, EncodingGSM7, 140, 1, nil},
+		// 153 and 8 septets, each after a 6-octet UDH and one fill bit.
+		{strings.Repeat("a", 161), GSMPacked, EncodingGSM7, 134 + 8, 2, nil},
+		{strings.Repeat("a", 161), GSMUnpacked, EncodingGSM7, 161, 2, nil},
+		// û is outside GSM but within Latin-1: nothing forces UCS2.
+		{"crème brûlée", GSMUnpacked, EncodingLatin1, 12, 1, nil},
+		{"brûlée 中文 中", GSMUnpacked, EncodingUCS2, 22, 1, []string{"中", "文"}},
+		{vietnamese, GSMUnpacked, EncodingUCS2, 104, 1, []string{"Đ", "ừ", "ồ", "ế", "ẫ", "ư", "ễ", "ợ", "ỵ"}},
+	}
+	for _, tc := range cases {
+		est, err := EstimateCost(tc.text, SubmitOptions{Packing: tc.packing})
+		if err != nil {
+			t.Errorf("EstimateCost(%.20q): %v", tc.text, err)
+			continue
+		}
+		if est.Encoding != tc.encoding || est.Octets != tc.octets || est.Segments != tc.segments {
+			t.Errorf("EstimateCost(%.20q, %s) = %s, %d octets, %d segments; want %s, %d, %d", tc.text, tc.packing,
+				est.Encoding, est.Octets, est.Segments, tc.encoding, tc.octets, tc.segments)
+		}
+		if !reflect.DeepEqual(est.UCS2Chars, tc.ucs2Chars) {
+			t.Errorf("EstimateCost(%.20q) UCS2Chars = %q, want %q", tc.text, est.UCS2Chars, tc.ucs2Chars)
+		}
+	}
+}
+
+func TestTestCaseSegments(t *testing.T) {
+	cases := []struct {
+		name       string
+		dataCoding int
+		text       string
+		udhHex     string
+		want       int
+	}{
+		{"gsm single", 0, strings.Repeat("a", 160), "", 1},
+		{"gsm extension", 0, strings.Repeat("€", 80) + "a", "", 2},
+		{"ucs2 single", 8, strings.Repeat("ж", 70), "", 1},
+		{"ucs2 two", 8, strings.Repeat("ж", 71), "", 2},
+		{"latin-1 two", 3, strings.Repeat("é", 141), "", 2},
+		{"own udh", 8, strings.Repeat("ж", 67), "050003010201", 1},
+	}
+	for _, tc := range cases {
+		in := InputPDU{
+			SourceAddr:      strPtr("ACME"),
+			DestinationAddr: strPtr("447700900001"),
+			DataCoding:      intPtr(tc.dataCoding),
+			ShortMessage:    strPtr(tc.text),
+		}
+		if tc.udhHex != "" {
+			in.UDHHex = strPtr(tc.udhHex)
+		}
+		res := validateInput(1, TestCase{InputPdu: in}, ValidationOptions{})
+		if res.Segments != tc.want {
+			t.Errorf("%s: %d segments (errors %q), want %d", tc.name, res.Segments, res.Errors, tc.want)
+		}
+	}
+}
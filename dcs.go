@@ -0,0 +1,346 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/linxGnu/gosmpp/data"
+)
+
+// DCSGroup is the coding group of a data_coding value. Values below 0x10
+// use the SMPP alphabet table; the rest follow 3GPP TS 23.038 section 4.
+type DCSGroup int
+
+const (
+	DCSGroupSMPP         DCSGroup = iota // 0x00-0x0F: SMPP alphabet values
+	DCSGroupGeneral                      // 0x10-0x3F: general data coding
+	DCSGroupAutoDelete                   // 0x40-0x7F: marked for automatic deletion
+	DCSGroupReserved                     // 0x80-0xBF
+	DCSGroupMWIDiscard                   // 0xC0-0xCF: message waiting, discard message
+	DCSGroupMWIStore                     // 0xD0-0xDF: message waiting, store message (GSM 7-bit)
+	DCSGroupMWIStoreUCS2                 // 0xE0-0xEF: message waiting, store message (UCS2)
+	DCSGroupMessageClass                 // 0xF0-0xFF: data coding/message class
+)
+
+func (g DCSGroup) String() string {
+	switch g {
+	case DCSGroupSMPP:
+		return "SMPP"
+	case DCSGroupGeneral:
+		return "general"
+	case DCSGroupAutoDelete:
+		return "automatic deletion"
+	case DCSGroupMWIDiscard:
+		return "MWI discard"
+	case DCSGroupMWIStore:
+		return "MWI store"
+	case DCSGroupMWIStoreUCS2:
+		return "MWI store UCS2"
+	case DCSGroupMessageClass:
+		return "message class"
+	}
+	return "reserved"
+}
+
+// DCSAlphabet is the character set selected by a data_coding value.
+type DCSAlphabet int
+
+const (
+	AlphabetGSM7 DCSAlphabet = iota // SMSC default alphabet
+	Alphabet8Bit
+	AlphabetUCS2
+	AlphabetASCII
+	AlphabetLatin1
+	AlphabetCyrillic
+	AlphabetHebrew
+	AlphabetJIS
+	AlphabetPictogram
+	AlphabetISO2022JP
+	AlphabetKanji
+	AlphabetKSC5601
+	AlphabetReserved
+)
+
+func (a DCSAlphabet) String() string {
+	switch a {
+	case AlphabetGSM7:
+		return "GSM 7-bit"
+	case Alphabet8Bit:
+		return "8-bit binary"
+	case AlphabetUCS2:
+		return "UCS2"
+	case AlphabetASCII:
+		return "IA5/ASCII"
+	case AlphabetLatin1:
+		return "Latin-1"
+	case AlphabetCyrillic:
+		return "Cyrillic"
+	case AlphabetHebrew:
+		return "Latin/Hebrew"
+	case AlphabetJIS:
+		return "JIS"
+	case AlphabetPictogram:
+		return "pictogram"
+	case AlphabetISO2022JP:
+		return "ISO-2022-JP"
+	case AlphabetKanji:
+		return "extended Kanji"
+	case AlphabetKSC5601:
+		return "KS C 5601"
+	}
+	return "reserved"
+}
+
+// smppAlphabets maps SMPP data_coding values 0x00-0x0F to alphabets.
+var smppAlphabets = [16]DCSAlphabet{
+	AlphabetGSM7, AlphabetASCII, Alphabet8Bit, AlphabetLatin1,
+	Alphabet8Bit, AlphabetJIS, AlphabetCyrillic, AlphabetHebrew,
+	AlphabetUCS2, AlphabetPictogram, AlphabetISO2022JP, AlphabetReserved,
+	AlphabetReserved, AlphabetKanji, AlphabetKSC5601, AlphabetReserved,
+}
+
+// MessageClass is the GSM message class. Class 0 is displayed immediately
+// (flash SMS); classes 1-3 are ME-, SIM- and TE-specific.
+type MessageClass int
+
+const (
+	MessageClassNone MessageClass = -1
+	MessageClass0    MessageClass = 0
+	MessageClass1    MessageClass = 1
+	MessageClass2    MessageClass = 2
+	MessageClass3    MessageClass = 3
+)
+
+// MWIType is the kind of message waiting indication.
+type MWIType int
+
+const (
+	MWIVoicemail MWIType = iota
+	MWIFax
+	MWIEmail
+	MWIOther
+)
+
+func (t MWIType) String() string {
+	return [...]string{"voicemail", "fax", "email", "other"}[t&3]
+}
+
+// DataCodingScheme is a decoded data_coding value.
+type DataCodingScheme struct {
+	Group      DCSGroup
+	Alphabet   DCSAlphabet
+	Class      MessageClass
+	Compressed bool
+	// MWIActive and MWIType are set for the message waiting groups.
+	MWIActive bool
+	MWIType   MWIType
+	// Raw is the original value for decoded schemes.
+	Raw byte
+}
+
+// DecodeDCS decodes a data_coding octet.
+func DecodeDCS(b byte) DataCodingScheme {
+	d := DataCodingScheme{Raw: b, Class: MessageClassNone}
+	switch {
+	case b < 0x10:
+		d.Group = DCSGroupSMPP
+		d.Alphabet = smppAlphabets[b]
+	case b < 0x80:
+		d.Group = DCSGroupGeneral
+		if b&0x40 != 0 {
+			d.Group = DCSGroupAutoDelete
+		}
+		d.Compressed = b&0x20 != 0
+		d.Alphabet = [...]DCSAlphabet{AlphabetGSM7, Alphabet8Bit, AlphabetUCS2, AlphabetReserved}[(b>>2)&3]
+		if b&0x10 != 0 {
+			d.Class = MessageClass(b & 3)
+		}
+	case b < 0xC0:
+		d.Group = DCSGroupReserved
+		d.Alphabet = AlphabetReserved
+	case b < 0xF0:
+		d.Group = [...]DCSGroup{DCSGroupMWIDiscard, DCSGroupMWIStore, DCSGroupMWIStoreUCS2}[(b>>4)-0xC]
+		d.Alphabet = AlphabetGSM7
+		if d.Group == DCSGroupMWIStoreUCS2 {
+			d.Alphabet = AlphabetUCS2
+		}
+		d.MWIActive = b&0x08 != 0
+		d.MWIType = MWIType(b & 3)
+	default:
+		d.Group = DCSGroupMessageClass
+		d.Alphabet = AlphabetGSM7
+		if b&0x04 != 0 {
+			d.Alphabet = Alphabet8Bit
+		}
+		d.Class = MessageClass(b & 3)
+	}
+	return d
+}
+
+// Byte encodes d as a data_coding octet.
+func (d DataCodingScheme) Byte() (byte, error) {
+	switch d.Group {
+	case DCSGroupSMPP:
+		for v, a := range smppAlphabets {
+			if a == d.Alphabet && a != AlphabetReserved {
+				return byte(v), nil
+			}
+		}
+		return 0, fmt.Errorf("alphabet %s has no SMPP data_coding value", d.Alphabet)
+	case DCSGroupGeneral, DCSGroupAutoDelete:
+		var b byte
+		switch d.Alphabet {
+		case AlphabetGSM7:
+		case Alphabet8Bit:
+			b = 0x04
+		case AlphabetUCS2:
+			b = 0x08
+		default:
+			return 0, fmt.Errorf("alphabet %s is not allowed in the %s coding group", d.Alphabet, d.Group)
+		}
+		// Without a class or flags this equals the SMPP value for the same alphabet.
+		if d.Class != MessageClassNone {
+			b |= 0x10 | byte(d.Class&3)
+		}
+		if d.Compressed {
+			b |= 0x20
+		}
+		if d.Group == DCSGroupAutoDelete {
+			b |= 0x40
+		}
+		return b, nil
+	case DCSGroupMWIDiscard, DCSGroupMWIStore, DCSGroupMWIStoreUCS2:
+		b := byte(0xC0 + 0x10*(d.Group-DCSGroupMWIDiscard))
+		if d.MWIActive {
+			b |= 0x08
+		}
+		return b | byte(d.MWIType&3), nil
+	case DCSGroupMessageClass:
+		b := byte(0xF0)
+		switch d.Alphabet {
+		case AlphabetGSM7:
+		case Alphabet8Bit:
+			b |= 0x04
+		default:
+			return 0, fmt.Errorf("alphabet %s is not allowed in the %s coding group", d.Alphabet, d.Group)
+		}
+		if d.Class == MessageClassNone {
+			return 0, fmt.Errorf("%s coding group requires a message class", d.Group)
+		}
+		return b | byte(d.Class&3), nil
+	}
+	return 0, fmt.Errorf("cannot encode %s coding group", d.Group)
+}
+
+// Encoding returns the gosmpp encoding for d. Values gosmpp does not know
+// natively are wrapped so the original data_coding octet goes on the wire.
+func (d DataCodingScheme) Encoding() (data.Encoding, error) {
+	if d.Compressed {
+		return nil, fmt.Errorf("compressed data_coding 0x%02X is not supported", d.Raw)
+	}
+	var base data.Encoding
+	switch d.Alphabet {
+	case AlphabetGSM7:
+		base = data.GSM7BIT
+	case Alphabet8Bit:
+		base = data.BINARY8BIT2
+		if d.Raw == data.BINARY8BIT1Coding {
+			base = data.BINARY8BIT1
+		}
+	case AlphabetUCS2:
+		base = data.UCS2
+	case AlphabetASCII:
+		base = data.ASCII
+	case AlphabetLatin1:
+		base = data.LATIN1
+	case AlphabetCyrillic:
+		base = data.CYRILLIC
+	case AlphabetHebrew:
+		base = data.HEBREW
+	default:
+		return nil, fmt.Errorf("data_coding 0x%02X: %s alphabet is not supported", d.Raw, d.Alphabet)
+	}
+	raw, err := d.Byte()
+	if d.Group == DCSGroupSMPP || err != nil {
+		raw = d.Raw
+	}
+	if raw == base.DataCoding() {
+		return base, nil
+	}
+	return data.NewCustomEncoding(raw, base), nil
+}
+
+// IsFlash reports whether the message is class 0.
+func (d DataCodingScheme) IsFlash() bool {
+	return d.Class == MessageClass0
+}
+
+func (d DataCodingScheme) String() string {
+	parts := []string{fmt.Sprintf("0x%02X", d.Raw), d.Group.String(), d.Alphabet.String()}
+	if d.Class != MessageClassNone {
+		parts = append(parts, fmt.Sprintf("class %d", d.Class))
+	}
+	if d.Compressed {
+		parts = append(parts, "compressed")
+	}
+	if d.Group == DCSGroupMWIDiscard || d.Group == DCSGroupMWIStore || d.Group == DCSGroupMWIStoreUCS2 {
+		state := "inactive"
+		if d.MWIActive {
+			state = "active"
+		}
+		parts = append(parts, fmt.Sprintf("%s %s", d.MWIType, state))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// FlashDCS returns a class 0 (flash) scheme for alphabet, which must be
+// GSM 7-bit, 8-bit or UCS2.
+func FlashDCS(alphabet DCSAlphabet) (DataCodingScheme, error) {
+	d := DataCodingScheme{Group: DCSGroupMessageClass, Alphabet: alphabet, Class: MessageClass0}
+	if alphabet == AlphabetUCS2 {
+		d.Group = DCSGroupGeneral
+	}
+	raw, err := d.Byte()
+	d.Raw = raw
+	return d, err
+}
+
+// MWIDCS returns a message waiting indication scheme. With store unset the
+// handset may discard the message text; ucs2 requires store.
+func MWIDCS(kind MWIType, active, store, ucs2 bool) (DataCodingScheme, error) {
+	d := DataCodingScheme{Group: DCSGroupMWIDiscard, Alphabet: AlphabetGSM7, Class: MessageClassNone, MWIActive: active, MWIType: kind}
+	switch {
+	case ucs2 && !store:
+		return d, fmt.Errorf("UCS2 message waiting indication requires the store group")
+	case ucs2:
+		d.Group, d.Alphabet = DCSGroupMWIStoreUCS2, AlphabetUCS2
+	case store:
+		d.Group = DCSGroupMWIStore
+	}
+	raw, err := d.Byte()
+	d.Raw = raw
+	return d, err
+}
+
+// smDataCoding extracts data_coding from a raw deliver_sm or data_sm body.
+func smDataCoding(commandID uint32, body []byte) (byte, bool) {
+	r := newPDUReader(body)
+	r.cstring("service_type")
+	r.octets("source_addr_ton/npi", 2)
+	r.cstring("source_addr")
+	r.octets("dest_addr_ton/npi", 2)
+	r.cstring("destination_addr")
+	switch commandID {
+	case cmdDeliverSM:
+		r.octets("esm_class/protocol_id/priority_flag", 3)
+		r.cstring("schedule_delivery_time")
+		r.cstring("validity_period")
+		r.octets("registered_delivery/replace_if_present_flag", 2)
+	case cmdDataSM:
+		r.octets("esm_class/registered_delivery", 2)
+	default:
+		return 0, false
+	}
+	dc := r.octet("data_coding")
+	return dc, r.err == nil
+}
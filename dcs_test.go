@@ -0,0 +1,168 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/linxGnu/gosmpp/data"
+)
+
+// dcsUnencodable reports whether b selects an alphabet or group that
+// DataCodingScheme.Byte cannot write.
+func dcsUnencodable(b byte) bool {
+	switch {
+	case b < 0x10:
+		return smppAlphabets[b] == AlphabetReserved
+	case b < 0x80:
+		return b&0x0C == 0x0C
+	case b < 0xC0:
+		return true
+	}
+	return false
+}
+
+// dcsCanonical reports whether Byte writes b back unchanged: reserved bits
+// are written as zero and 0x04 is written as 0x02, the first 8-bit value.
+func dcsCanonical(b byte) bool {
+	switch {
+	case b < 0x10:
+		return b != 0x04
+	case b < 0x80:
+		return b&0x10 != 0 || b&0x03 == 0
+	case b < 0xF0:
+		return b&0x04 == 0
+	}
+	return b&0x08 == 0
+}
+
+func TestDCSRoundTrip(t *testing.T) {
+	for i := 0; i < 256; i++ {
+		b := byte(i)
+		d := DecodeDCS(b)
+		got, err := d.Byte()
+		if dcsUnencodable(b) {
+			if err == nil {
+				t.Errorf("0x%02X (%s) encoded as 0x%02X", b, d, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("0x%02X (%s): %v", b, d, err)
+			continue
+		}
+		if dcsCanonical(b) && got != b {
+			t.Errorf("0x%02X (%s) encoded as 0x%02X", b, d, got)
+		}
+		again := DecodeDCS(got)
+		again.Raw = b
+		if again != d {
+			t.Errorf("0x%02X decodes to %+v, but its encoding 0x%02X to %+v", b, d, got, again)
+		}
+	}
+}
+
+func TestDCSFields(t *testing.T) {
+	cases := []struct {
+		b    byte
+		want string
+	}{
+		{0x00, "0x00, SMPP, GSM 7-bit"},
+		{0x08, "0x08, SMPP, UCS2"},
+		{0x11, "0x11, general, GSM 7-bit, class 1"},
+		{0x38, "0x38, general, UCS2, class 0, compressed"},
+		{0x56, "0x56, automatic deletion, 8-bit binary, class 2"},
+		{0x9A, "0x9A, reserved, reserved"},
+		{0xC1, "0xC1, MWI discard, GSM 7-bit, fax inactive"},
+		{0xDA, "0xDA, MWI store, GSM 7-bit, email active"},
+		{0xE8, "0xE8, MWI store UCS2, UCS2, voicemail active"},
+		{0xF5, "0xF5, message class, 8-bit binary, class 1"},
+	}
+	for _, tc := range cases {
+		if got := DecodeDCS(tc.b).String(); got != tc.want {
+			t.Errorf("DecodeDCS(0x%02X) = %s, want %s", tc.b, got, tc.want)
+		}
+	}
+	if !DecodeDCS(0xF0).IsFlash() || !DecodeDCS(0x18).IsFlash() || DecodeDCS(0xF1).IsFlash() || DecodeDCS(0x00).IsFlash() {
+		t.Error("IsFlash does not match class 0")
+	}
+}
+
+func TestDCSEncoding(t *testing.T) {
+	cases := []struct {
+		b    byte
+		base data.Encoding
+	}{
+		{0x00, data.GSM7BIT},
+		{0x03, data.LATIN1},
+		{0x08, data.UCS2},
+		{0x02, data.BINARY8BIT1},
+		{0x04, data.BINARY8BIT2},
+		{0xF0, data.GSM7BIT},
+		{0x18, data.UCS2},
+		{0xD8, data.GSM7BIT},
+	}
+	for _, tc := range cases {
+		enc, err := DecodeDCS(tc.b).Encoding()
+		if err != nil {
+			t.Errorf("0x%02X: %v", tc.b, err)
+			continue
+		}
+		// The original octet goes on the wire, even when gosmpp names it
+		// differently.
+		if enc.DataCoding() != tc.b {
+			t.Errorf("0x%02X: encoding sends data_coding 0x%02X", tc.b, enc.DataCoding())
+		}
+		text := "Hi"
+		if tc.base == data.BINARY8BIT1 || tc.base == data.BINARY8BIT2 {
+			continue
+		}
+		want, _ := tc.base.Encode(text)
+		if got, err := enc.Encode(text); err != nil || string(got) != string(want) {
+			t.Errorf("0x%02X: encodes %q as %X, %v; want %X", tc.b, text, got, err, want)
+		}
+	}
+	for _, b := range []byte{0x05, 0x0B, 0x24, 0x9A} {
+		if _, err := DecodeDCS(b).Encoding(); err == nil {
+			t.Errorf("0x%02X has an encoding", b)
+		}
+	}
+}
+
+func TestFlashAndMWIDCS(t *testing.T) {
+	flash := []struct {
+		alphabet DCSAlphabet
+		want     byte
+	}{
+		{AlphabetGSM7, 0xF0},
+		{Alphabet8Bit, 0xF4},
+		{AlphabetUCS2, 0x18},
+	}
+	for _, tc := range flash {
+		d, err := FlashDCS(tc.alphabet)
+		if err != nil || d.Raw != tc.want || !d.IsFlash() {
+			t.Errorf("FlashDCS(%s) = %s, %v; want 0x%02X", tc.alphabet, d, err, tc.want)
+		}
+	}
+	if _, err := FlashDCS(AlphabetLatin1); err == nil {
+		t.Error("FlashDCS(Latin-1) succeeded")
+	}
+
+	mwi := []struct {
+		kind                MWIType
+		active, store, ucs2 bool
+		want                byte
+	}{
+		{MWIVoicemail, true, false, false, 0xC8},
+		{MWIFax, false, false, false, 0xC1},
+		{MWIEmail, true, true, false, 0xDA},
+		{MWIOther, false, true, true, 0xE3},
+	}
+	for _, tc := range mwi {
+		d, err := MWIDCS(tc.kind, tc.active, tc.store, tc.ucs2)
+		if err != nil || d.Raw != tc.want {
+			t.Errorf("MWIDCS(%s, %v, %v, %v) = %s, %v; want 0x%02X", tc.kind, tc.active, tc.store, tc.ucs2, d, err, tc.want)
+		}
+	}
+	if _, err := MWIDCS(MWIVoicemail, true, false, true); err == nil {
+		t.Error("UCS2 message waiting indication without store succeeded")
+	}
+}
@@ -0,0 +1,248 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/linxGnu/gosmpp"
+	"github.com/linxGnu/gosmpp/pdu"
+)
+
+// EndpointStatus reports the health of a configured endpoint.
+type EndpointStatus struct {
+	Endpoint
+	Active      bool
+	Failures    int
+	LastError   error
+	LastFailure time.Time
+	DownUntil   time.Time
+}
+
+// failoverConnector implements gosmpp.Connector over an ordered list of endpoints.
+// gosmpp calls Connect both for the initial bind and for every rebind, so
+// failing over is simply a matter of picking the next healthy endpoint here.
+type failoverConnector struct {
+	auth     gosmpp.Auth
+	dialer   gosmpp.Dialer
+	version  byte
+	cooldown time.Duration
+
+	// wrap, if set, is applied to every dialed connection before binding.
+	wrap func(net.Conn) net.Conn
+
+	mu     sync.Mutex
+	status []EndpointStatus
+	active int // endpoint currently bound, -1 when none
+	last   int // endpoint most recently bound, kept across disconnects
+	conn   net.Conn
+
+	onSwitch     func(from, to Endpoint)
+	onConnecting func()
+	onBound      func(ep Endpoint, version byte)
+	onBindFailed func(error)
+}
+
+func newFailoverConnector(cfg Config, dialer gosmpp.Dialer) *failoverConnector {
+	f := &failoverConnector{
+		auth: gosmpp.Auth{
+			SystemID:   cfg.SystemID,
+			Password:   cfg.Password,
+			SystemType: cfg.SystemType,
+		},
+		dialer:   dialer,
+		version:  cfg.InterfaceVersion,
+		cooldown: cfg.FailoverCooldown,
+		active:   -1,
+		last:     -1,
+	}
+	for _, ep := range cfg.endpoints() {
+		f.status = append(f.status, EndpointStatus{Endpoint: ep})
+	}
+	return f
+}
+
+// GetBindType implements gosmpp.Connector.
+func (f *failoverConnector) GetBindType() pdu.BindingType {
+	return pdu.Transceiver
+}
+
+// Connect implements gosmpp.Connector. Endpoints are tried in priority order,
+// skipping those still cooling down from a failure unless every endpoint is down.
+func (f *failoverConnector) Connect() (*gosmpp.Connection, error) {
+	if f.onConnecting != nil {
+		f.onConnecting()
+	}
+	var errs []error
+	for _, i := range f.candidates() {
+		ep := f.status[i].Endpoint
+		raw, err := f.dialer(ep.Addr())
+		if err != nil {
+			f.markFailed(i, err)
+			errs = append(errs, fmt.Errorf("%s: %w", ep.Addr(), err))
+			continue
+		}
+		if f.wrap != nil {
+			raw = f.wrap(raw)
+		}
+		conn, version, err := bindConnection(raw, f.GetBindType(), f.auth, f.version)
+		if err != nil {
+			f.markFailed(i, err)
+			errs = append(errs, fmt.Errorf("%s: %w", ep.Addr(), err))
+			continue
+		}
+		f.markActive(i, raw, version)
+		return conn, nil
+	}
+	err := fmt.Errorf("all SMSC endpoints failed: %w", errors.Join(errs...))
+	if f.onBindFailed != nil {
+		f.onBindFailed(err)
+	}
+	return nil, err
+}
+
+// candidates returns endpoint indexes in the order they should be tried.
+func (f *failoverConnector) candidates() []int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	now := time.Now()
+	var healthy, down []int
+	for i, st := range f.status {
+		if now.Before(st.DownUntil) {
+			down = append(down, i)
+		} else {
+			healthy = append(healthy, i)
+		}
+	}
+	return append(healthy, down...)
+}
+
+func (f *failoverConnector) markFailed(i int, err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	st := &f.status[i]
+	st.Failures++
+	st.LastError = err
+	st.LastFailure = time.Now()
+	st.DownUntil = st.LastFailure.Add(f.cooldown)
+	log.Printf("SMSC endpoint %s failed (%d consecutive): %v", st.Addr(), st.Failures, err)
+}
+
+func (f *failoverConnector) markActive(i int, conn net.Conn, version byte) {
+	f.mu.Lock()
+	prev := f.last
+	f.active = i
+	f.last = i
+	f.conn = conn
+	f.status[i].Failures = 0
+	f.status[i].LastError = nil
+	f.status[i].DownUntil = time.Time{}
+	onSwitch := f.onSwitch
+	var from Endpoint
+	if prev >= 0 {
+		from = f.status[prev].Endpoint
+	}
+	to := f.status[i].Endpoint
+	f.mu.Unlock()
+
+	log.Printf("Bound to SMSC endpoint %s (priority %d, SMPP version 0x%02X)", to.Addr(), to.Priority, version)
+	if prev >= 0 && prev != i && onSwitch != nil {
+		onSwitch(from, to)
+	}
+	if f.onBound != nil {
+		f.onBound(to, version)
+	}
+}
+
+// markLost records that the active connection dropped so the next Connect
+// prefers another endpoint for the cooldown period.
+func (f *failoverConnector) markLost(err error) {
+	f.mu.Lock()
+	i := f.active
+	f.active = -1
+	f.conn = nil
+	f.mu.Unlock()
+
+	if i >= 0 {
+		f.markFailed(i, err)
+	}
+}
+
+// Active returns the endpoint currently in use, if any.
+func (f *failoverConnector) Active() (Endpoint, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.active < 0 {
+		return Endpoint{}, false
+	}
+	return f.status[f.active].Endpoint, true
+}
+
+// Status returns a snapshot of every endpoint's health.
+func (f *failoverConnector) Status() []EndpointStatus {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	out := make([]EndpointStatus, len(f.status))
+	copy(out, f.status)
+	if f.active >= 0 {
+		out[f.active].Active = true
+	}
+	return out
+}
+
+// failback periodically probes endpoints with a better priority than the
+// active one. When one answers, the active connection is dropped so that
+// gosmpp rebinds and Connect picks the preferred endpoint again.
+func (f *failoverConnector) failback(interval time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+
+		f.mu.Lock()
+		active, conn := f.active, f.conn
+		var preferred []Endpoint
+		for i := 0; i < active; i++ {
+			if f.status[i].Priority < f.status[active].Priority {
+				preferred = append(preferred, f.status[i].Endpoint)
+			}
+		}
+		f.mu.Unlock()
+
+		for _, ep := range preferred {
+			probe, err := f.dialer(ep.Addr())
+			if err != nil {
+				continue
+			}
+			_ = probe.Close()
+			log.Printf("SMSC endpoint %s is reachable again, failing back", ep.Addr())
+			// Forget the active endpoint first so the resulting close is not
+			// counted against it as a failure.
+			f.mu.Lock()
+			for i := range f.status {
+				if f.status[i].Endpoint == ep {
+					f.status[i].DownUntil = time.Time{}
+				}
+			}
+			f.active = -1
+			f.conn = nil
+			f.mu.Unlock()
+			if conn != nil {
+				_ = conn.Close()
+			}
+			break
+		}
+	}
+}
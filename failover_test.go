@@ -0,0 +1,109 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+// waitFor polls cond until it holds, failing the test after five seconds.
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// connectEndpoints binds a client to the given endpoints.
+func connectEndpoints(t *testing.T, cfg Config, endpoints ...Endpoint) *Client {
+	t.Helper()
+	cfg.Endpoints = endpoints
+	cfg.FailoverCooldown = time.Minute
+	client := NewClient(cfg)
+	if err := client.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = client.Close() })
+	return client
+}
+
+// activeIs reports whether client is bound to m.
+func activeIs(client *Client, m *mockSMSC) func() bool {
+	return func() bool {
+		ep, ok := client.ActiveEndpoint()
+		return ok && ep.Addr() == m.endpoint(0).Addr()
+	}
+}
+
+func TestFailoverPriorityOrder(t *testing.T) {
+	primary, secondary, tertiary := newMockSMSC(t), newMockSMSC(t), newMockSMSC(t)
+	// Listed out of order: priority decides.
+	client := connectEndpoints(t, primary.config(t), tertiary.endpoint(2), primary.endpoint(0), secondary.endpoint(1))
+	waitFor(t, "the bind to the primary", activeIs(client, primary))
+	if n := secondary.bindCount() + tertiary.bindCount(); n != 0 {
+		t.Errorf("lower priority endpoints were bound %d times", n)
+	}
+
+	primary.setRefuse(true)
+	client2 := connectEndpoints(t, primary.config(t), tertiary.endpoint(2), primary.endpoint(0), secondary.endpoint(1))
+	waitFor(t, "the bind to the secondary", activeIs(client2, secondary))
+	if n := tertiary.bindCount(); n != 0 {
+		t.Errorf("the tertiary endpoint was bound %d times", n)
+	}
+}
+
+func TestFailoverCooldown(t *testing.T) {
+	primary, secondary, tertiary := newMockSMSC(t), newMockSMSC(t), newMockSMSC(t)
+	primary.setRefuse(true)
+	client := connectEndpoints(t, primary.config(t), primary.endpoint(0), secondary.endpoint(1), tertiary.endpoint(2))
+	waitFor(t, "the bind to the secondary", activeIs(client, secondary))
+
+	st := client.Endpoints()[0]
+	if st.Failures != 1 || st.LastError == nil || time.Until(st.DownUntil) < 50*time.Second {
+		t.Errorf("failed primary: %d failures, last error %v, down until %s", st.Failures, st.LastError, st.DownUntil)
+	}
+
+	// The primary recovers, but is still cooling down when the secondary
+	// drops: the rebind goes to the tertiary.
+	primary.setRefuse(false)
+	secondary.drop()
+	waitFor(t, "the rebind to the tertiary", activeIs(client, tertiary))
+	if n := primary.bindCount(); n != 0 {
+		t.Errorf("the primary was bound %d times while cooling down", n)
+	}
+}
+
+func TestFailoverMarkLost(t *testing.T) {
+	primary, secondary := newMockSMSC(t), newMockSMSC(t)
+	client := connectEndpoints(t, primary.config(t), primary.endpoint(0), secondary.endpoint(1))
+	waitFor(t, "the bind to the primary", activeIs(client, primary))
+
+	primary.drop()
+	waitFor(t, "the rebind to the secondary", activeIs(client, secondary))
+	st := client.Endpoints()
+	if st[0].Active || st[0].Failures != 1 || st[0].LastError == nil || st[0].DownUntil.IsZero() {
+		t.Errorf("lost primary: %+v", st[0])
+	}
+	if !st[1].Active || st[1].Failures != 0 {
+		t.Errorf("secondary: %+v", st[1])
+	}
+}
+
+func TestFailback(t *testing.T) {
+	primary, secondary := newMockSMSC(t), newMockSMSC(t)
+	primary.setRefuse(true)
+	cfg := primary.config(t)
+	cfg.FailbackInterval = 50 * time.Millisecond
+	client := connectEndpoints(t, cfg, primary.endpoint(0), secondary.endpoint(1))
+	waitFor(t, "the bind to the secondary", activeIs(client, secondary))
+
+	primary.setRefuse(false)
+	waitFor(t, "the fail-back to the primary", activeIs(client, primary))
+	// Failing back is not a failure of the secondary.
+	if st := client.Endpoints()[1]; st.Failures != 0 {
+		t.Errorf("secondary counted %d failures after the fail-back", st.Failures)
+	}
+}
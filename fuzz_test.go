@@ -0,0 +1,602 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// byteSource is a rand.Source reading the fuzz input, so that the
+// coverage-guided engine steers the generator's choices. It returns zeros
+// once the input runs out.
+type byteSource struct{ data []byte }
+
+func (s *byteSource) Uint64() uint64 {
+	var b [8]byte
+	n := copy(b[:], s.data)
+	s.data = s.data[n:]
+	return binary.BigEndian.Uint64(b[:])
+}
+
+func (s *byteSource) Int63() int64 { return int64(s.Uint64() >> 1) }
+func (s *byteSource) Seed(int64)   {}
+
+func newFuzzer(data []byte) *fuzzer {
+	return &fuzzer{rng: rand.New(&byteSource{data: data})}
+}
+
+// seedInputs returns n pseudo-random fuzz inputs for the seed corpora and
+// the property tests.
+func seedInputs(n, size int) [][]byte {
+	rng := rand.New(rand.NewSource(1))
+	out := make([][]byte, n)
+	for i := range out {
+		out[i] = make([]byte, size)
+		rng.Read(out[i])
+	}
+	return out
+}
+
+// fuzzer generates random inputs from a seeded source. wild inputs include
+// out-of-range values and malformed encodings; the others stay mostly
+// valid so that the validator accepts enough of them to compare with the
+// encoder.
+type fuzzer struct {
+	rng  *rand.Rand
+	wild bool
+	// tables are the GSM shift tables the UDH of the current input selects;
+	// GSM text is drawn from them.
+	tables gsmTables
+}
+
+// fuzzExtraRunes are characters outside the GSM default alphabet: Latin-1,
+// typographic punctuation, other scripts, astral characters and controls.
+var fuzzExtraRunes = []rune("áâçœ‘’“”…–—\u00a0\u200bЖжшщשלוםαβγ中文😀👍🏽\x00\x01\x7f\ufffd")
+
+func (f *fuzzer) chance(n int) bool {
+	return f.rng.Intn(n) == 0
+}
+
+// text returns up to max characters drawn from one of several repertoires.
+func (f *fuzzer) text(max int) string {
+	n := f.rng.Intn(max + 1)
+	var pool []rune
+	switch f.rng.Intn(4) {
+	case 0:
+		pool = []rune("abcdefghijklmnopqrstuvwxyz ABCDEFGHIJKLMNOPQRSTUVWXYZ 0123456789.,!?")
+	case 1:
+		pool = gsmRunes(f.tables)
+	case 2:
+		for r := rune(0x20); r <= 0xFF; r++ {
+			if r < 0x7F || r > 0x9F {
+				pool = append(pool, r)
+			}
+		}
+	default:
+		pool = append(gsmRunes(f.tables), fuzzExtraRunes...)
+	}
+	out := make([]rune, n)
+	for i := range out {
+		out[i] = pool[f.rng.Intn(len(pool))]
+	}
+	return string(out)
+}
+
+// gsmRunes returns the characters of the GSM tables t.
+func gsmRunes(t gsmTables) []rune {
+	cs, _ := t.charset()
+	var rs []rune
+	for i, r := range cs.base {
+		if i != gsmEscape {
+			rs = append(rs, r)
+		}
+	}
+	for code := 0; code < 128; code++ {
+		if r, ok := cs.ext[byte(code)]; ok {
+			rs = append(rs, r)
+		}
+	}
+	return rs
+}
+
+// nationalTables returns the default tables or, now and then, a national
+// language locking and/or single shift table.
+func (f *fuzzer) nationalTables() gsmTables {
+	if f.chance(2) {
+		return gsmTables{}
+	}
+	var locking, single []GSMLanguage
+	for lang := range gsmLockingTables {
+		locking = append(locking, lang)
+	}
+	for lang := range gsmSingleShiftTables {
+		single = append(single, lang)
+	}
+	sort.Slice(locking, func(i, j int) bool { return locking[i] < locking[j] })
+	sort.Slice(single, func(i, j int) bool { return single[i] < single[j] })
+	var t gsmTables
+	if f.chance(2) {
+		t.Locking = locking[f.rng.Intn(len(locking))]
+	}
+	if f.chance(2) || t.Locking == GSMDefault {
+		t.Single = single[f.rng.Intn(len(single))]
+	}
+	return t
+}
+
+// octet returns nil, one of common, any octet or, for wild inputs, a value
+// that does not fit in one octet.
+func (f *fuzzer) octet(common ...int) *int {
+	switch f.rng.Intn(8) {
+	case 0:
+		return nil
+	case 1:
+		if f.wild {
+			return intPtr(f.rng.Intn(600) - 200)
+		}
+	case 2:
+		if f.wild {
+			return intPtr(f.rng.Intn(256))
+		}
+	}
+	return intPtr(common[f.rng.Intn(len(common))])
+}
+
+// str returns nil, one of common or, for wild inputs, random text.
+func (f *fuzzer) str(common ...string) *string {
+	switch {
+	case f.chance(6):
+		return nil
+	case f.wild && f.chance(3):
+		return strPtr(f.text(30))
+	}
+	return strPtr(common[f.rng.Intn(len(common))])
+}
+
+func (f *fuzzer) bytes(max int) []byte {
+	b := make([]byte, f.rng.Intn(max+1))
+	f.rng.Read(b)
+	return b
+}
+
+// hexString encodes b, sometimes with spaces and, for wild inputs,
+// sometimes with invalid digits.
+func (f *fuzzer) hexString(b []byte) string {
+	s := strings.ToUpper(hex.EncodeToString(b))
+	if f.chance(4) {
+		s = strings.TrimSpace(regroup(s, 2))
+	}
+	if f.wild && f.chance(4) {
+		s += []string{"Z", "0", " zz"}[f.rng.Intn(3)]
+	}
+	return s
+}
+
+// udh returns a raw UDH carrying the national language shifts of f.tables
+// and concatenation, port addressing or random elements or, for wild
+// inputs, a malformed header.
+func (f *fuzzer) udh() []byte {
+	ies := f.tables.udh()
+	for i := f.rng.Intn(3); i >= 0; i-- {
+		switch f.rng.Intn(4) {
+		case 0:
+			ies = append(ies, concatIEI, 3, byte(f.rng.Intn(256)), 2, 1)
+		case 1:
+			ies = append(ies, 0x05, 4, 0x0B, 0x84, 0x23, 0xF0)
+		default:
+			data := f.bytes(8)
+			ies = append(append(ies, byte(f.rng.Intn(256)), byte(len(data))), data...)
+		}
+	}
+	raw := append([]byte{byte(len(ies))}, ies...)
+	if f.wild && f.chance(3) {
+		return f.mutate(raw)
+	}
+	return raw
+}
+
+// smppTime returns an absolute or relative SMPP time, an RFC 3339
+// timestamp, a Go duration or, for wild inputs, garbage.
+func (f *fuzzer) smppTime() *string {
+	switch f.rng.Intn(7) {
+	case 0, 1:
+		return nil
+	case 2:
+		return strPtr("")
+	case 3:
+		return strPtr(FormatSMPPTime(f.time()))
+	case 4:
+		s, err := FormatSMPPRelative(time.Duration(f.rng.Int63n(int64(maxSMPPRelative))))
+		if err != nil {
+			return nil
+		}
+		return strPtr(s)
+	case 5:
+		return strPtr(f.time().Format(time.RFC3339))
+	}
+	if f.wild {
+		return strPtr(f.text(18))
+	}
+	return strPtr([]string{"90m", "24h", "1h30m"}[f.rng.Intn(3)])
+}
+
+// time returns a time SMPP can represent: 2000-2099 in tenths of a second
+// with a UTC offset in quarter hours.
+func (f *fuzzer) time() time.Time {
+	zone := time.FixedZone("", (f.rng.Intn(105)-48)*900)
+	return time.Date(2000, 1, 2, 0, 0, 0, 0, zone).
+		Add(time.Duration(f.rng.Int63n(int64(98*365*24*time.Hour))) / (100 * time.Millisecond) * (100 * time.Millisecond))
+}
+
+// tlvs returns optional parameters by name or vendor tag with values of
+// every JSON form.
+func (f *fuzzer) tlvs() []TLVSpec {
+	var specs []TLVSpec
+	for i := f.rng.Intn(3); i >= 0; i-- {
+		def := knownTLVs[f.rng.Intn(len(knownTLVs))]
+		s := TLVSpec{Name: def.Name}
+		switch f.rng.Intn(5) {
+		case 0:
+			s.Value = json.RawMessage(strconv.Itoa(f.rng.Intn(70000) - 10))
+		case 1:
+			s.Value, _ = json.Marshal(f.text(20))
+		case 2:
+			s.Hex = f.hexString(f.bytes(8))
+		case 3:
+			tag := TLVTag(0x1400 + f.rng.Intn(0x100))
+			s = TLVSpec{Tag: &tag, Type: tlvOctets}
+			s.Value, _ = json.Marshal(f.text(20))
+		}
+		if def.Type == tlvEmpty && !f.wild {
+			s.Value, s.Hex = nil, ""
+		}
+		specs = append(specs, s)
+	}
+	return specs
+}
+
+// inputPDU returns a random submit_sm test input. Any field may be missing.
+func (f *fuzzer) inputPDU() InputPDU {
+	f.wild = f.chance(2)
+	f.tables = f.nationalTables()
+	in := InputPDU{
+		CommandID:            f.str("submit_sm", "SUBMIT_SM", "deliver_sm"),
+		ServiceType:          f.str("", "CMT", "WAP", "USSD12"),
+		SourceAddrTON:        f.octet(0, 1, 2, 5),
+		SourceAddrNPI:        f.octet(0, 1),
+		SourceAddr:           f.str("ACME", "447700900002", "+447700900002", "ACME Corporation", ""),
+		DestAddrTON:          f.octet(0, 1, 2),
+		DestAddrNPI:          f.octet(0, 1),
+		DestinationAddr:      f.str("447700900001", "07700900001", "+447700900001", "0447700900001", "12345"),
+		EsmClass:             f.octet(0, 0, 0x40, 0x04, 0x08, 0x03),
+		ProtocolID:           f.octet(0, 0x7F),
+		PriorityFlag:         f.octet(0, 1, 3),
+		RegisteredDelivery:   f.octet(0, 1, 2, 0x11),
+		ReplaceIfPresentFlag: f.octet(0, 1),
+		DataCoding:           f.octet(0, 0, 1, 3, 4, 6, 8, 0x10, 0x18, 0xF0, 0xF4),
+		ScheduleDeliveryTime: f.smppTime(),
+		ValidityPeriod:       f.smppTime(),
+	}
+	switch f.rng.Intn(8) {
+	case 0:
+		in.ShortMessageHex = strPtr(f.hexString(f.bytes(200)))
+	case 1:
+		in.ShortMessageBase64 = strPtr(base64.StdEncoding.EncodeToString(f.bytes(200)))
+		if f.wild && f.chance(3) {
+			*in.ShortMessageBase64 += "!"
+		}
+	case 2:
+	default:
+		in.ShortMessage = strPtr(f.text(200))
+	}
+	if f.chance(4) {
+		in.Transliterate = new(bool)
+		*in.Transliterate = f.chance(2)
+	}
+	if f.tables != (gsmTables{}) || f.chance(4) {
+		in.UDHHex = strPtr(f.hexString(f.udh()))
+	}
+	if f.chance(8) {
+		in.UDHDestPort = intPtr(f.rng.Intn(0x10000))
+		if f.wild {
+			in.UDHDestPort = intPtr(f.rng.Intn(0x20000) - 0x8000)
+		}
+		in.UDHSourcePort = f.octet(0, 9200, 0xC002)
+	}
+	if f.chance(5) {
+		in.TLVs = f.tlvs()
+	}
+	// A declared sm_length is usually the computed one, so that it does
+	// not mask the other checks.
+	if f.chance(3) {
+		dc := 0
+		if in.DataCoding != nil {
+			dc = *in.DataCoding
+		}
+		udh, _ := testCaseUDH(in)
+		n, _ := shortMessageOctets(in, dc, udh, GSMUnpacked)
+		if f.wild && f.chance(2) {
+			n += f.rng.Intn(5) - 2
+		}
+		in.SmLength = intPtr(n)
+	}
+	return in
+}
+
+// mutate flips, drops, inserts or overwrites octets of b, or truncates it.
+func (f *fuzzer) mutate(b []byte) []byte {
+	out := append([]byte(nil), b...)
+	for i := f.rng.Intn(4); i >= 0; i-- {
+		if len(out) == 0 {
+			return f.bytes(16)
+		}
+		pos := f.rng.Intn(len(out))
+		switch f.rng.Intn(5) {
+		case 0:
+			out[pos] ^= 1 << uint(f.rng.Intn(8))
+		case 1:
+			out = append(out[:pos], out[pos+1:]...)
+		case 2:
+			out = append(out[:pos], append([]byte{byte(f.rng.Intn(256))}, out[pos:]...)...)
+		case 3:
+			out[pos] = []byte{0x00, 0xFF, 0x7F, 0x80}[f.rng.Intn(4)]
+		default:
+			out = out[:pos]
+		}
+	}
+	return out
+}
+
+// checkSubmitSM validates and builds a generated input. Neither may panic
+// whatever is missing or out of range; when the validator accepts the
+// input, the built PDU must encode to the computed sm_length and survive
+// an encode/decode round-trip. It reports whether the input was accepted
+// and compared with the encoder.
+func checkSubmitSM(in InputPDU, packing GSMPacking) (bool, error) {
+	opts := ValidationOptions{Packing: packing}
+	res := validateTestCase(1, TestCase{TestCaseId: 1, InputPdu: in}, opts)
+	sm, _, buildErr := buildSubmitSM(in, packing, nil)
+	if !res.Valid {
+		return false, nil
+	}
+	dataCoding := 0
+	if in.DataCoding != nil {
+		dataCoding = *in.DataCoding
+	}
+	if _, err := byteToDataCoding(byte(dataCoding)); err != nil {
+		// An alphabet the runner cannot send.
+		return false, nil
+	}
+	if buildErr != nil {
+		return true, fmt.Errorf("validator accepts the input but building it fails: %v", buildErr)
+	}
+	raw, err := rawSubmitSMOf(sm)
+	if err != nil {
+		return true, fmt.Errorf("reading the built short_message: %v", err)
+	}
+	if len(raw.ShortMessage) != res.ComputedSmLength {
+		return true, fmt.Errorf("validator computes sm_length %d but the encoder sends %d octets (%X)",
+			res.ComputedSmLength, len(raw.ShortMessage), raw.ShortMessage)
+	}
+	if raw.DataCoding != byte(dataCoding) {
+		return true, fmt.Errorf("data_coding 0x%02X is sent as 0x%02X", dataCoding, raw.DataCoding)
+	}
+	if in.ServiceType != nil && raw.ServiceType != *in.ServiceType {
+		return true, fmt.Errorf("service_type %q is sent as %q", *in.ServiceType, raw.ServiceType)
+	}
+	if in.PriorityFlag != nil && int(raw.PriorityFlag) != *in.PriorityFlag {
+		return true, fmt.Errorf("priority_flag %d is sent as %d", *in.PriorityFlag, raw.PriorityFlag)
+	}
+	frame, err := raw.bytes(1)
+	if err != nil {
+		return true, fmt.Errorf("validator accepts the input but it cannot be encoded: %v", err)
+	}
+	decoded, err := decodeSubmitSM(frame[pduHeaderLen:])
+	if err != nil {
+		return true, fmt.Errorf("decoding the encoded PDU: %v", err)
+	}
+	if again, _ := decoded.bytes(1); !bytes.Equal(again, frame) {
+		return true, fmt.Errorf("encode/decode round-trip changed the PDU:\n  %X\n  %X", frame, again)
+	}
+	return true, nil
+}
+
+func FuzzSubmitSM(f *testing.F) {
+	for i, seed := range seedInputs(64, 2048) {
+		f.Add(seed, i%2 == 1)
+	}
+	f.Fuzz(func(t *testing.T, data []byte, packed bool) {
+		in := newFuzzer(data).inputPDU()
+		packing := GSMUnpacked
+		if packed {
+			packing = GSMPacked
+		}
+		if _, err := checkSubmitSM(in, packing); err != nil {
+			b, _ := json.Marshal(in)
+			t.Fatalf("%v\ninput (%s packing): %s", err, packing, b)
+		}
+	})
+}
+
+// TestSubmitSMProperty runs the submit_sm property over generated inputs
+// and checks that enough of them reach the encoder comparison.
+func TestSubmitSMProperty(t *testing.T) {
+	accepted := 0
+	for i, seed := range seedInputs(5000, 2048) {
+		packing := []GSMPacking{GSMUnpacked, GSMPacked}[i%2]
+		in := newFuzzer(seed).inputPDU()
+		ok, err := checkSubmitSM(in, packing)
+		if err != nil {
+			b, _ := json.Marshal(in)
+			t.Fatalf("seed %d: %v\ninput (%s packing): %s", i, err, packing, b)
+		}
+		if ok {
+			accepted++
+		}
+	}
+	if accepted < 100 {
+		t.Errorf("only %d generated inputs were accepted by the validator", accepted)
+	}
+}
+
+// FuzzGSM7 round-trips GSM text through the codec and septets through
+// packing; arbitrary septets must decode without panicking.
+func FuzzGSM7(f *testing.F) {
+	for _, s := range []string{"", "Hello world", "€[]{}|^~\\", "@£$¥èéùìòÇØøÅåΔ_ΦΓΛΩΠΨΣΘΞ", "Привет", "😀"} {
+		f.Add(s, uint8(0), []byte{0x1B})
+	}
+	f.Add("abcdefg", uint8(6), []byte{0x1B, 0x65, 0x1B})
+	f.Fuzz(func(t *testing.T, text string, fill uint8, raw []byte) {
+		_, _ = gsmTables{}.decode(raw)
+		septets, err := gsmTables{}.encode(text)
+		if err != nil {
+			if _, err := (gsmTables{}).septetCount(text); err == nil {
+				t.Fatalf("septetCount accepts %q, which encode rejects", text)
+			}
+			return
+		}
+		if n, err := (gsmTables{}).septetCount(text); err != nil || n != len(septets) {
+			t.Fatalf("septetCount(%q) = %d, %v; encoded %d septets", text, n, err, len(septets))
+		}
+		if got, _ := (gsmTables{}).decode(septets); got != text {
+			t.Fatalf("decode(encode(%q)) = %q", text, got)
+		}
+		pad := int(fill % 7)
+		if got := unpackSeptets(packSeptets(septets, pad), pad, len(septets)); !bytes.Equal(got, septets) {
+			t.Fatalf("unpack(pack(%X, %d)) = %X", septets, pad, got)
+		}
+	})
+}
+
+// FuzzDataCoding re-encodes a decoded data_coding octet; the result must
+// decode to the same scheme.
+func FuzzDataCoding(f *testing.F) {
+	for _, b := range []byte{0x00, 0x01, 0x03, 0x08, 0x10, 0x18, 0xC8, 0xD0, 0xE8, 0xF0, 0xF4} {
+		f.Add(b)
+	}
+	f.Fuzz(func(t *testing.T, b byte) {
+		d := DecodeDCS(b)
+		out, err := d.Byte()
+		if err != nil {
+			return
+		}
+		got := DecodeDCS(out)
+		got.Raw = d.Raw
+		if got != d {
+			t.Fatalf("0x%02X (%s) re-encodes as 0x%02X (%s)", b, d, out, DecodeDCS(out))
+		}
+	})
+}
+
+// FuzzSMPPTime formats absolute times and relative durations and parses
+// them back; arbitrary strings must parse or fail without panicking.
+func FuzzSMPPTime(f *testing.F) {
+	f.Add("240305143015700+", int64(0), int8(0), int64(1))
+	f.Add("000000013000000R", int64(1e9), int8(-20), int64(5400))
+	f.Add("000100000000000R", int64(3e9), int8(48), int64(100*86400))
+	f.Add("2024-03-05T14:30:15Z", int64(-5), int8(60), int64(99*86400+86399))
+	f.Fuzz(func(t *testing.T, s string, at int64, quarters int8, relative int64) {
+		now := time.Date(2024, time.January, 31, 12, 0, 0, 0, time.UTC)
+		if p, err := ParseSMPPTime(s, now); err == nil && len(s) == smppTimeLen && s[15] != 'R' {
+			if again := FormatSMPPTime(p); again != s && !strings.HasSuffix(s, "00+") && !strings.HasSuffix(s, "00-") {
+				// Only the sign of a zero offset may change.
+				t.Fatalf("%q parses as %s and formats as %q", s, p, again)
+			}
+		}
+		_, _ = normalizeSMPPTime(s)
+
+		// An absolute time SMPP can hold: 2000-2099 in tenths of a
+		// second, with an offset of at most 48 quarter hours.
+		span := int64(98 * 365 * 24 * 10)
+		tenths := (at%span + span) % span
+		q := int(quarters) % 49
+		abs := time.Date(2000, 1, 2, 0, 0, 0, 0, time.FixedZone("", q*900)).
+			Add(time.Duration(tenths) * 100 * time.Millisecond)
+		if got, err := ParseSMPPTime(FormatSMPPTime(abs), now); err != nil || !got.Equal(abs) {
+			t.Fatalf("%s formats as %q, which parses as %s, %v", abs, FormatSMPPTime(abs), got, err)
+		}
+
+		d := time.Duration(relative) * time.Second
+		if relative < 0 || relative > int64(maxSMPPRelative/time.Second) {
+			return
+		}
+		rel, err := FormatSMPPRelative(d)
+		if err != nil {
+			if d >= time.Second && d < maxSMPPRelative {
+				t.Fatalf("FormatSMPPRelative(%s): %v", d, err)
+			}
+			return
+		}
+		if got, err := ParseSMPPTime(rel, now); err != nil || got.Sub(now) != d {
+			t.Fatalf("%s formats as %q, which parses as %s later, %v", d, rel, got.Sub(now), err)
+		}
+	})
+}
+
+// FuzzRawPDU feeds arbitrary frames to the raw decoders and the pdu
+// codec, which must fail cleanly; a submit_sm that decodes must re-encode
+// to the same octets.
+func FuzzRawPDU(f *testing.F) {
+	for _, h := range []string{
+		"0000004b000000040000000000000001434d540001013132333435000101343437373030393030303030000000010000010000001654657374206d6573736167652066726f6d20534d4343",
+		"0000001b8000000400000000000000013132333435363738393000",
+		"00000010800000000000000300000002",
+	} {
+		b, _ := hex.DecodeString(h)
+		f.Add(b)
+	}
+	for _, seed := range seedInputs(32, 128) {
+		fz := newFuzzer(seed)
+		raw := rawSubmitSM{
+			ServiceType:     "CMT",
+			SourceAddrTON:   5,
+			SourceAddr:      "ACME",
+			DestAddrTON:     1,
+			DestAddrNPI:     1,
+			DestinationAddr: "447700900001",
+			EsmClass:        esmUDHI,
+			DataCoding:      8,
+			ShortMessage:    append(fz.udh(), fz.bytes(40)...),
+			TLVs:            []rawTLV{{Tag: tagUserMessageReference, Value: fz.bytes(2)}},
+		}
+		if b, err := raw.bytes(uint32(fz.rng.Int31())); err == nil {
+			f.Add(fz.mutate(b))
+		}
+	}
+	f.Fuzz(func(t *testing.T, frame []byte) {
+		_, _ = DecodePDU(frame, GSMUnpacked)
+		_, _ = DecodePDU(frame, GSMPacked)
+		hdr, err := parsePDUHeader(frame)
+		if err != nil {
+			return
+		}
+		body := frame[pduHeaderLen:]
+		smDataCoding(cmdDeliverSM, body)
+		smDataCoding(cmdDataSM, body)
+		_, _ = parseUDH(body)
+		newPDUReader(body).tlvs()
+		if hdr.CommandID != cmdSubmitSM {
+			return
+		}
+		s, err := decodeSubmitSM(body)
+		if err != nil {
+			return
+		}
+		again, err := s.bytes(hdr.Sequence)
+		if err != nil {
+			t.Fatalf("re-encoding a decoded PDU: %v", err)
+		}
+		if !bytes.Equal(again[pduHeaderLen:], body) {
+			t.Fatalf("%X decodes and re-encodes as %X", frame, again)
+		}
+	})
+}
@@ -0,0 +1,334 @@
+package main
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"math/rand"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/fatih/color"
+)
+
+// MatrixAddress is an address value in a test matrix.
+type MatrixAddress struct {
+	TON  int    `json:"ton"`
+	NPI  int    `json:"npi"`
+	Addr string `json:"addr"`
+}
+
+// TestMatrix declares the values to combine into generated test cases.
+// Lengths are in characters; when empty, boundary lengths for each
+// data_coding are used (empty, one character, the single-segment limit and
+// the short_message limit, each with its successor).
+type TestMatrix struct {
+	Seed               int64           `json:"seed"`
+	Pairwise           bool            `json:"pairwise"`
+	FirstID            int             `json:"first_id,omitempty"`
+	DataCoding         []int           `json:"data_coding"`
+	Destinations       []MatrixAddress `json:"destinations"`
+	Sources            []MatrixAddress `json:"sources"`
+	Lengths            []int           `json:"lengths,omitempty"`
+	EsmClass           []int           `json:"esm_class"`
+	RegisteredDelivery []int           `json:"registered_delivery"`
+}
+
+// defaultMatrix covers the common encodings, valid and invalid addresses,
+// UDHI on and off, a receipt-only esm_class and the reserved receipt value.
+var defaultMatrix = TestMatrix{
+	Seed:       1,
+	Pairwise:   true,
+	DataCoding: []int{0, 8, 3},
+	Destinations: []MatrixAddress{
+		{TON: 1, NPI: 1, Addr: "447700900001"},
+		{TON: 2, NPI: 1, Addr: "07700900001"},
+		{TON: 3, NPI: 0, Addr: "12345"},
+		{TON: 1, NPI: 1, Addr: "0447700900001"},
+		{TON: 7, NPI: 1, Addr: "447700900001"},
+	},
+	Sources: []MatrixAddress{
+		{TON: 5, NPI: 0, Addr: "ACME"},
+		{TON: 1, NPI: 1, Addr: "447700900002"},
+		{TON: 5, NPI: 0, Addr: "ACME Corporation"},
+	},
+	EsmClass:           []int{0, 0x40, 0x04},
+	RegisteredDelivery: []int{0, 1, 3},
+}
+
+// boundaryLengths returns the boundary message lengths in characters for a
+// data_coding.
+func boundaryLengths(dataCoding int) []int {
+	switch DecodeDCS(byte(dataCoding)).Alphabet {
+	case AlphabetGSM7:
+		return []int{0, 1, 160, 161, 254, 255}
+	case AlphabetUCS2:
+		return []int{0, 1, 70, 71, 127, 128}
+	}
+	return []int{0, 1, 140, 141, 254, 255}
+}
+
+// fillerText returns n characters of sample text for dataCoding.
+func fillerText(dataCoding, n int) string {
+	sample := "The quick brown fox jumps over the lazy dog 0123456789. "
+	switch DecodeDCS(byte(dataCoding)).Alphabet {
+	case AlphabetUCS2:
+		sample = "Привет, мир! Γειά σου κόσμε. "
+	case AlphabetLatin1:
+		sample = "Café crème, déjà vu à Zürich. "
+	}
+	runes := []rune(sample)
+	out := make([]rune, n)
+	for i := range out {
+		out[i] = runes[i%len(runes)]
+	}
+	return string(out)
+}
+
+// cartesian returns every combination of indices for dimensions of sizes.
+func cartesian(sizes []int) [][]int {
+	rows := [][]int{{}}
+	for _, n := range sizes {
+		var next [][]int
+		for _, row := range rows {
+			for v := 0; v < n; v++ {
+				next = append(next, append(append([]int(nil), row...), v))
+			}
+		}
+		rows = next
+	}
+	return rows
+}
+
+// pairwise returns rows of indices covering every pair of values of every
+// two dimensions, built greedily: each row starts from an uncovered pair
+// and fills the other dimensions with the values covering most new pairs.
+// rng breaks ties so a seed reproduces the same rows.
+func pairwise(sizes []int, rng *rand.Rand) [][]int {
+	if len(sizes) < 2 {
+		return cartesian(sizes)
+	}
+	type pair struct{ i, vi, j, vj int }
+	uncovered := map[pair]bool{}
+	for i := range sizes {
+		for j := i + 1; j < len(sizes); j++ {
+			for vi := 0; vi < sizes[i]; vi++ {
+				for vj := 0; vj < sizes[j]; vj++ {
+					uncovered[pair{i, vi, j, vj}] = true
+				}
+			}
+		}
+	}
+	covers := func(row []int, set []bool) int {
+		n := 0
+		for i := range row {
+			for j := i + 1; j < len(row); j++ {
+				if set[i] && set[j] && uncovered[pair{i, row[i], j, row[j]}] {
+					n++
+				}
+			}
+		}
+		return n
+	}
+
+	var rows [][]int
+	for len(uncovered) > 0 {
+		pending := make([]pair, 0, len(uncovered))
+		for p := range uncovered {
+			pending = append(pending, p)
+		}
+		sort.Slice(pending, func(a, b int) bool {
+			x, y := pending[a], pending[b]
+			if x.i != y.i {
+				return x.i < y.i
+			}
+			if x.j != y.j {
+				return x.j < y.j
+			}
+			if x.vi != y.vi {
+				return x.vi < y.vi
+			}
+			return x.vj < y.vj
+		})
+		start := pending[0]
+
+		var best []int
+		bestScore := -1
+		for try := 0; try < 20; try++ {
+			row := make([]int, len(sizes))
+			set := make([]bool, len(sizes))
+			row[start.i], row[start.j] = start.vi, start.vj
+			set[start.i], set[start.j] = true, true
+			for _, d := range rng.Perm(len(sizes)) {
+				if set[d] {
+					continue
+				}
+				set[d] = true
+				bestV, bestN := 0, -1
+				for _, v := range rng.Perm(sizes[d]) {
+					row[d] = v
+					if n := covers(row, set); n > bestN {
+						bestV, bestN = v, n
+					}
+				}
+				row[d] = bestV
+			}
+			if score := covers(row, set); score > bestScore {
+				best, bestScore = row, score
+			}
+		}
+		for i := range best {
+			for j := i + 1; j < len(best); j++ {
+				delete(uncovered, pair{i, best[i], j, best[j]})
+			}
+		}
+		rows = append(rows, best)
+	}
+	return rows
+}
+
+// GenerateTestCases builds test cases from m. sm_length and the expected
+// response are computed with the same rules as validateTestCase.
+func GenerateTestCases(m TestMatrix, opts ValidationOptions) ([]TestCase, error) {
+	lengthCount := len(m.Lengths)
+	if lengthCount == 0 {
+		lengthCount = len(boundaryLengths(0))
+	}
+	sizes := []int{len(m.DataCoding), len(m.Destinations), len(m.Sources), lengthCount, len(m.EsmClass), len(m.RegisteredDelivery)}
+	for i, n := range sizes {
+		if n == 0 {
+			return nil, fmt.Errorf("matrix dimension %s is empty",
+				[]string{"data_coding", "destinations", "sources", "lengths", "esm_class", "registered_delivery"}[i])
+		}
+	}
+	rows := cartesian(sizes)
+	if m.Pairwise {
+		rows = pairwise(sizes, rand.New(rand.NewSource(m.Seed)))
+	}
+
+	id := m.FirstID
+	if id == 0 {
+		id = 1
+	}
+	tests := make([]TestCase, 0, len(rows))
+	for n, row := range rows {
+		dc := m.DataCoding[row[0]]
+		dest, src := m.Destinations[row[1]], m.Sources[row[2]]
+		length := 0
+		if len(m.Lengths) > 0 {
+			length = m.Lengths[row[3]]
+		} else {
+			length = boundaryLengths(dc)[row[3]]
+		}
+		esm, rd := m.EsmClass[row[4]], m.RegisteredDelivery[row[5]]
+
+		zero := 0
+		in := InputPDU{
+			CommandID:            strPtr("submit_sm"),
+			ServiceType:          strPtr(""),
+			SourceAddrTON:        intPtr(src.TON),
+			SourceAddrNPI:        intPtr(src.NPI),
+			SourceAddr:           strPtr(src.Addr),
+			DestAddrTON:          intPtr(dest.TON),
+			DestAddrNPI:          intPtr(dest.NPI),
+			DestinationAddr:      strPtr(dest.Addr),
+			EsmClass:             intPtr(esm),
+			ProtocolID:           &zero,
+			PriorityFlag:         &zero,
+			RegisteredDelivery:   intPtr(rd),
+			ReplaceIfPresentFlag: &zero,
+			DataCoding:           intPtr(dc),
+			Encoding:             strPtr(encodingLabel(dc)),
+			ShortMessage:         strPtr(fillerText(dc, length)),
+		}
+		if esm&esmUDHI != 0 {
+			// A single-part concatenation header keeps UDHI consistent.
+			in.UDHHex = strPtr(strings.ToUpper(hex.EncodeToString([]byte{5, concatIEI, 3, byte(n), 1, 1})))
+		}
+		udh, err := testCaseUDH(in)
+		if err != nil {
+			return nil, err
+		}
+		if octets, ok := shortMessageOctets(in, dc, udh, opts.Packing); ok {
+			in.SmLength = intPtr(octets)
+		}
+
+		tc := TestCase{TestCaseId: id + n, InputPdu: in}
+		res := validateInput(n+1, tc, opts)
+		if res.TestDataError {
+			return nil, fmt.Errorf("test case %d: %s", tc.TestCaseId, strings.Join(res.Errors, "; "))
+		}
+		tc.ExpectedOutput = ExpectedOutput{
+			CommandID:      strPtr(predictedResponse(res.PredictedStatus)),
+			CommandStatus:  intPtr(int(res.PredictedStatus)),
+			DeliveryStatus: strPtr("accepted"),
+		}
+		if res.PredictedStatus != ESME_ROK {
+			tc.ExpectedOutput.DeliveryStatus = strPtr("failed")
+		}
+		tests = append(tests, tc)
+	}
+	return tests, nil
+}
+
+func strPtr(s string) *string { return &s }
+func intPtr(n int) *int       { return &n }
+
+// runGenerate implements the "generate" subcommand.
+func runGenerate(args []string) int {
+	fs := flag.NewFlagSet("generate", flag.ExitOnError)
+	matrixFile := fs.String("matrix", "", "JSON test matrix (default: built-in matrix)")
+	seed := fs.Int64("seed", 0, "override the matrix seed")
+	full := fs.Bool("full", false, "generate every combination instead of pairwise")
+	out := fs.String("out", "", "output file (default: stdout)")
+	jsonl := fs.Bool("jsonl", false, "write one test case per line instead of a JSON array")
+	packing := packingFlag(fs)
+	_ = fs.Parse(args)
+
+	m := defaultMatrix
+	if *matrixFile != "" {
+		b, err := os.ReadFile(*matrixFile)
+		if err != nil {
+			color.Red("%v", err)
+			return 1
+		}
+		m = TestMatrix{}
+		if err := json.Unmarshal(b, &m); err != nil {
+			color.Red("%s: %v", *matrixFile, err)
+			return 1
+		}
+	}
+	if *seed != 0 {
+		m.Seed = *seed
+	}
+	if *full {
+		m.Pairwise = false
+	}
+	tests, err := GenerateTestCases(m, ValidationOptions{Packing: *packing})
+	if err != nil {
+		color.Red("%v", err)
+		return 1
+	}
+	var b []byte
+	if *jsonl {
+		for _, tc := range tests {
+			line, _ := json.Marshal(tc)
+			b = append(append(b, line...), '\n')
+		}
+	} else {
+		b, _ = json.MarshalIndent(tests, "", "  ")
+		b = append(b, '\n')
+	}
+	if *out == "" {
+		fmt.Print(string(b))
+		return 0
+	}
+	if err := os.WriteFile(*out, b, 0o644); err != nil {
+		color.Red("%v", err)
+		return 1
+	}
+	color.Green("wrote %d test case(s) to %s", len(tests), *out)
+	return 0
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"encoding/json"
+	"math/rand"
+	"testing"
+)
+
+func TestPairwiseCoverage(t *testing.T) {
+	for _, sizes := range [][]int{{3, 5, 3, 6, 3, 3}, {2, 2}, {4, 1, 3}, {7, 2, 5, 3}} {
+		rows := pairwise(sizes, rand.New(rand.NewSource(1)))
+		full := 1
+		for _, n := range sizes {
+			full *= n
+		}
+		if len(rows) > full {
+			t.Errorf("%v: %d pairwise rows exceed the %d combinations", sizes, len(rows), full)
+		}
+		for i := range sizes {
+			for j := i + 1; j < len(sizes); j++ {
+				for vi := 0; vi < sizes[i]; vi++ {
+					for vj := 0; vj < sizes[j]; vj++ {
+						covered := false
+						for _, row := range rows {
+							if row[i] == vi && row[j] == vj {
+								covered = true
+								break
+							}
+						}
+						if !covered {
+							t.Errorf("%v: no row has value %d in dimension %d and %d in dimension %d", sizes, vi, i, vj, j)
+						}
+					}
+				}
+			}
+		}
+	}
+	if rows := pairwise([]int{3, 5, 3, 6, 3, 3}, rand.New(rand.NewSource(1))); len(rows) > 40 {
+		t.Errorf("default matrix needs %d pairwise rows, want far fewer than the 2430 combinations", len(rows))
+	}
+}
+
+func TestGenerateReproducible(t *testing.T) {
+	generate := func(seed int64) string {
+		m := defaultMatrix
+		m.Seed = seed
+		tests, err := GenerateTestCases(m, ValidationOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, _ := json.Marshal(tests)
+		return string(b)
+	}
+	if generate(7) != generate(7) {
+		t.Error("the same seed generated different test cases")
+	}
+	if generate(1) != generate(defaultMatrix.Seed) {
+		t.Error("the default seed is not 1")
+	}
+}
+
+func TestGenerateLengthsAndStatus(t *testing.T) {
+	m := TestMatrix{
+		DataCoding:         []int{0, 8},
+		Destinations:       []MatrixAddress{{TON: 1, NPI: 1, Addr: "447700900001"}, {TON: 7, NPI: 1, Addr: "447700900001"}},
+		Sources:            []MatrixAddress{{TON: 5, NPI: 0, Addr: "ACME"}},
+		Lengths:            []int{0, 1, 160, 255},
+		EsmClass:           []int{0, 0x40, 0x04},
+		RegisteredDelivery: []int{1, 3},
+		FirstID:            100,
+	}
+	for _, packing := range []GSMPacking{GSMUnpacked, GSMPacked} {
+		tests, err := GenerateTestCases(m, ValidationOptions{Packing: packing})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(tests) != 2*2*4*3*2 {
+			t.Fatalf("full matrix generated %d test cases", len(tests))
+		}
+		for n, tc := range tests {
+			in := tc.InputPdu
+			if tc.TestCaseId != 100+n {
+				t.Errorf("test case %d has id %d", n, tc.TestCaseId)
+			}
+			chars := len([]rune(*in.ShortMessage))
+			udh := 0
+			if *in.EsmClass&esmUDHI != 0 {
+				udh = 6
+			}
+
+			// The fillers are plain GSM and BMP text: one septet or two
+			// UCS2 octets per character.
+			want := udh + 2*chars
+			if *in.DataCoding == 0 {
+				want = udh + chars
+				if packing == GSMPacked {
+					fill := (7 - udh*8%7) % 7
+					want = udh + (fill+7*chars+7)/8
+				}
+			}
+			if in.SmLength == nil || *in.SmLength != want {
+				t.Errorf("test case %d (%s): sm_length %v, want %d", tc.TestCaseId, packing, in.SmLength, want)
+			}
+
+			status := ESME_ROK
+			switch {
+			case *in.DestAddrTON == 7:
+				status = ESME_RINVDSTTON
+			case *in.EsmClass == 0x04:
+				status = ESME_RINVESMCLASS
+			case *in.RegisteredDelivery == 3:
+				status = ESME_RINVREGDLVFLG
+			case want > maxShortMessageLen:
+				status = ESME_RINVMSGLEN
+			}
+			exp := tc.ExpectedOutput
+			if CommandStatus(*exp.CommandStatus) != status || *exp.CommandID != "submit_sm_resp" {
+				t.Errorf("test case %d: expects %s %s, want %s", tc.TestCaseId, *exp.CommandID, CommandStatus(*exp.CommandStatus), status)
+			}
+			if delivery := *exp.DeliveryStatus; (delivery == "accepted") != (status == ESME_ROK) {
+				t.Errorf("test case %d: delivery_status %q for %s", tc.TestCaseId, delivery, status)
+			}
+		}
+	}
+
+	m.Sources = nil
+	if _, err := GenerateTestCases(m, ValidationOptions{}); err == nil {
+		t.Error("a matrix without sources generated test cases")
+	}
+}
@@ -0,0 +1,488 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/linxGnu/gosmpp/data"
+	"github.com/linxGnu/gosmpp/pdu"
+)
+
+// GSMLanguage identifies a national language shift table (3GPP TS 23.038 section 6.2.1.2.4).
+// GSMDefault selects the default alphabet or default extension table.
+// Only the Turkish, Spanish, Portuguese and Hindi tables are included;
+// text for the other languages, such as Bengali or Tamil, cannot be encoded.
+type GSMLanguage byte
+
+const (
+	GSMDefault    GSMLanguage = 0
+	GSMTurkish    GSMLanguage = 1
+	GSMSpanish    GSMLanguage = 2
+	GSMPortuguese GSMLanguage = 3
+	GSMHindi      GSMLanguage = 6
+)
+
+func (l GSMLanguage) String() string {
+	switch l {
+	case GSMDefault:
+		return "default"
+	case GSMTurkish:
+		return "Turkish"
+	case GSMSpanish:
+		return "Spanish"
+	case GSMPortuguese:
+		return "Portuguese"
+	case GSMHindi:
+		return "Hindi"
+	}
+	return fmt.Sprintf("language %d", byte(l))
+}
+
+// parseGSMLanguages parses a comma-separated list of language names.
+func parseGSMLanguages(s string) ([]GSMLanguage, error) {
+	var langs []GSMLanguage
+	for _, name := range strings.Split(s, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		found := false
+		for _, l := range []GSMLanguage{GSMTurkish, GSMSpanish, GSMPortuguese, GSMHindi} {
+			if strings.EqualFold(name, l.String()) {
+				langs = append(langs, l)
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("unknown GSM national language %q", name)
+		}
+	}
+	return langs, nil
+}
+
+// UDH information element identifiers for national language shift tables.
+const (
+	ieiNationalSingleShift  byte = 0x24
+	ieiNationalLockingShift byte = 0x25
+)
+
+// gsmEscape is the septet that switches to the single shift (extension) table.
+const gsmEscape = 0x1B
+
+// gsmDefaultAlphabet is the GSM 7-bit default alphabet (3GPP TS 23.038 section 6.2.1).
+var gsmDefaultAlphabet = []rune("@£$¥èéùìòÇ\nØø\rÅå" +
+	"Δ_ΦΓΛΩΠΨΣΘΞ\x1bÆæßÉ" +
+	" !\"#¤%&'()*+,-./" +
+	"0123456789:;<=>?" +
+	"¡ABCDEFGHIJKLMNO" +
+	"PQRSTUVWXYZÄÖÑÜ§" +
+	"¿abcdefghijklmno" +
+	"pqrstuvwxyzäöñüà")
+
+// gsmDefaultExtension is the default single shift table (3GPP TS 23.038 section 6.2.1.1).
+var gsmDefaultExtension = map[byte]rune{
+	0x0A: '\f', 0x14: '^', 0x28: '{', 0x29: '}', 0x2F: '\\',
+	0x3C: '[', 0x3D: '~', 0x3E: ']', 0x40: '|', 0x65: '€',
+}
+
+// gsmLockingTables holds the national language locking shift tables (3GPP TS 23.038 annex A.3).
+// Spanish has no locking shift table.
+var gsmLockingTables = map[GSMLanguage][]rune{
+	GSMTurkish: []rune("@£$¥€éùıòÇ\nĞğ\rÅå" +
+		"Δ_ΦΓΛΩΠΨΣΘΞ\x1bŞşßÉ" +
+		" !\"#¤%&'()*+,-./" +
+		"0123456789:;<=>?" +
+		"İABCDEFGHIJKLMNO" +
+		"PQRSTUVWXYZÄÖÑÜ§" +
+		"çabcdefghijklmno" +
+		"pqrstuvwxyzäöñüà"),
+	GSMPortuguese: []rune("@£$¥êéúíóç\nÔô\rÁá" +
+		"Δ_ªÇÀ∞^\\€Ó|\x1bÂâÊÉ" +
+		" !\"#º%&'()*+,-./" +
+		"0123456789:;<=>?" +
+		"ÍABCDEFGHIJKLMNO" +
+		"PQRSTUVWXYZÃÕÚÜ§" +
+		"~abcdefghijklmno" +
+		"pqrstuvwxyzãõ`üà"),
+	GSMHindi: []rune("ँंःअआइईउऊऋ\nऌऍ\rऎए" +
+		"ऐऑऒओऔकखगघङच\x1bछजझञ" +
+		" !टठडढणत)(थद,ध.न" +
+		"0123456789:;ऩपफ?" +
+		"बभमयरऱलळऴवशषसह़ऽ" +
+		"ािीुूृॄॅॆेैॉॊोौ्" +
+		"ॐabcdefghijklmno" +
+		"pqrstuvwxyzॲॻॼॾॿ"),
+}
+
+// gsmSingleShiftTables holds the national language single shift tables (3GPP TS 23.038 annex A.2).
+var gsmSingleShiftTables = map[GSMLanguage]map[byte]rune{
+	GSMTurkish: {
+		0x0A: '\f', 0x14: '^', 0x28: '{', 0x29: '}', 0x2F: '\\',
+		0x3C: '[', 0x3D: '~', 0x3E: ']', 0x40: '|', 0x47: 'Ğ',
+		0x49: 'İ', 0x53: 'Ş', 0x63: 'ç', 0x65: '€', 0x67: 'ğ',
+		0x69: 'ı', 0x73: 'ş',
+	},
+	GSMSpanish: {
+		0x09: 'ç', 0x0A: '\f', 0x14: '^', 0x28: '{', 0x29: '}',
+		0x2F: '\\', 0x3C: '[', 0x3D: '~', 0x3E: ']', 0x40: '|',
+		0x41: 'Á', 0x49: 'Í', 0x4F: 'Ó', 0x55: 'Ú', 0x61: 'á',
+		0x65: '€', 0x69: 'í', 0x6F: 'ó', 0x75: 'ú',
+	},
+	GSMPortuguese: {
+		0x05: 'ê', 0x09: 'ç', 0x0A: '\f', 0x0B: 'Ô', 0x0C: 'ô',
+		0x0E: 'Á', 0x0F: 'á', 0x12: 'Φ', 0x13: 'Γ', 0x14: '^',
+		0x15: 'Ω', 0x16: 'Π', 0x17: 'Ψ', 0x18: 'Σ', 0x19: 'Θ',
+		0x1F: 'Ê', 0x28: '{', 0x29: '}', 0x2F: '\\', 0x3C: '[',
+		0x3D: '~', 0x3E: ']', 0x40: '|', 0x41: 'À', 0x49: 'Í',
+		0x4F: 'Ó', 0x55: 'Ú', 0x5B: 'Ã', 0x5C: 'Õ', 0x61: 'Â',
+		0x65: '€', 0x69: 'í', 0x6F: 'ó', 0x75: 'ú', 0x7B: 'ã',
+		0x7C: 'õ', 0x7F: 'â',
+	},
+	GSMHindi: {
+		0x00: '@', 0x01: '£', 0x02: '$', 0x03: '¥', 0x04: '¿',
+		0x05: '"', 0x06: '¤', 0x07: '%', 0x08: '&', 0x09: '\'',
+		0x0A: '\f', 0x0B: '*', 0x0C: '+', 0x0E: '-', 0x0F: '/',
+		0x10: '<', 0x11: '=', 0x12: '>', 0x13: '¡', 0x14: '^',
+		0x15: '¡', 0x16: '_', 0x17: '#', 0x18: '*', 0x19: '।',
+		0x1A: '॥', 0x1C: '०', 0x1D: '१', 0x1E: '२', 0x1F: '३',
+		0x20: '४', 0x21: '५', 0x22: '६', 0x23: '७', 0x24: '८',
+		0x25: '९', 0x26: '॑', 0x27: '॒', 0x28: '{', 0x29: '}',
+		0x2A: '॓', 0x2B: '॔', 0x2C: 'क़', 0x2D: 'ख़', 0x2E: 'ग़',
+		0x2F: '\\', 0x30: 'ज़', 0x31: 'ड़', 0x32: 'ढ़', 0x33: 'फ़',
+		0x34: 'य़', 0x35: 'ॠ', 0x36: 'ॡ', 0x37: 'ॢ', 0x38: 'ॣ',
+		0x39: '॰', 0x3A: 'ॱ', 0x3C: '[', 0x3D: '~', 0x3E: ']',
+		0x40: '|', 0x41: 'A', 0x42: 'B', 0x43: 'C', 0x44: 'D',
+		0x45: 'E', 0x46: 'F', 0x47: 'G', 0x48: 'H', 0x49: 'I',
+		0x4A: 'J', 0x4B: 'K', 0x4C: 'L', 0x4D: 'M', 0x4E: 'N',
+		0x4F: 'O', 0x50: 'P', 0x51: 'Q', 0x52: 'R', 0x53: 'S',
+		0x54: 'T', 0x55: 'U', 0x56: 'V', 0x57: 'W', 0x58: 'X',
+		0x59: 'Y', 0x5A: 'Z', 0x65: '€',
+	},
+}
+
+// gsmTables selects the locking shift (base) and single shift (extension)
+// tables used to encode a message. The zero value is the default alphabet.
+type gsmTables struct {
+	Locking GSMLanguage
+	Single  GSMLanguage
+}
+
+// gsmCharset is a compiled pair of tables with reverse lookups for encoding.
+type gsmCharset struct {
+	base   []rune
+	ext    map[byte]rune
+	encode map[rune]byte
+	encExt map[rune]byte
+}
+
+var gsmCharsets = map[gsmTables]*gsmCharset{}
+
+func init() {
+	bases := map[GSMLanguage][]rune{GSMDefault: gsmDefaultAlphabet}
+	for lang, table := range gsmLockingTables {
+		bases[lang] = table
+	}
+	exts := map[GSMLanguage]map[byte]rune{GSMDefault: gsmDefaultExtension}
+	for lang, table := range gsmSingleShiftTables {
+		exts[lang] = table
+	}
+	for locking, base := range bases {
+		if len(base) != 128 {
+			panic(fmt.Sprintf("GSM %s locking shift table has %d entries", locking, len(base)))
+		}
+		for single, ext := range exts {
+			gsmCharsets[gsmTables{Locking: locking, Single: single}] = newGSMCharset(base, ext)
+		}
+	}
+}
+
+func newGSMCharset(base []rune, ext map[byte]rune) *gsmCharset {
+	cs := &gsmCharset{
+		base:   base,
+		ext:    ext,
+		encode: make(map[rune]byte, len(base)),
+		encExt: make(map[rune]byte, len(ext)),
+	}
+	for i, r := range base {
+		if i == gsmEscape {
+			continue
+		}
+		if _, dup := cs.encode[r]; !dup {
+			cs.encode[r] = byte(i)
+		}
+	}
+	// Iterate in code order so duplicated characters map to their first position.
+	for code := 0; code < 128; code++ {
+		r, ok := ext[byte(code)]
+		if !ok {
+			continue
+		}
+		if _, inBase := cs.encode[r]; inBase {
+			continue
+		}
+		if _, dup := cs.encExt[r]; !dup {
+			cs.encExt[r] = byte(code)
+		}
+	}
+	return cs
+}
+
+func (t gsmTables) charset() (*gsmCharset, error) {
+	cs, ok := gsmCharsets[t]
+	if !ok {
+		return nil, fmt.Errorf("no GSM tables for locking shift %s and single shift %s", t.Locking, t.Single)
+	}
+	return cs, nil
+}
+
+// septetCount returns the number of septets s needs with these tables.
+// Extension characters count as two septets (escape + char).
+func (t gsmTables) septetCount(s string) (int, error) {
+	cs, err := t.charset()
+	if err != nil {
+		return 0, err
+	}
+	count := 0
+	for _, r := range s {
+		if _, ok := cs.encode[r]; ok {
+			count++
+		} else if _, ok := cs.encExt[r]; ok {
+			count += 2
+		} else {
+			return 0, fmt.Errorf("rune %U (%q) not representable in GSM 03.38 (%s tables)", r, r, t)
+		}
+	}
+	return count, nil
+}
+
+// encode returns s as unpacked septets, one per octet.
+func (t gsmTables) encode(s string) ([]byte, error) {
+	cs, err := t.charset()
+	if err != nil {
+		return nil, err
+	}
+	out := make([]byte, 0, len(s))
+	for _, r := range s {
+		if code, ok := cs.encode[r]; ok {
+			out = append(out, code)
+		} else if code, ok := cs.encExt[r]; ok {
+			out = append(out, gsmEscape, code)
+		} else {
+			return nil, fmt.Errorf("rune %U (%q) not representable in GSM 03.38 (%s tables)", r, r, t)
+		}
+	}
+	return out, nil
+}
+
+// decode converts unpacked septets back to text. Unknown extension codes
+// decode to their base table character, as 3GPP TS 23.038 recommends.
+func (t gsmTables) decode(septets []byte) (string, error) {
+	cs, err := t.charset()
+	if err != nil {
+		return "", err
+	}
+	var b strings.Builder
+	for i := 0; i < len(septets); i++ {
+		code := septets[i] & 0x7F
+		if code == gsmEscape && i+1 < len(septets) {
+			i++
+			next := septets[i] & 0x7F
+			if r, ok := cs.ext[next]; ok {
+				b.WriteRune(r)
+			} else {
+				b.WriteRune(cs.base[next])
+			}
+			continue
+		}
+		if code == gsmEscape {
+			continue
+		}
+		b.WriteRune(cs.base[code])
+	}
+	return b.String(), nil
+}
+
+// udh returns the national language information elements for t, if any.
+func (t gsmTables) udh() []byte {
+	var ies []byte
+	if t.Locking != GSMDefault {
+		ies = append(ies, ieiNationalLockingShift, 1, byte(t.Locking))
+	}
+	if t.Single != GSMDefault {
+		ies = append(ies, ieiNationalSingleShift, 1, byte(t.Single))
+	}
+	return ies
+}
+
+func (t gsmTables) String() string {
+	if t.Locking == GSMDefault && t.Single == GSMDefault {
+		return "default"
+	}
+	return fmt.Sprintf("locking %s, single shift %s", t.Locking, t.Single)
+}
+
+// udhSeptets is the number of septets a UDH of n octets (including the UDHL octet) occupies.
+func udhSeptets(n int) int {
+	if n == 0 {
+		return 0
+	}
+	return (n*8 + 6) / 7
+}
+
+// GSMPacking selects how GSM 7-bit septets are carried in short_message.
+// SMPP leaves this to the SMSC; most expect one septet per octet.
+type GSMPacking int
+
+const (
+	GSMUnpacked GSMPacking = iota // one septet per octet
+	GSMPacked                     // 8 septets in 7 octets, as on the air interface
+)
+
+func (p GSMPacking) String() string {
+	if p == GSMPacked {
+		return "packed"
+	}
+	return "unpacked"
+}
+
+// Set implements flag.Value.
+func (p *GSMPacking) Set(s string) (err error) {
+	*p, err = parseGSMPacking(s)
+	return err
+}
+
+// parseGSMPacking accepts "packed" or "unpacked" (the default when empty).
+func parseGSMPacking(s string) (GSMPacking, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "", "unpacked":
+		return GSMUnpacked, nil
+	case "packed":
+		return GSMPacked, nil
+	}
+	return GSMUnpacked, fmt.Errorf("unknown GSM packing %q (want packed or unpacked)", s)
+}
+
+// packSeptets packs septets least significant bit first, starting after
+// fill zero bits.
+func packSeptets(septets []byte, fill int) []byte {
+	out := make([]byte, (fill+len(septets)*7+7)/8)
+	pos := fill
+	for _, s := range septets {
+		v := uint16(s&0x7F) << (pos % 8)
+		out[pos/8] |= byte(v)
+		if pos%8 > 1 {
+			out[pos/8+1] |= byte(v >> 8)
+		}
+		pos += 7
+	}
+	return out
+}
+
+// unpackSeptets reverses packSeptets, reading count septets after fill bits.
+func unpackSeptets(packed []byte, fill, count int) []byte {
+	out := make([]byte, 0, count)
+	for pos := fill; len(out) < count && pos+7 <= len(packed)*8; pos += 7 {
+		v := uint16(packed[pos/8])
+		if pos/8+1 < len(packed) {
+			v |= uint16(packed[pos/8+1]) << 8
+		}
+		out = append(out, byte(v>>(pos%8))&0x7F)
+	}
+	return out
+}
+
+// gsmUserData returns the exact short_message octets for unpacked septets:
+// the UDH, if any, followed by the septets. When packing is GSMPacked the
+// septets are packed after the fill bits that align them to a septet
+// boundary following the UDH.
+func gsmUserData(septets []byte, udh pdu.UDH, packing GSMPacking) []byte {
+	header := udhBytes(udh)
+	if packing != GSMPacked {
+		return append(header, septets...)
+	}
+	fill := (7 - len(header)*8%7) % 7
+	return append(header, packSeptets(septets, fill)...)
+}
+
+// selectGSMTables picks the cheapest table combination able to encode s,
+// counting the septets taken by the national language UDH. Only the default
+// tables and the given languages are considered.
+func selectGSMTables(s string, languages []GSMLanguage) (gsmTables, int, error) {
+	candidates := []gsmTables{{}}
+	for _, lang := range languages {
+		if _, ok := gsmSingleShiftTables[lang]; ok {
+			candidates = append(candidates, gsmTables{Single: lang})
+		}
+		if _, ok := gsmLockingTables[lang]; ok {
+			candidates = append(candidates, gsmTables{Locking: lang})
+			if _, ok := gsmSingleShiftTables[lang]; ok {
+				candidates = append(candidates, gsmTables{Locking: lang, Single: lang})
+			}
+		}
+	}
+
+	best, bestCost := gsmTables{}, -1
+	var firstErr error
+	for _, t := range candidates {
+		septets, err := t.septetCount(s)
+		if err != nil {
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		cost := septets
+		if ies := t.udh(); len(ies) > 0 {
+			cost += udhSeptets(1 + len(ies))
+		}
+		if bestCost < 0 || cost < bestCost {
+			best, bestCost = t, cost
+		}
+	}
+	if bestCost < 0 {
+		return gsmTables{}, 0, firstErr
+	}
+	septets, _ := best.septetCount(s)
+	return best, septets, nil
+}
+
+// nationalTables returns the shift tables selected by the IEs in udh.
+func nationalTables(udh pdu.UDH) gsmTables {
+	var t gsmTables
+	for _, ie := range udh {
+		if len(ie.Data) != 1 {
+			continue
+		}
+		switch ie.ID {
+		case ieiNationalLockingShift:
+			t.Locking = GSMLanguage(ie.Data[0])
+		case ieiNationalSingleShift:
+			t.Single = GSMLanguage(ie.Data[0])
+		}
+	}
+	return t
+}
+
+// messageText decodes a short message using its data coding scheme when
+// known, honouring national language shift tables for GSM 7-bit.
+func messageText(m *pdu.ShortMessage, dcs *DataCodingScheme) (string, error) {
+	if dcs == nil {
+		if m.Encoding() != data.GSM7BIT || nationalTables(m.UDH()) == (gsmTables{}) {
+			return m.GetMessage()
+		}
+		dcs = &DataCodingScheme{Alphabet: AlphabetGSM7}
+	}
+	raw, err := m.GetMessageData()
+	if err != nil {
+		return "", err
+	}
+	if t := nationalTables(m.UDH()); dcs.Alphabet == AlphabetGSM7 && t != (gsmTables{}) {
+		return t.decode(raw)
+	}
+	enc, err := dcs.Encoding()
+	if err != nil {
+		return "", err
+	}
+	return enc.Decode(raw)
+}
@@ -0,0 +1,179 @@
+package main
+
+import (
+	"bytes"
+	"encoding/hex"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// allGSMTables returns every locking and single shift combination.
+func allGSMTables() []gsmTables {
+	locking := []GSMLanguage{GSMDefault}
+	for lang := range gsmLockingTables {
+		locking = append(locking, lang)
+	}
+	single := []GSMLanguage{GSMDefault}
+	for lang := range gsmSingleShiftTables {
+		single = append(single, lang)
+	}
+	sort.Slice(locking, func(i, j int) bool { return locking[i] < locking[j] })
+	sort.Slice(single, func(i, j int) bool { return single[i] < single[j] })
+	var out []gsmTables
+	for _, l := range locking {
+		for _, s := range single {
+			out = append(out, gsmTables{Locking: l, Single: s})
+		}
+	}
+	return out
+}
+
+func TestGSMTablesRoundTrip(t *testing.T) {
+	for _, tables := range allGSMTables() {
+		cs, err := tables.charset()
+		if err != nil {
+			t.Fatal(err)
+		}
+		// Every character of both tables, base table first.
+		var text []rune
+		for i, r := range cs.base {
+			if i != gsmEscape {
+				text = append(text, r)
+			}
+		}
+		for code := 0; code < 128; code++ {
+			if r, ok := cs.ext[byte(code)]; ok {
+				text = append(text, r)
+			}
+		}
+		septets, err := tables.encode(string(text))
+		if err != nil {
+			t.Fatalf("%s: %v", tables, err)
+		}
+		if n, err := tables.septetCount(string(text)); err != nil || n != len(septets) {
+			t.Errorf("%s: septetCount = %d, %v; encode gives %d septets", tables, n, err, len(septets))
+		}
+		for _, s := range septets {
+			if s > 0x7F {
+				t.Fatalf("%s: encode produced octet 0x%02X", tables, s)
+			}
+		}
+		if got, err := tables.decode(septets); err != nil || got != string(text) {
+			t.Errorf("%s: round-trip gives %q, %v", tables, got, err)
+		}
+	}
+}
+
+func TestGSMTablesNational(t *testing.T) {
+	cases := []struct {
+		tables gsmTables
+		text   string
+		hex    string
+	}{
+		{gsmTables{}, "@£$ {€}", "000102201B281B651B29"},
+		{gsmTables{Locking: GSMTurkish}, "şğı", "1D0C07"},
+		{gsmTables{Single: GSMTurkish}, "şğı", "1B731B671B69"},
+		{gsmTables{Single: GSMSpanish}, "áí", "1B611B69"},
+		{gsmTables{Locking: GSMPortuguese, Single: GSMPortuguese}, "ãõ", "7B7C"},
+		{gsmTables{Locking: GSMHindi}, "नमस", "2F424C"},
+	}
+	for _, tc := range cases {
+		septets, err := tc.tables.encode(tc.text)
+		if err != nil {
+			t.Errorf("%s: %q: %v", tc.tables, tc.text, err)
+			continue
+		}
+		if got := strings.ToUpper(hex.EncodeToString(septets)); got != tc.hex {
+			t.Errorf("%s: %q encodes to %s, want %s", tc.tables, tc.text, got, tc.hex)
+		}
+		udh, err := parseUDH(append([]byte{byte(len(tc.tables.udh()))}, tc.tables.udh()...))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := nationalTables(udh); got != tc.tables {
+			t.Errorf("the UDH of %s selects %s", tc.tables, got)
+		}
+	}
+	if _, err := (gsmTables{}).encode("ş"); err == nil {
+		t.Error("ş encoded with the default tables")
+	}
+	if _, err := (gsmTables{Locking: 4}).encode("a"); err == nil {
+		t.Error("text encoded with a locking shift table that is not included")
+	}
+}
+
+// septetAt reads septet k of GSM user data following header octets and
+// fill bits, as 3GPP TS 23.040 section 9.2.3.24 lays them out: bits are
+// numbered from the least significant bit of each octet.
+func septetAt(ud []byte, header, fill, k int) byte {
+	var s byte
+	for bit := 0; bit < 7; bit++ {
+		pos := header*8 + fill + 7*k + bit
+		if ud[pos/8]>>(pos%8)&1 != 0 {
+			s |= 1 << bit
+		}
+	}
+	return s
+}
+
+func TestGSMUserData(t *testing.T) {
+	hello, _ := (gsmTables{}).encode("hellohello")
+	if got := gsmUserData(hello, nil, GSMPacked); !bytes.Equal(got, []byte{0xE8, 0x32, 0x9B, 0xFD, 0x46, 0x97, 0xD9, 0xEC, 0x37}) {
+		t.Errorf("hellohello packs to %X", got)
+	}
+
+	turkish := gsmTables{Locking: GSMTurkish}
+	udhs := map[string][]byte{
+		"none":          nil,
+		"turkish":       {0x03, 0x25, 0x01, 0x01},
+		"concatenation": {0x05, 0x00, 0x03, 0x2A, 0x02, 0x01},
+		"concatenation and ports": {0x0B, 0x00, 0x03, 0x2A, 0x02, 0x01,
+			0x05, 0x04, 0x0B, 0x84, 0x23, 0xF0},
+	}
+	for name, raw := range udhs {
+		parsed, err := parseUDH(raw)
+		if len(raw) > 0 && err != nil {
+			t.Fatal(err)
+		}
+		tables := nationalTables(parsed)
+		if name == "turkish" && tables != turkish {
+			t.Fatalf("UDH %X selects %s", raw, tables)
+		}
+		for _, text := range []string{"", "a", "şğı İstanbul", strings.Repeat("Çok güzel! ", 9)} {
+			septets, err := tables.encode(text)
+			if err != nil {
+				continue
+			}
+			unpacked := gsmUserData(septets, parsed, GSMUnpacked)
+			if !bytes.Equal(unpacked, append(append([]byte(nil), raw...), septets...)) {
+				t.Errorf("%s: unpacked %q is %X", name, text, unpacked)
+			}
+
+			packed := gsmUserData(septets, parsed, GSMPacked)
+			fill := (7 - len(raw)*8%7) % 7
+			if want := len(raw) + (fill+7*len(septets)+7)/8; len(packed) != want {
+				t.Errorf("%s: packed %q is %d octets, want %d", name, text, len(packed), want)
+			}
+			if !bytes.Equal(packed[:len(raw)], raw) {
+				t.Errorf("%s: packed %q does not start with the UDH: %X", name, text, packed)
+			}
+			if len(septets) > 0 && fill > 0 && packed[len(raw)]&(1<<fill-1) != 0 {
+				t.Errorf("%s: fill bits of %q are not zero: %X", name, text, packed)
+			}
+			for k, s := range septets {
+				if got := septetAt(packed, len(raw), fill, k); got != s {
+					t.Errorf("%s: septet %d of %q is 0x%02X, want 0x%02X", name, k, text, got, s)
+				}
+			}
+			if got := unpackSeptets(packed[len(raw):], fill, len(septets)); !bytes.Equal(got, septets) {
+				t.Errorf("%s: unpacking %q gives %X, want %X", name, text, got, septets)
+			}
+			if text != "" {
+				if got, _ := tables.decode(septets); got != text {
+					t.Errorf("%s: %q decodes to %q", name, text, got)
+				}
+			}
+		}
+	}
+}
@@ -5,30 +5,16 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
-	"math"
 	"os"
 	"strings"
 	"unicode/utf16"
-)
 
-// InputPDU models the "input_pdu" object. We use pointer types for optional fields
-func init() {
-	// Default GSM 03.38 characters (common set). Source: GSM 03.38 tables (practical subset).
-	def := "@£$¥èéùìòÇ\nØø\rÅåΔ_ΦΓΛΩΠΨΣΘΞÆæßÉ !\"#¤%&'()*+,-./0123456789:;<=>?¡" +
-		"ABCDEFGHIJKLMNOPQRSTUVWXYZÄÖÑÜ§¿abcdefghijklmnopqrstuvwxyzäöñüà"
-	gsmDefault = make(map[rune]bool, len(def))
-	for _, r := range def {
-		gsmDefault[r] = true
-	}
-	// Extended table (characters that require escape; each consumes two septets)
-	ext := "^{}\\[~]|€" // common extended characters
-	gsmExtended = make(map[rune]bool, len(ext))
-	for _, r := range ext {
-		gsmExtended[r] = true
-	}
-}
+	"github.com/linxGnu/gosmpp/pdu"
+)
 
 // parseFile accepts either a JSON array or newline-delimited JSON objects (JSONL).
+// The content is checked against the test case schema first, so type errors
+// are reported with their JSON path (e.g. $[2].input_pdu.sm_length).
 func parseFile(path string) ([]TestCase, error) {
 	// Read entire file (same behavior as original). For very large files,
 	// consider streaming with os.Open and json.Decoder directly.
@@ -52,6 +38,9 @@ func parseFile(path string) ([]TestCase, error) {
 
 	// If the content starts with '[' treat it as a JSON array.
 	if bytes.HasPrefix(trimmed, []byte{'['}) {
+		if errs := validateTestFile(testCasesSchema(), trimmed, "$"); len(errs) > 0 {
+			return nil, schemaError(errs)
+		}
 		var tests []TestCase
 		if err := json.Unmarshal(trimmed, &tests); err != nil {
 			return nil, fmt.Errorf("error unmarshalling JSON array: %w", err)
@@ -62,16 +51,29 @@ func parseFile(path string) ([]TestCase, error) {
 	// Fallback: decode one or more JSON objects (JSONL or concatenated JSON objects).
 	dec := json.NewDecoder(bytes.NewReader(trimmed))
 	var tests []TestCase
-	for {
-		var tc TestCase
-		if err := dec.Decode(&tc); err != nil {
+	var schemaErrs []string
+	item := testCaseSchema()
+	for i := 0; ; i++ {
+		var raw json.RawMessage
+		if err := dec.Decode(&raw); err != nil {
 			if err == io.EOF {
 				break
 			}
 			return nil, fmt.Errorf("error decoding JSON object: %w", err)
 		}
+		if errs := validateTestFile(item, raw, fmt.Sprintf("$[%d]", i)); len(errs) > 0 {
+			schemaErrs = append(schemaErrs, errs...)
+			continue
+		}
+		var tc TestCase
+		if err := json.Unmarshal(raw, &tc); err != nil {
+			return nil, fmt.Errorf("error decoding JSON object: %w", err)
+		}
 		tests = append(tests, tc)
 	}
+	if len(schemaErrs) > 0 {
+		return nil, schemaError(schemaErrs)
+	}
 
 	if len(tests) == 0 {
 		return nil, fmt.Errorf("no test cases found in file")
@@ -79,23 +81,13 @@ func parseFile(path string) ([]TestCase, error) {
 	return tests, nil
 }
 
-// gsm7SeptetCount returns number of septets required for string s under GSM 03.38:
+// gsm7SeptetCount returns number of septets required for string s under GSM 03.38
+// using the default alphabet and default extension table:
 // - default characters = 1 septet
 // - extended characters = 2 septets (escape + char)
 // returns error if a rune is not representable.
 func gsm7SeptetCount(s string) (int, error) {
-	count := 0
-	for _, r := range s {
-		if gsmDefault[r] {
-			count++
-		} else if gsmExtended[r] {
-			// extended table characters are represented using ESC + char => 2 septets
-			count += 2
-		} else {
-			return 0, fmt.Errorf("rune %U (%q) not representable in GSM 03.38", r, r)
-		}
-	}
-	return count, nil
+	return gsmTables{}.septetCount(s)
 }
 
 // ucs2ByteLength computes the number of bytes when the string is encoded as UTF-16 (big-endian).
@@ -105,46 +97,44 @@ func ucs2ByteLength(s string) int {
 	return len(codeUnits) * 2
 }
 
-// computeSegments calculates how many SMS segments the message will occupy given encoding and UDH.
-// We use the conventional per-segment user data character limits:
-// - GSM 7-bit: single=160, concatenated=153
-// - UCS-2: single=70, concatenated=67
-// For unknown encodings we fallback to octet-based sizes: single=140, concatenated=134.
-func computeSegments(dataCoding int, udhPresent bool, messageLength int) int {
-	var perSegment int
-	switch dataCoding {
-	case 0:
-		if udhPresent {
-			perSegment = 153
-		} else {
-			perSegment = 160
-		}
-	case 8:
-		if udhPresent {
-			perSegment = 67
-		} else {
-			perSegment = 70
-		}
-	default:
-		if udhPresent {
-			perSegment = 134
-		} else {
-			perSegment = 140
-		}
+// testCaseSegments is the number of SMS the short_message of in takes, with
+// the segment sizes splitMessage uses; text is the message as sent. A test
+// case carrying its own UDH is already one segment.
+func testCaseSegments(in InputPDU, text string, dataCoding int, udh pdu.UDH, octets int) int {
+	if len(udh) > 0 {
+		return 1
 	}
-	if messageLength <= 0 {
-		return 0
+	report := EncodingReport{Encoding: EncodingLatin1, Length: octets}
+	switch DecodeDCS(byte(dataCoding)).Alphabet {
+	case AlphabetGSM7:
+		if _, binary, _ := testCasePayload(in); !binary {
+			report.Encoding = EncodingGSM7
+			report.Length, _ = (gsmTables{}).septetCount(text)
+		}
+	case AlphabetUCS2:
+		report.Encoding = EncodingUCS2
 	}
-	return int(math.Ceil(float64(messageLength) / float64(perSegment)))
+	return segmentCount(report)
+}
+
+// validateTestCase validates the input PDU of tc, predicts the command_status
+// a compliant SMSC would return and compares it with the expected output.
+func validateTestCase(index int, tc TestCase, opts ValidationOptions) ValidationResult {
+	res := validateInput(index, tc, opts)
+	compareExpectedOutput(tc, &res)
+	return res
 }
 
-// validateTestCase performs the validations and returns a ValidationResult.
+// validateInput performs the validations and returns a ValidationResult.
 // Behavior notes to match the sample expectations:
-//   - For data_coding == 0 we treat sm_length as the septet count (number of GSM 7-bit characters,
-//     counting extended characters as 2). Incompatible characters cause failure.
+//   - sm_length is the octet count of short_message on the wire, UDH included.
+//   - For data_coding == 0 that is the septet count (extended characters count as 2) when the
+//     profile sends unpacked GSM 7-bit, or the packed size after fill bits when it packs.
+//     Incompatible characters cause failure unless the test case opts into transliteration,
+//     in which case sm_length refers to the transliterated text.
 //   - For data_coding == 8 we compute sm_length as UTF-16 bytes (number of code units * 2).
 //   - Otherwise we fallback to len([]byte(short_message)) (UTF-8 bytes).
-func validateTestCase(index int, tc TestCase) ValidationResult {
+func validateInput(index int, tc TestCase, opts ValidationOptions) ValidationResult {
 	res := ValidationResult{
 		Index: index,
 		Valid: true,
@@ -153,12 +143,24 @@ func validateTestCase(index int, tc TestCase) ValidationResult {
 	// Required field checks
 	if tc.InputPdu.DestinationAddr == nil || strings.TrimSpace(*tc.InputPdu.DestinationAddr) == "" {
 		res.Valid = false
+		res.PredictedStatus = ESME_RINVDSTADR
 		res.Errors = append(res.Errors, "Missing required field: destination_addr")
 		// Expected output in sample for this case is a failed delivery with that error.
 		//res.ExpectedOutputMatch = (tc.ExpectedOutput.Error != nil && strings.Contains(*tc.ExpectedOutput.Error, "Missing required field"))
 		return res
 	}
 
+	// Field-level checks, each with the status an SMSC would answer.
+	if vs := validateSubmitSM(tc.InputPdu, opts); len(vs) > 0 {
+		res.Valid = false
+		res.PredictedStatus = vs[0].Status
+		res.Violations = vs
+		for _, v := range vs {
+			res.Errors = append(res.Errors, v.String())
+		}
+		return res
+	}
+
 	// Short message presence: we allow empty messages but compute accordingly.
 	shortMsg := ""
 	if tc.InputPdu.ShortMessage != nil {
@@ -170,55 +172,51 @@ func validateTestCase(index int, tc TestCase) ValidationResult {
 		dataCoding = *tc.InputPdu.DataCoding
 	}
 
-	// Compute expected "length" according to encoding rule that matches your examples.
-	var computedLength int
-	switch dataCoding {
-	case 0:
-		// GSM 7-bit: compute septet count; incompatible characters -> error
-		sep, err := gsm7SeptetCount(shortMsg)
-		if err != nil {
-			res.Valid = false
-			res.Errors = append(res.Errors, "Message contains characters incompatible with data_coding 0 (GSM 7-bit)")
-			res.Errors = append(res.Errors, err.Error())
-			// expected_output for such sample indicates failed delivery with that error.
-			//if tc.ExpectedOutput.Error != nil && strings.Contains(*tc.ExpectedOutput.Error, "incompatible") {
-			//	res.ExpectedOutputMatch = true
-			//}
-			return res
+	gsm := DecodeDCS(byte(dataCoding)).Alphabet == AlphabetGSM7
+	if gsm && tc.InputPdu.Transliterate != nil && *tc.InputPdu.Transliterate {
+		var subs []Substitution
+		shortMsg, subs = transliterateForGSM(shortMsg, nil, nil)
+		if len(subs) > 0 {
+			res.Note = fmt.Sprintf("transliterated %d character(s)", len(subs))
 		}
-		computedLength = sep
-	case 8:
-		// 16-bit encoding (UCS-2 / UTF-16BE): use code units * 2 bytes
-		computedLength = ucs2ByteLength(shortMsg)
-	default:
-		// fallback: raw UTF-8 byte length
-		computedLength = len([]byte(shortMsg))
 	}
 
-	res.ComputedSmLength = computedLength
+	// Binary payloads and UDHs must decode before anything is measured; the
+	// runner fails on them before sending.
+	if _, _, err := testCasePayload(tc.InputPdu); err != nil {
+		return testDataError(res, fmt.Sprintf("invalid binary short_message: %v", err))
+	}
+	udh, err := testCaseUDH(tc.InputPdu)
+	if err != nil {
+		return testDataError(res, fmt.Sprintf("invalid UDH: %v", err))
+	}
 
-	// Compare sm_length if present in input PDU
-	if tc.InputPdu.SmLength != nil {
-		if *tc.InputPdu.SmLength != computedLength {
-			res.Valid = false
-			// Match the error wording shown in your sample outputs
-			msg := fmt.Sprintf("sm_length indicates %d bytes but actual short_message is %d bytes (truncated or malformed PDU)", *tc.InputPdu.SmLength, computedLength)
-			res.Errors = append(res.Errors, msg)
-			// If expected_output contains that error text, flag a match
-			//if tc.ExpectedOutput.Error != nil && strings.Contains(*tc.ExpectedOutput.Error, "sm_length indicates") {
-			//	res.ExpectedOutputMatch = true
-			//}
-			return res
+	// Compute the octets newSubmitSM sends, UDH included; GSM 7-bit text is
+	// measured as the exact user data in the profile's packing.
+	computedLength, ok := shortMessageOctets(tc.InputPdu, dataCoding, udh, opts.Packing)
+	if !ok {
+		// Text the data_coding cannot represent -> error
+		res.Valid = false
+		res.PredictedStatus = ESME_RINVDCS
+		res.Errors = append(res.Errors, fmt.Sprintf("Message contains characters incompatible with data_coding %d (%s)", dataCoding, DecodeDCS(byte(dataCoding)).Alphabet))
+		if gsm {
+			if _, err := nationalTables(udh).encode(shortMsg); err != nil {
+				res.Errors = append(res.Errors, err.Error())
+			}
 		}
+		return res
 	}
 
-	// Determine UDH presence
-	//udhPresent := tc.InputPdu.Udh != nil && strings.TrimSpace(*tc.InputPdu.Udh) != ""
-	//res.Segments = computeSegments(dataCoding, udhPresent, computedLength)
-	//if udhPresent {
-	//	res.Note = "UDHI present, SMSC should concatenate segments"
-	//}
-	//
+	res.ComputedSmLength = computedLength
+
+	// Compare sm_length if present in input PDU. The runner sends the
+	// length it encodes, so a stale value is an error in the test case only.
+	if tc.InputPdu.SmLength != nil && *tc.InputPdu.SmLength != computedLength {
+		return testDataError(res, fmt.Sprintf("sm_length indicates %d bytes but actual short_message is %d bytes (truncated or malformed PDU)", *tc.InputPdu.SmLength, computedLength))
+	}
+
+	res.Segments = testCaseSegments(tc.InputPdu, shortMsg, dataCoding, udh, computedLength)
+
 	//// Compare certain expected_output fields (delivery_status and segments) where applicable.
 	//// This is a best-effort comparison; expected_output may contain message ID and other fields
 	//// that are unrelated to validation rules we compute here.
@@ -243,6 +241,15 @@ func validateTestCase(index int, tc TestCase) ValidationResult {
 	return res
 }
 
+// testDataError marks res as failing on the test case itself, with no
+// predicted SMSC status.
+func testDataError(res ValidationResult, msg string) ValidationResult {
+	res.Valid = false
+	res.TestDataError = true
+	res.Errors = append(res.Errors, msg)
+	return res
+}
+
 //func main() {
 //	filePath := flag.String("file", "test-case.jsonl", "path to JSON/JSONL file containing test cases")
 //	flag.Parse()
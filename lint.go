@@ -0,0 +1,385 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/fatih/color"
+)
+
+// LintSeverity ranks lint issues. Errors break a run; warnings are likely
+// mistakes in the test data.
+type LintSeverity int
+
+const (
+	LintError LintSeverity = iota
+	LintWarning
+)
+
+func (s LintSeverity) String() string {
+	if s == LintError {
+		return "error"
+	}
+	return "warning"
+}
+
+// LintIssue is a problem found in a test file. Fix, when set, is the value
+// --fix writes for Key.
+type LintIssue struct {
+	File     string
+	Line     int
+	Severity LintSeverity
+	TestCase string
+	Message  string
+	Key      string
+	Fix      string
+
+	offset int // start of the test case object
+}
+
+func (i LintIssue) String() string {
+	where := ""
+	if i.TestCase != "" {
+		where = "test case " + i.TestCase + ": "
+	}
+	fixable := ""
+	if i.Fix != "" {
+		fixable = " (fixable)"
+	}
+	return fmt.Sprintf("%s:%d: %s: %s%s%s", i.File, i.Line, i.Severity, where, i.Message, fixable)
+}
+
+// rawTestCase is one test case object and its byte offsets in the file.
+type rawTestCase struct {
+	Start, End int
+	Raw        json.RawMessage
+}
+
+// splitTestCases locates the test case objects of a JSON array or JSONL file
+// the way parseFile reads them. b must not start with a BOM.
+func splitTestCases(b []byte) ([]rawTestCase, error) {
+	dec := json.NewDecoder(bytes.NewReader(b))
+	trimmed := bytes.TrimSpace(b)
+	if bytes.HasPrefix(trimmed, []byte{'['}) {
+		if _, err := dec.Token(); err != nil {
+			return nil, err
+		}
+	}
+	var cases []rawTestCase
+	for dec.More() {
+		start := int(dec.InputOffset())
+		var raw json.RawMessage
+		if err := dec.Decode(&raw); err != nil {
+			if err == io.EOF {
+				break
+			}
+			return cases, err
+		}
+		// InputOffset before Decode may still point at a separator.
+		for start < len(b) && strings.IndexByte(" \t\r\n,", b[start]) >= 0 {
+			start++
+		}
+		cases = append(cases, rawTestCase{Start: start, End: int(dec.InputOffset()), Raw: raw})
+	}
+	return cases, nil
+}
+
+// lineAt returns the 1-based line of offset in b.
+func lineAt(b []byte, offset int) int {
+	if offset > len(b) {
+		offset = len(b)
+	}
+	return 1 + bytes.Count(b[:offset], []byte{'\n'})
+}
+
+// keyValueSpan finds "key": value in b[start:end] and returns the offsets of
+// the value.
+func keyValueSpan(b []byte, start, end int, key string) (int, int, bool) {
+	needle := []byte(strconv.Quote(key))
+	for pos := start; pos < end; {
+		i := bytes.Index(b[pos:end], needle)
+		if i < 0 {
+			return 0, 0, false
+		}
+		j := pos + i + len(needle)
+		for j < end && (b[j] == ' ' || b[j] == '\t' || b[j] == '\r' || b[j] == '\n') {
+			j++
+		}
+		if j < end && b[j] == ':' {
+			j++
+			for j < end && (b[j] == ' ' || b[j] == '\t' || b[j] == '\r' || b[j] == '\n') {
+				j++
+			}
+			dec := json.NewDecoder(bytes.NewReader(b[j:end]))
+			var v json.RawMessage
+			if err := dec.Decode(&v); err != nil {
+				return 0, 0, false
+			}
+			return j, j + int(dec.InputOffset()), true
+		}
+		pos = j
+	}
+	return 0, 0, false
+}
+
+// jsonFieldNames returns the JSON keys of struct type t.
+func jsonFieldNames(t reflect.Type) map[string]bool {
+	names := map[string]bool{}
+	for i := 0; i < t.NumField(); i++ {
+		name := strings.Split(t.Field(i).Tag.Get("json"), ",")[0]
+		if name == "" {
+			name = t.Field(i).Name
+		}
+		if name != "-" {
+			names[name] = true
+		}
+	}
+	return names
+}
+
+// encodingLabel is the informational "encoding" value matching dataCoding.
+func encodingLabel(dataCoding int) string {
+	switch DecodeDCS(byte(dataCoding)).Alphabet {
+	case AlphabetGSM7:
+		return "7-bit"
+	case AlphabetUCS2:
+		return "16-bit"
+	}
+	return "8-bit"
+}
+
+// lintFile checks the test cases in path and returns the issues found,
+// ordered by line.
+func lintFile(path string, opts ValidationOptions) ([]LintIssue, []byte, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	body := bytes.TrimPrefix(b, []byte{0xEF, 0xBB, 0xBF})
+	bom := len(b) - len(body)
+
+	var issues []LintIssue
+	caseStart := 0
+	report := func(offset int, sev LintSeverity, tc, key, fix, format string, args ...interface{}) {
+		issues = append(issues, LintIssue{
+			File: path, Line: lineAt(body, offset), Severity: sev, TestCase: tc,
+			Message: fmt.Sprintf(format, args...), Key: key, Fix: fix, offset: caseStart + bom,
+		})
+	}
+
+	cases, err := splitTestCases(body)
+	if err != nil {
+		offset := 0
+		var syntax *json.SyntaxError
+		if errors.As(err, &syntax) {
+			offset = int(syntax.Offset)
+		} else if len(cases) > 0 {
+			offset = cases[len(cases)-1].End
+		}
+		report(offset, LintError, "", "", "", "invalid JSON: %v", err)
+	}
+	if len(cases) == 0 && err == nil {
+		report(0, LintError, "", "", "", "no test cases found in file")
+	}
+
+	inputFields := jsonFieldNames(reflect.TypeOf(InputPDU{}))
+	numericFields := map[string]bool{}
+	for i, t := 0, reflect.TypeOf(InputPDU{}); i < t.NumField(); i++ {
+		if t.Field(i).Type == reflect.TypeOf((*int)(nil)) {
+			numericFields[strings.Split(t.Field(i).Tag.Get("json"), ",")[0]] = true
+		}
+	}
+
+	seenIDs := map[string]int{}
+	for n, c := range cases {
+		caseStart = c.Start
+		at := func(key string) int {
+			if s, _, ok := keyValueSpan(body, c.Start, c.End, key); ok {
+				return s
+			}
+			return c.Start
+		}
+
+		var obj map[string]json.RawMessage
+		if err := json.Unmarshal(c.Raw, &obj); err != nil {
+			report(c.Start, LintError, "", "", "", "test case #%d is not a JSON object", n+1)
+			continue
+		}
+		id := fmt.Sprintf("#%d", n+1)
+		typeErrors := false
+		if raw, ok := obj["test_case_id"]; !ok {
+			report(c.Start, LintWarning, id, "", "", "missing test_case_id")
+		} else {
+			id = strings.Trim(string(raw), `"`)
+			if _, err := strconv.Atoi(string(raw)); err != nil {
+				typeErrors = true
+				report(at("test_case_id"), LintError, id, "", "", "test_case_id %s must be a number", raw)
+			}
+			if first, dup := seenIDs[id]; dup {
+				report(at("test_case_id"), LintError, id, "", "", "duplicate test_case_id (first used on line %d)", first)
+			} else {
+				seenIDs[id] = lineAt(body, at("test_case_id"))
+			}
+		}
+		if _, ok := obj["expected_output_pdu"]; !ok {
+			report(c.Start, LintError, id, "", "", "missing expected_output_pdu")
+		}
+		rawInput, ok := obj["input_pdu"]
+		if !ok {
+			report(c.Start, LintError, id, "", "", "missing input_pdu")
+			continue
+		}
+
+		var input map[string]json.RawMessage
+		if err := json.Unmarshal(rawInput, &input); err != nil {
+			report(at("input_pdu"), LintError, id, "", "", "input_pdu is not a JSON object")
+			continue
+		}
+		keys := make([]string, 0, len(input))
+		for k := range input {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			v := input[k]
+			switch {
+			case !inputFields[k]:
+				report(at(k), LintWarning, id, "", "", "unknown input_pdu field %q is ignored", k)
+			case numericFields[k] && len(v) > 0 && v[0] == '"':
+				typeErrors = true
+				var s string
+				_ = json.Unmarshal(v, &s)
+				fix := ""
+				if n, err := strconv.ParseInt(strings.TrimSpace(s), 0, 64); err == nil {
+					fix = strconv.FormatInt(n, 10)
+				}
+				report(at(k), LintError, id, k, fix, "%s must be a number, not the string %s", k, v)
+			}
+		}
+		if typeErrors {
+			continue
+		}
+
+		var tc TestCase
+		if err := json.Unmarshal(c.Raw, &tc); err != nil {
+			report(c.Start, LintError, id, "", "", "%v", err)
+			continue
+		}
+		in := tc.InputPdu
+		dataCoding := 0
+		if in.DataCoding != nil {
+			dataCoding = *in.DataCoding
+		}
+		if in.Encoding != nil && in.DataCoding != nil {
+			if want := encodingLabel(dataCoding); !strings.EqualFold(*in.Encoding, want) {
+				report(at("encoding"), LintWarning, id, "encoding", strconv.Quote(want),
+					"encoding %q disagrees with data_coding %d (%s)", *in.Encoding, dataCoding, want)
+			}
+		}
+		if in.SmLength != nil {
+			udh, udhErr := testCaseUDH(in)
+			if n, ok := shortMessageOctets(in, dataCoding, udh, opts.Packing); ok && udhErr == nil && n != *in.SmLength {
+				report(at("sm_length"), LintWarning, id, "sm_length", strconv.Itoa(n),
+					"sm_length %d is stale, short_message is %d octets", *in.SmLength, n)
+			}
+		}
+	}
+
+	sort.SliceStable(issues, func(i, j int) bool { return issues[i].Line < issues[j].Line })
+	return issues, b, nil
+}
+
+// applyLintFixes rewrites the fixable values in b, leaving the rest of the
+// file as written.
+func applyLintFixes(b []byte, issues []LintIssue) ([]byte, int) {
+	type edit struct {
+		start, end int
+		value      string
+	}
+	var edits []edit
+	for _, is := range issues {
+		if is.Fix == "" {
+			continue
+		}
+		// The key is searched from the start of its test case object.
+		start, end, ok := keyValueSpan(b, is.offset, len(b), is.Key)
+		if !ok {
+			continue
+		}
+		edits = append(edits, edit{start, end, is.Fix})
+	}
+	sort.Slice(edits, func(i, j int) bool { return edits[i].start > edits[j].start })
+	out := append([]byte(nil), b...)
+	for _, e := range edits {
+		out = append(out[:e.start], append([]byte(e.value), out[e.end:]...)...)
+	}
+	return out, len(edits)
+}
+
+// runLint implements the "lint" subcommand.
+func runLint(args []string) int {
+	fs := flag.NewFlagSet("lint", flag.ExitOnError)
+	fix := fs.Bool("fix", false, "rewrite computed fields (sm_length, encoding, numeric strings) in place")
+	packing := packingFlag(fs)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: lint [-fix] [-packing MODE] FILE...")
+		fs.PrintDefaults()
+	}
+	_ = fs.Parse(args)
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return 2
+	}
+	opts := ValidationOptions{Packing: *packing}
+
+	status := 0
+	for _, path := range fs.Args() {
+		issues, b, err := lintFile(path, opts)
+		if err != nil {
+			color.Red("%v", err)
+			status = 1
+			continue
+		}
+		if *fix {
+			// Fixing a type error can reveal computed fields to fix, so
+			// repeat until nothing changes.
+			total := 0
+			for round := 0; round < 3 && err == nil; round++ {
+				fixed, n := applyLintFixes(b, issues)
+				if n == 0 {
+					break
+				}
+				if err = os.WriteFile(path, fixed, 0o644); err == nil {
+					total += n
+					issues, b, err = lintFile(path, opts)
+				}
+			}
+			if err != nil {
+				color.Red("%v", err)
+				status = 1
+				continue
+			}
+			if total > 0 {
+				color.Green("%s: fixed %d value(s)", path, total)
+			}
+		}
+		for _, is := range issues {
+			if is.Severity == LintError {
+				color.Red("%s", is)
+				status = 1
+			} else {
+				color.Yellow("%s", is)
+			}
+		}
+	}
+	return status
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLintFile(t *testing.T) {
+	issues, _, err := lintFile("testdata/lint.json", ValidationOptions{Packing: GSMPacked})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, is := range issues {
+		got = append(got, is.String())
+	}
+	want := []string{
+		`testdata/lint.json:7: error: test case 1: data_coding must be a number, not the string "8" (fixable)`,
+		`testdata/lint.json:19: error: test case 2: esm_class must be a number, not the string " 0x40 " (fixable)`,
+		`testdata/lint.json:31: error: test case 3: priority_flag must be a number, not the string "high"`,
+		`testdata/lint.json:32: warning: test case 3: unknown input_pdu field "short_msg" is ignored`,
+		`testdata/lint.json:37: error: test case 2: missing expected_output_pdu`,
+		`testdata/lint.json:38: error: test case 2: duplicate test_case_id (first used on line 15)`,
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("issues:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+// TestLintFix fixes the string numbers, and then the encoding and the stale
+// sm_length they hid, leaving the rest of the file as written.
+func TestLintFix(t *testing.T) {
+	in, err := os.ReadFile("testdata/lint.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, err := os.ReadFile("testdata/lint.fixed.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "cases.json")
+	if err := os.WriteFile(path, in, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if status := runLint([]string{"-fix", "-packing", "packed", path}); status != 1 {
+		t.Errorf("lint -fix exited %d, want 1 for the errors it cannot fix", status)
+	}
+	got, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != string(want) {
+		t.Errorf("lint -fix wrote:\n%s\nwant testdata/lint.fixed.json:\n%s", got, want)
+	}
+
+	// Fixing is idempotent.
+	if status := runLint([]string{"-fix", "-packing", "packed", path}); status != 1 {
+		t.Errorf("second lint -fix exited %d", status)
+	}
+	if again, _ := os.ReadFile(path); string(again) != string(want) {
+		t.Errorf("second lint -fix changed the file:\n%s", again)
+	}
+}
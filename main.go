@@ -10,16 +10,15 @@ package main
 //	if err := client.Connect(); err != nil {
 //		log.Fatalf("connect error: %v", err)
 //	}
-//	defer func() {
-//		if err := client.Close(); err != nil {
-//			log.Printf("close error: %v", err)
-//		}
-//	}()
 //
 //	// Send an example SMS asynchronously (adjust as needed)
 //	go func() {
-//		// give session a moment to bind
-//		time.Sleep(2 * time.Second)
+//		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+//		defer cancel()
+//		if err := client.WaitBound(ctx); err != nil {
+//			log.Printf("session not bound: %v", err)
+//			return
+//		}
 //		src := cfg.SourceAddr
 //		if src == "" {
 //			src = "MelroseLabs"
@@ -28,7 +27,12 @@ package main
 //		if dst == "" {
 //			dst = "447712345678"
 //		}
-//		sub := NewSubmitSM(src, dst, "Hello World")
+//		sub, enc, err := NewSubmitSM(src, dst, "Hello World", SubmitOptions{DefaultCountry: cfg.DefaultCountry})
+//		if err != nil {
+//			log.Printf("failed to build sms: %v", err)
+//			return
+//		}
+//		log.Printf("encoding %s, %d segment(s)", enc.Encoding, enc.Segments)
 //		if err := client.SendSMS(sub); err != nil {
 //			log.Printf("failed to submit sms: %v", err)
 //		} else {
@@ -41,4 +45,11 @@ package main
 //	signal.Notify(quit, os.Interrupt, syscall.SIGTERM)
 //	<-quit
 //	log.Println("shutting down")
+//	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+//	defer cancel()
+//	report, err := client.Shutdown(ctx, ShutdownOptions{WaitReceipts: true})
+//	if err != nil {
+//		log.Printf("shutdown error: %v", err)
+//	}
+//	log.Printf("shutdown complete: %s", report)
 //}
@@ -2,20 +2,21 @@ package main
 
 import (
 	"bufio"
+	"context"
 	"crypto/tls"
 	_ "encoding/json"
+	"flag"
 	"fmt"
 	"log"
-	"net"
 	"os"
+	"os/signal"
 	"strings"
 	"sync"
+	"syscall"
 	"time"
 
 	"github.com/fatih/color"
 	_ "github.com/fatih/color"
-	"github.com/joho/godotenv"
-	"github.com/linxGnu/gosmpp"
 	"github.com/linxGnu/gosmpp/data"
 	"github.com/linxGnu/gosmpp/pdu"
 )
@@ -25,95 +26,154 @@ var testCaseTracker = make(map[int32]*TestCase)    // Track PDU test case based
 
 var testCases []TestCase // replace with actual type
 
+var translitTable TranslitTable // nil selects the default transliteration table
+
+var gsmPacking GSMPacking // GSM 7-bit packing of the selected profile
+
+// subcommands run instead of the test-case runner when named as the first argument.
+var subcommands = map[string]func(args []string) int{
+	"cost":     runCost,
+	"generate": runGenerate,
+	"lint":     runLint,
+	"negative": runNegative,
+	"pdu":      runPDU,
+	"predict":  runPredict,
+	"schema":   runSchema,
+}
+
+// packingFlag defines the -packing flag shared by the offline subcommands.
+// Its default comes from SMPP_GSM_PACKING; an invalid value there exits
+// like an invalid flag.
+func packingFlag(fs *flag.FlagSet) *GSMPacking {
+	p := new(GSMPacking)
+	if env := os.Getenv("SMPP_GSM_PACKING"); env != "" {
+		if err := p.Set(env); err != nil {
+			color.Red("invalid SMPP_GSM_PACKING: %v", err)
+			os.Exit(2)
+		}
+	}
+	fs.Var(p, "packing", "GSM 7-bit packing `MODE` of short_message the SMSC expects: unpacked or packed")
+	return p
+}
+
 func main() {
+	if len(os.Args) > 1 {
+		if run, ok := subcommands[os.Args[1]]; ok {
+			os.Exit(run(os.Args[2:]))
+		}
+	}
+
+	filePath := flag.String("file", "test-case.jsonl", "path to JSON/JSONL file containing test cases")
+	profile := flag.String("profile", "", "SMSC profile to load from .env.<profile> (selects host, credentials and SMPP version)")
+	flag.Parse()
 
 	var wg sync.WaitGroup
 
 	wg.Add(1)
-	go sendingAndReceiveSMS(&wg)
+	go sendingAndReceiveSMS(&wg, *filePath, *profile)
 
 	wg.Wait()
 
 }
 
-func sendingAndReceiveSMS(wg *sync.WaitGroup) {
-	testCases, parserError := parseFile("test-case.jsonl")
+func sendingAndReceiveSMS(wg *sync.WaitGroup, filePath, profile string) {
+	testCases, parserError := parseFile(filePath)
 
 	if parserError != nil {
-		color.Green("Rebinding but error:", parserError)
-		//fmt.Fprintf(os.Stderr, "error parsing file: %v\n", parserError)
+		color.Red("error parsing file: %v", parserError)
 		os.Exit(1)
 	}
 
 	defer wg.Done()
-	_ = godotenv.Load() // ignore error; .env is optional
-	server := os.Getenv("SMPP_HOST") + ":" + os.Getenv("SMPP_PORT")
-	systemId := os.Getenv("SYSTEM_ID")
-	password := os.Getenv("PASSWORD")
-
-	auth := gosmpp.Auth{
-		SMSC:       server,
-		SystemID:   systemId,
-		Password:   password,
-		SystemType: "",
-	}
-	var TLSDialer = func(addr string) (net.Conn, error) {
-		conf := &tls.Config{
-			InsecureSkipVerify: true,
+	cfg, err := LoadProfile(profile)
+	if err != nil {
+		log.Fatal(err)
+	}
+	cfg.EnquireLink = 5 * time.Second
+	cfg.ReadTimeout = 10 * time.Second
+	gsmPacking = cfg.GSMPacking
+	if cfg.TranslitTable != "" {
+		if translitTable, err = LoadTranslitTable(cfg.TranslitTable); err != nil {
+			log.Fatal(err)
 		}
-		return tls.Dial("tcp", addr, conf)
 	}
-	var trans *gosmpp.Session
-	var err error
-	trans, err = gosmpp.NewSession(
-		gosmpp.TRXConnector(TLSDialer, auth),
-		gosmpp.Settings{
-			EnquireLink: 5 * time.Second,
-
-			ReadTimeout: 10 * time.Second,
-
-			OnSubmitError: func(_ pdu.PDU, err error) {
-				log.Fatal("SubmitPDU error:", err)
-			},
-
-			OnReceivingError: func(err error) {
-				color.Green("Receiving PDU/Network error:", err)
-			},
-
-			OnRebindingError: func(err error) {
-				color.Green("Rebinding but error:", err)
-			},
 
-			OnPDU: handlePDU(&trans),
-
-			OnClosed: func(state gosmpp.State) {
-				color.Green("State :", state)
-			},
-		}, 5*time.Second)
-
-	if err != nil {
+	// The transceiver and an outbind receiver may both deliver PDUs.
+	var handleMu sync.Mutex
+	handle := handlePDU()
+	onPDU := func(p pdu.PDU) {
+		handleMu.Lock()
+		defer handleMu.Unlock()
+		handle(p)
+	}
+	client := NewClient(cfg)
+	client.HandlePDU(onPDU)
+	if cfg.Host == "" && len(cfg.Endpoints) == 0 {
+		// A receiver bound through outbind cannot submit: only report what the SMSC delivers.
+		color.Yellow("Outbind only: %d test case(s) not sent, receiving until interrupted", len(testCases))
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+		if err = client.Listen(ctx); err != nil && ctx.Err() == nil {
+			log.Fatal(err)
+		} else if err == nil {
+			color.Green("Bound as receiver with SMPP interface version 0x%02X", client.InterfaceVersion())
+			<-ctx.Done()
+		}
+		_ = client.Close()
+		return
+	}
+	if err = client.Connect(); err != nil {
 		log.Fatal(err)
 	}
-
-	defer func() {
-		_ = trans.Close()
-	}()
+	color.Green("Bound with SMPP interface version 0x%02X", client.InterfaceVersion())
+	if cfg.OutbindAddr != "" {
+		// Receipts may also come through an SMSC-initiated receiver session.
+		receiver := NewClient(cfg)
+		receiver.HandlePDU(onPDU)
+		receiver.capture = client.capture.share() // one capture records both sessions
+		ctx, stopListening := context.WithCancel(context.Background())
+		listening := make(chan struct{})
+		go func() {
+			defer close(listening)
+			if err := receiver.Listen(ctx); err != nil && ctx.Err() == nil {
+				color.Red("Outbind: %v", err)
+			}
+		}()
+		defer func() {
+			stopListening()
+			<-listening
+			_ = receiver.Close()
+		}()
+	}
 
 	// sending SMS(s)
 	for i, testCase := range testCases {
 		color.Green("Test #%d:\n", i+1)
 
-		if err = trans.Transceiver().Submit(newSubmitSM(testCase)); err != nil {
+		if err = client.SendSMS(newSubmitSM(testCase)); err != nil {
 			color.Red("Error ", err)
 		}
 		time.Sleep(time.Second)
 	}
 
+	// wait for the responses to the last submits before unbinding
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	report, err := client.Shutdown(ctx, ShutdownOptions{})
+	if err != nil {
+		color.Red("Shutdown: %v", err)
+	}
+	for _, seq := range report.Unanswered {
+		if tc, ok := testCaseTracker[seq]; ok {
+			color.Red("No response received for TestCase %d (sequence %d)", tc.TestCaseId, seq)
+		}
+	}
 }
 
-func handlePDU(trans **gosmpp.Session) func(pdu.PDU, bool) {
+func handlePDU() func(pdu.PDU) {
 	concatenated := map[uint8][]string{}
-	return func(p pdu.PDU, _ bool) {
+	byMessageID := map[string]*TestCase{} // for matching delivery receipts
+	return func(p pdu.PDU) {
 		// Print out the received PDU type and details
 		switch responsePdu := p.(type) {
 		case *pdu.SubmitSMResp:
@@ -136,7 +196,15 @@ func handlePDU(trans **gosmpp.Session) func(pdu.PDU, bool) {
 					)
 					os.Exit(1)
 				}
-
+				if mismatches := checkTLVs(expectedOutput.TLVs, responsePdu.OptionalParameters, nil); len(mismatches) > 0 {
+					color.Red("TLV mismatch with TestCase %d:", testCase.TestCaseId)
+					for _, m := range mismatches {
+						color.Red("  %s", m)
+					}
+				}
+				if responsePdu.MessageID != "" {
+					byMessageID[responsePdu.MessageID] = testCase
+				}
 			}
 		case *pdu.GenericNack:
 			color.Green("GenericNack Received")
@@ -149,6 +217,25 @@ func handlePDU(trans **gosmpp.Session) func(pdu.PDU, bool) {
 
 		case *pdu.DeliverSM:
 			color.Green("DeliverSM:%+v\n", responsePdu)
+			if len(responsePdu.OptionalParameters) > 0 {
+				color.Green("DeliverSM TLVs: %s", formatTLVs(responsePdu.OptionalParameters))
+			}
+			if id, ok := receiptMessageID(responsePdu); ok {
+				if testCase, ok := byMessageID[id]; ok {
+					if mismatches := checkTLVs(testCase.ExpectedOutput.DeliverTLVs, responsePdu.OptionalParameters, responsePdu.Message.Encoding()); len(mismatches) > 0 {
+						color.Red("DeliverSM TLV mismatch with TestCase %d:", testCase.TestCaseId)
+						for _, m := range mismatches {
+							color.Red("  %s", m)
+						}
+					}
+					delete(byMessageID, id)
+				}
+			}
+			if isBinaryMessage(&responsePdu.Message, nil) {
+				payload, _ := responsePdu.Message.GetMessageData()
+				color.Green(describeBinary(responsePdu.Message.UDH(), payload))
+				return
+			}
 			color.Green(responsePdu.Message.GetMessage())
 			message, err := responsePdu.Message.GetMessage()
 			if err != nil {
@@ -171,10 +258,6 @@ func handlePDU(trans **gosmpp.Session) func(pdu.PDU, bool) {
 
 		case *pdu.UnbindResp:
 			color.Green("UnbindResp:%+v\n", responsePdu)
-			color.Green("UnbindResp received — closing session...")
-			if *trans != nil {
-				_ = (*trans).Close()
-			}
 
 		default:
 			// Handling unhandled PDUs
@@ -184,44 +267,177 @@ func handlePDU(trans **gosmpp.Session) func(pdu.PDU, bool) {
 }
 
 func newSubmitSM(testcase TestCase) *pdu.SubmitSM {
-	requestPDU := testcase.InputPdu
-
-	// build up submitSM
-	srcAddr := pdu.NewAddress()
+	submitSM, notes, err := buildSubmitSM(testcase.InputPdu, gsmPacking, translitTable)
+	for _, note := range notes {
+		color.Yellow("TestCase %d: %s", testcase.TestCaseId, note)
+	}
+	if err != nil {
+		log.Fatalf("TestCase %d: %v", testcase.TestCaseId, err)
+	}
+	// Track the request by sequence_number
+	requestTracker[submitSM.SequenceNumber] = submitSM
+	testCaseTracker[submitSM.SequenceNumber] = &testcase
 
-	srcAddr.SetTon(byte(*requestPDU.SourceAddrTON))
-	srcAddr.SetNpi(byte(*requestPDU.SourceAddrNPI))
-	_ = srcAddr.SetAddress(*requestPDU.SourceAddr)
+	return submitSM
+}
 
-	destAddr := pdu.NewAddress()
-	if requestPDU.DestAddrTON != nil {
-		destAddr.SetTon(byte(*requestPDU.DestAddrTON))
+// buildSubmitSM builds the submit_sm for a test case. Fields the test case
+// omits are sent as zero; TON/NPI missing from the test case are inferred.
+// notes are warnings worth showing, such as addresses an SMSC may reject
+// and transliterated characters.
+func buildSubmitSM(requestPDU InputPDU, packing GSMPacking, table TranslitTable) (*pdu.SubmitSM, []string, error) {
+	var notes []string
+	octet := func(p *int) byte {
+		if p == nil {
+			return 0
+		}
+		return byte(*p)
 	}
-	if requestPDU.DestAddrNPI != nil {
-		destAddr.SetNpi(byte(*requestPDU.DestAddrNPI))
+
+	srcAddr := pdu.NewAddress()
+	if requestPDU.SourceAddr != nil {
+		src := testCaseAddress(*requestPDU.SourceAddr, requestPDU.SourceAddrTON, requestPDU.SourceAddrNPI)
+		if err := ValidateAddress(src); err != nil {
+			notes = append(notes, fmt.Sprintf("source_addr: %v", err))
+		}
+		srcAddr.SetTon(src.TON)
+		srcAddr.SetNpi(src.NPI)
+		if err := srcAddr.SetAddress(src.Addr); err != nil {
+			notes = append(notes, fmt.Sprintf("source_addr: %v", err))
+		}
 	}
 
+	destAddr := pdu.NewAddress()
 	if requestPDU.DestinationAddr != nil {
-		err := destAddr.SetAddress(*requestPDU.DestinationAddr)
-		if err != nil {
-			log.Fatal(err)
+		dest := testCaseAddress(*requestPDU.DestinationAddr, requestPDU.DestAddrTON, requestPDU.DestAddrNPI)
+		if err := ValidateAddress(dest); err != nil {
+			notes = append(notes, fmt.Sprintf("destination_addr: %v", err))
+		}
+		destAddr.SetTon(dest.TON)
+		destAddr.SetNpi(dest.NPI)
+		if err := destAddr.SetAddress(dest.Addr); err != nil {
+			return nil, notes, fmt.Errorf("destination_addr: %w", err)
 		}
 	}
 
 	submitSM := pdu.NewSubmitSM().(*pdu.SubmitSM)
 	submitSM.SourceAddr = srcAddr
 	submitSM.DestAddr = destAddr
-	dataCode := byteToDataCoding(byte(*requestPDU.DataCoding))
-	_ = submitSM.Message.SetMessageWithEncoding(*requestPDU.ShortMessage, dataCode)
-	submitSM.ProtocolID = byte(*requestPDU.ProtocolID)
-	submitSM.RegisteredDelivery = byte(*requestPDU.RegisteredDelivery)
-	submitSM.ReplaceIfPresentFlag = byte(*requestPDU.ReplaceIfPresentFlag)
-	submitSM.EsmClass = byte(*requestPDU.EsmClass)
-	// Track the request by sequence_number
-	requestTracker[submitSM.SequenceNumber] = submitSM
-	testCaseTracker[submitSM.SequenceNumber] = &testcase
+	dataCoding := octet(requestPDU.DataCoding)
+	dataCode, err := byteToDataCoding(dataCoding)
+	if err != nil {
+		return nil, notes, err
+	}
+	gsm := DecodeDCS(dataCoding).Alphabet == AlphabetGSM7
+	message := ""
+	if requestPDU.ShortMessage != nil {
+		message = *requestPDU.ShortMessage
+	}
+	if gsm && requestPDU.Transliterate != nil && *requestPDU.Transliterate {
+		var subs []Substitution
+		message, subs = transliterateForGSM(message, table, nil)
+		for _, sub := range subs {
+			notes = append(notes, fmt.Sprintf("transliterated %s", sub))
+		}
+	}
+	udh, err := testCaseUDH(requestPDU)
+	if err != nil {
+		return nil, notes, err
+	}
+	if payload, ok, err := testCasePayload(requestPDU); err != nil {
+		return nil, notes, err
+	} else if ok {
+		_ = submitSM.Message.SetMessageDataWithEncoding(payload, dataCode)
+	} else if gsm {
+		// Send the exact user data, UDH first, in the profile's packing,
+		// encoded with the shift tables its national language IEs select.
+		septets, err := nationalTables(udh).encode(message)
+		if err != nil {
+			return nil, notes, fmt.Errorf("short_message: %w", err)
+		}
+		_ = submitSM.Message.SetMessageDataWithEncoding(gsmUserData(septets, udh, packing), dataCode)
+		if len(udh) > 0 {
+			submitSM.EsmClass |= 0x40 // UDHI
+			udh = nil
+		}
+	} else if DecodeDCS(dataCoding).Alphabet == Alphabet8Bit {
+		// Binary encodings have no text encoder: send the text's octets.
+		_ = submitSM.Message.SetMessageDataWithEncoding([]byte(message), dataCode)
+	} else {
+		_ = submitSM.Message.SetMessageWithEncoding(message, dataCode)
+	}
+	if err := attachTLVs(submitSM, requestPDU.TLVs, dataCode); err != nil {
+		return nil, notes, err
+	}
+	if requestPDU.ServiceType != nil {
+		submitSM.ServiceType = *requestPDU.ServiceType
+	}
+	submitSM.ProtocolID = octet(requestPDU.ProtocolID)
+	submitSM.PriorityFlag = octet(requestPDU.PriorityFlag)
+	submitSM.RegisteredDelivery = octet(requestPDU.RegisteredDelivery)
+	submitSM.ReplaceIfPresentFlag = octet(requestPDU.ReplaceIfPresentFlag)
+	submitSM.EsmClass |= octet(requestPDU.EsmClass)
+	if requestPDU.ScheduleDeliveryTime != nil {
+		if submitSM.ScheduleDeliveryTime, err = normalizeSMPPTime(*requestPDU.ScheduleDeliveryTime); err != nil {
+			return nil, notes, fmt.Errorf("schedule_delivery_time: %w", err)
+		}
+	}
+	if requestPDU.ValidityPeriod != nil {
+		if submitSM.ValidityPeriod, err = normalizeSMPPTime(*requestPDU.ValidityPeriod); err != nil {
+			return nil, notes, fmt.Errorf("validity_period: %w", err)
+		}
+	}
+	if len(udh) > 0 {
+		submitSM.Message.SetUDH(udh)
+		submitSM.EsmClass |= 0x40 // UDHI
+	}
+	return submitSM, notes, nil
+}
 
-	return submitSM
+// testCaseAddress applies the TON/NPI given in a test case, inferring the
+// missing ones from the address. Explicit values keep the address as written.
+func testCaseAddress(addr string, ton, npi *int) SMEAddress {
+	a := InferAddress(addr)
+	if ton != nil {
+		a.TON = byte(*ton)
+		a.Addr = addr
+	}
+	if npi != nil {
+		a.NPI = byte(*npi)
+	}
+	return a
+}
+
+// testCasePayload returns the binary short message of a test case, if any.
+func testCasePayload(in InputPDU) ([]byte, bool, error) {
+	switch {
+	case in.ShortMessageHex != nil:
+		b, err := decodePayload(*in.ShortMessageHex, false)
+		return b, true, err
+	case in.ShortMessageBase64 != nil:
+		b, err := decodePayload(*in.ShortMessageBase64, true)
+		return b, true, err
+	}
+	return nil, false, nil
+}
+
+// testCaseUDH builds the UDH of a test case from udh_hex and the port fields.
+func testCaseUDH(in InputPDU) (pdu.UDH, error) {
+	var m BinaryMessage
+	if in.UDHHex != nil {
+		raw, err := decodePayload(*in.UDHHex, false)
+		if err != nil {
+			return nil, fmt.Errorf("udh_hex: %w", err)
+		}
+		m.RawUDH = raw
+	}
+	if in.UDHDestPort != nil {
+		m.DestPort = *in.UDHDestPort
+		if in.UDHSourcePort != nil {
+			m.SourcePort = *in.UDHSourcePort
+		}
+	}
+	return m.udh()
 }
 
 func isConcatenatedDone(parts []string, total byte) bool {
@@ -233,32 +449,11 @@ func isConcatenatedDone(parts []string, total byte) bool {
 	return total == 0
 }
 
-// FromDataCodingExtended maps a Data Coding Scheme (DCS) byte value
-// to its corresponding Encoding implementation, including GSM7BITPACKED.
-func byteToDataCoding(code byte) data.Encoding {
-	switch code {
-	case data.UCS2Coding:
-		return data.UCS2
-	case data.HEBREWCoding:
-		return data.HEBREW
-	case data.CYRILLICCoding:
-		return data.CYRILLIC
-	case data.BINARY8BIT2Coding:
-		return data.BINARY8BIT2
-	case data.LATIN1Coding:
-		return data.LATIN1
-	case data.BINARY8BIT1Coding:
-		return data.BINARY8BIT1
-	case data.ASCIICoding:
-		return data.ASCII
-	case data.GSM7BITCoding:
-		// You can choose to default to GSM7BIT or GSM7BITPACKED here.
-		// Typically GSM7BIT is the standard, but if you need the packed variant, return GSM7BITPACKED instead.
-		return data.GSM7BIT
-	default:
-		// Fallback: assume GSM7BITPACKED if unknown coding is given
-		return data.GSM7BITPACKED
-	}
+// byteToDataCoding maps a data_coding octet to its gosmpp encoding. Values
+// carrying a message class or MWI group keep their original octet on the wire;
+// alphabets gosmpp cannot encode are reported instead of guessed.
+func byteToDataCoding(code byte) (data.Encoding, error) {
+	return DecodeDCS(code).Encoding()
 }
 
 func connectToHtppServerUsingTCP() {
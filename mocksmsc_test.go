@@ -0,0 +1,340 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/linxGnu/gosmpp/data"
+	"github.com/linxGnu/gosmpp/pdu"
+)
+
+// mockSMSC is a minimal SMSC for tests. It binds any ESME, accepts every
+// submit_sm and, for those requesting a success receipt, sends a delivery
+// receipt once the message is due: at schedule_delivery_time, or at once
+// when the message is not scheduled. SMPP 5.0 broadcasts are accepted,
+// queried and cancelled too.
+type mockSMSC struct {
+	t  *testing.T
+	ln net.Listener
+
+	mu sync.Mutex
+	// refuse makes binds fail with ESME_RBINDFAIL.
+	refuse bool
+	// version is returned as sc_interface_version on bind, if not zero.
+	version    byte
+	binds      int
+	conns      map[net.Conn]bool
+	nextID     int
+	messages   map[string]*mockMessage
+	broadcasts map[string]bool
+}
+
+// mockMessage is a submit_sm accepted by the mock SMSC.
+type mockMessage struct {
+	ID          string
+	Submit      *pdu.SubmitSM
+	SubmittedAt time.Time
+	DeliverAt   time.Time
+	// Delivered is closed once the message is delivered, at DeliveredAt.
+	Delivered   chan struct{}
+	DeliveredAt time.Time
+}
+
+func newMockSMSC(t *testing.T) *mockSMSC {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := &mockSMSC{
+		t:          t,
+		ln:         ln,
+		conns:      map[net.Conn]bool{},
+		messages:   map[string]*mockMessage{},
+		broadcasts: map[string]bool{},
+	}
+	t.Cleanup(func() {
+		_ = ln.Close()
+		m.drop()
+	})
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go m.serve(conn)
+		}
+	}()
+	return m
+}
+
+// plainTCP makes the client dial in plain TCP for the rest of the test.
+func plainTCP(t *testing.T) {
+	dial := TLSDialer
+	TLSDialer = func(addr string) (net.Conn, error) { return net.Dial("tcp", addr) }
+	t.Cleanup(func() { TLSDialer = dial })
+}
+
+// config returns a client configuration for the mock, dialed in plain TCP.
+func (m *mockSMSC) config(t *testing.T) Config {
+	plainTCP(t)
+	ep := m.endpoint(0)
+	return Config{
+		Host:             ep.Host,
+		Port:             ep.Port,
+		SystemID:         "test",
+		Password:         "secret",
+		EnquireLink:      time.Second,
+		ReadTimeout:      5 * time.Second,
+		InterfaceVersion: SMPPVersion34,
+	}
+}
+
+// endpoint returns the mock's address as an endpoint of the given priority.
+func (m *mockSMSC) endpoint(priority int) Endpoint {
+	host, port, _ := net.SplitHostPort(m.ln.Addr().String())
+	return Endpoint{Host: host, Port: port, Priority: priority}
+}
+
+// setRefuse makes the mock refuse or accept binds.
+func (m *mockSMSC) setRefuse(refuse bool) {
+	m.mu.Lock()
+	m.refuse = refuse
+	m.mu.Unlock()
+}
+
+// setVersion sets the sc_interface_version returned on bind.
+func (m *mockSMSC) setVersion(version byte) {
+	m.mu.Lock()
+	m.version = version
+	m.mu.Unlock()
+}
+
+// bindCount returns the number of binds the mock accepted.
+func (m *mockSMSC) bindCount() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.binds
+}
+
+// drop closes every open connection, as a crashing SMSC would.
+func (m *mockSMSC) drop() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for conn := range m.conns {
+		_ = conn.Close()
+	}
+}
+
+// message returns the message the mock accepted as id.
+func (m *mockSMSC) message(id string) *mockMessage {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.messages[id]
+}
+
+func (m *mockSMSC) serve(conn net.Conn) {
+	m.mu.Lock()
+	m.conns[conn] = true
+	m.mu.Unlock()
+	defer func() {
+		m.mu.Lock()
+		delete(m.conns, conn)
+		m.mu.Unlock()
+		_ = conn.Close()
+	}()
+
+	var writeMu sync.Mutex
+	writeFrame := func(frame []byte) {
+		writeMu.Lock()
+		defer writeMu.Unlock()
+		_, _ = conn.Write(frame)
+	}
+	write := func(p pdu.PDU) {
+		buf := pdu.NewBuffer(nil)
+		p.Marshal(buf)
+		writeFrame(buf.Bytes())
+	}
+	nack := func(seq int32, status data.CommandStatusType) {
+		n := pdu.NewGenericNack().(*pdu.GenericNack)
+		n.SequenceNumber = seq
+		n.CommandStatus = status
+		write(n)
+	}
+	var receiptSeq int32
+	for {
+		frame, err := readMockFrame(conn)
+		if err != nil {
+			return
+		}
+		p, err := pdu.Parse(bytes.NewReader(frame))
+		if err != nil {
+			// Commands gosmpp does not know, such as the broadcasts.
+			if resp := m.serveRaw(frame); resp != nil {
+				writeFrame(resp)
+			}
+			continue
+		}
+		switch p := p.(type) {
+		case *pdu.BindRequest:
+			resp := pdu.NewBindResp(*p)
+			resp.SystemID = "mock"
+			m.mu.Lock()
+			if m.refuse {
+				resp.CommandStatus = data.ESME_RBINDFAIL
+			} else {
+				m.binds++
+				if m.version != 0 {
+					resp.RegisterOptionalParam(pdu.Field{Tag: pdu.Tag(tagScInterfaceVersion), Data: []byte{m.version}})
+				}
+			}
+			m.mu.Unlock()
+			write(resp)
+		case *pdu.EnquireLink:
+			write(p.GetResponse())
+		case *pdu.Unbind:
+			write(p.GetResponse())
+			return
+		case *pdu.SubmitSM:
+			msg, status := m.accept(p)
+			resp := p.GetResponse().(*pdu.SubmitSMResp)
+			resp.CommandStatus = status
+			if status != data.ESME_ROK {
+				write(resp)
+				continue
+			}
+			resp.MessageID = msg.ID
+			write(resp)
+			// Every message is delivered: only value 1 asks for that receipt.
+			if p.RegisteredDelivery&0x03 != 1 {
+				continue
+			}
+			time.AfterFunc(time.Until(msg.DeliverAt), func() {
+				m.mu.Lock()
+				msg.DeliveredAt = time.Now()
+				receiptSeq++
+				seq := receiptSeq
+				m.mu.Unlock()
+				write(mockReceipt(msg, seq))
+				close(msg.Delivered)
+			})
+		case *pdu.DeliverSMResp:
+		default:
+			nack(p.GetSequenceNumber(), data.ESME_RINVCMDID)
+		}
+	}
+}
+
+// serveRaw answers the SMPP 5.0 broadcast operations, which gosmpp cannot
+// parse.
+func (m *mockSMSC) serveRaw(frame []byte) []byte {
+	h, err := parsePDUHeader(frame)
+	if err != nil {
+		return nil
+	}
+	r := newPDUReader(frame[pduHeaderLen:h.Length])
+	var resp pduBuilder
+	switch h.CommandID {
+	case cmdBroadcastSM:
+		m.mu.Lock()
+		m.nextID++
+		id := "B" + strconv.Itoa(m.nextID)
+		m.broadcasts[id] = true
+		m.mu.Unlock()
+		resp.cstring(id)
+		return resp.bytes(cmdBroadcastSMResp, ESME_ROK, h.Sequence)
+	case cmdQueryBroadcastSM:
+		id := r.cstring("message_id")
+		m.mu.Lock()
+		active, ok := m.broadcasts[id]
+		m.mu.Unlock()
+		if !ok {
+			return resp.bytes(cmdQueryBroadcastSMResp, ESME_RQUERYFAIL, h.Sequence)
+		}
+		state := byte(2) // DELIVERED
+		if active {
+			state = 1 // ENROUTE
+		}
+		resp.cstring(id)
+		resp.tlv(tagMessageState, []byte{state})
+		resp.tlv(tagBroadcastAreaSuccess, []byte{100})
+		return resp.bytes(cmdQueryBroadcastSMResp, ESME_ROK, h.Sequence)
+	case cmdCancelBroadcastSM:
+		r.cstring("service_type")
+		id := r.cstring("message_id")
+		m.mu.Lock()
+		active, ok := m.broadcasts[id]
+		if ok && active {
+			m.broadcasts[id] = false
+		}
+		m.mu.Unlock()
+		if !ok || !active {
+			return resp.bytes(cmdCancelBroadcastResp, ESME_RCANCELFAIL, h.Sequence)
+		}
+		return resp.bytes(cmdCancelBroadcastResp, ESME_ROK, h.Sequence)
+	}
+	return resp.bytes(cmdGenericNack, ESME_RINVCMDID, h.Sequence)
+}
+
+// readMockFrame reads one PDU from r.
+func readMockFrame(r io.Reader) ([]byte, error) {
+	var length [4]byte
+	if _, err := io.ReadFull(r, length[:]); err != nil {
+		return nil, err
+	}
+	n := binary.BigEndian.Uint32(length[:])
+	if n < 16 || n > 64*1024 {
+		return nil, fmt.Errorf("command_length %d", n)
+	}
+	frame := make([]byte, n)
+	copy(frame, length[:])
+	_, err := io.ReadFull(r, frame[4:])
+	return frame, err
+}
+
+// accept stores sm and works out when it is due.
+func (m *mockSMSC) accept(sm *pdu.SubmitSM) (*mockMessage, data.CommandStatusType) {
+	now := time.Now()
+	deliverAt, err := ParseSMPPTime(sm.ScheduleDeliveryTime, now)
+	if err != nil {
+		return nil, data.ESME_RINVSCHED
+	}
+	if deliverAt.Before(now) {
+		deliverAt = now
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.nextID++
+	msg := &mockMessage{
+		ID:          strconv.Itoa(m.nextID),
+		Submit:      sm,
+		SubmittedAt: now,
+		DeliverAt:   deliverAt,
+		Delivered:   make(chan struct{}),
+	}
+	m.messages[msg.ID] = msg
+	return msg, data.ESME_ROK
+}
+
+// mockReceipt is the deliver_sm receipt for msg.
+func mockReceipt(msg *mockMessage, seq int32) pdu.PDU {
+	text := fmt.Sprintf("id:%s sub:001 dlvrd:001 submit date:%s done date:%s stat:DELIVRD err:000 text:",
+		msg.ID, msg.SubmittedAt.Format("0601021504"), msg.DeliveredAt.Format("0601021504"))
+	d := pdu.NewDeliverSM().(*pdu.DeliverSM)
+	d.SequenceNumber = seq
+	d.SourceAddr = msg.Submit.DestAddr
+	d.DestAddr = msg.Submit.SourceAddr
+	d.EsmClass = 0x04 // delivery receipt
+	_ = d.Message.SetMessageWithEncoding(text, data.GSM7BIT)
+	d.RegisterOptionalParam(pdu.Field{Tag: pdu.TagReceiptedMessageID, Data: append([]byte(msg.ID), 0)})
+	d.RegisterOptionalParam(pdu.Field{Tag: pdu.TagMessageStateOption, Data: []byte{2}}) // DELIVERED
+	return d
+}
@@ -18,6 +18,20 @@ type InputPDU struct {
 	Encoding             *string `json:"encoding,omitempty"`    // "7-bit" or "16-bit" (informational)
 	SmLength             *int    `json:"sm_length,omitempty"`
 	ShortMessage         *string `json:"short_message,omitempty"`
+	// SMPP time strings (YYMMDDhhmmsstnnp or YYMMDDhhmmss000R); RFC 3339
+	// timestamps and Go durations such as "90m" are converted.
+	ScheduleDeliveryTime *string   `json:"schedule_delivery_time,omitempty"`
+	ValidityPeriod       *string   `json:"validity_period,omitempty"`
+	Transliterate        *bool     `json:"transliterate,omitempty"` // replace non-GSM characters when data_coding is 0
+	TLVs                 []TLVSpec `json:"tlvs,omitempty"`          // optional parameters attached to the PDU
+
+	// Binary payloads replace short_message. udh_hex is a raw UDH including
+	// its length octet; udh_dest_port/udh_source_port add port addressing.
+	ShortMessageHex    *string `json:"short_message_hex,omitempty"`
+	ShortMessageBase64 *string `json:"short_message_base64,omitempty"`
+	UDHHex             *string `json:"udh_hex,omitempty"`
+	UDHSourcePort      *int    `json:"udh_source_port,omitempty"`
+	UDHDestPort        *int    `json:"udh_dest_port,omitempty"`
 }
 
 // ExpectedOutput models the "expected_output_pdu" object in your data.
@@ -25,6 +39,11 @@ type ExpectedOutput struct {
 	CommandID     *string `json:"command_id,omitempty"`
 	CommandStatus *int    `json:"command_status,omitempty"`
 	MessageID     *string `json:"message_id,omitempty"`
+	// DeliveryStatus is "accepted" or "failed".
+	DeliveryStatus *string `json:"delivery_status,omitempty"`
+	// TLVs are expected on the response; DeliverTLVs on the delivery receipt.
+	TLVs        []TLVSpec `json:"tlvs,omitempty"`
+	DeliverTLVs []TLVSpec `json:"deliver_sm_tlvs,omitempty"`
 }
 
 // TestCase ties an input PDU with its expected output.
@@ -38,17 +57,22 @@ type TestCase struct {
 
 // ValidationResult holds computed validation details for each test case.
 type ValidationResult struct {
-	Index               int      `json:"index"`
-	Valid               bool     `json:"valid"`
-	Errors              []string `json:"errors,omitempty"`
-	ComputedSmLength    int      `json:"computed_sm_length"`
+	Index  int      `json:"index"`
+	Valid  bool     `json:"valid"`
+	Errors []string `json:"errors,omitempty"`
+	// Violations are the field-level errors behind Errors, with the
+	// command_status an SMSC is expected to return.
+	Violations       []Violation `json:"violations,omitempty"`
+	ComputedSmLength int         `json:"computed_sm_length"`
+	// PredictedStatus is the command_status a compliant SMSC should return.
+	// It is meaningless when TestDataError is set.
+	PredictedStatus CommandStatus `json:"predicted_status"`
+	// TestDataError is set when the test case itself is wrong in a way the
+	// runner never puts on the wire (a stale sm_length, an undecodable UDH or
+	// binary payload), so no SMSC response can be predicted.
+	TestDataError       bool     `json:"test_data_error,omitempty"`
 	Segments            int      `json:"segments"`
 	Note                string   `json:"note,omitempty"`
 	ExpectedOutputMatch bool     `json:"expected_output_match"`
 	Mismatches          []string `json:"mismatches,omitempty"`
 }
-
-// We build GSM 03.38 default and extended character sets as rune->bool maps.
-// This is a pragmatic subset based on the standard (sufficient for typical text).
-var gsmDefault map[rune]bool
-var gsmExtended map[rune]bool
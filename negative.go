@@ -0,0 +1,464 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/fatih/color"
+)
+
+// NegativeExpectation is what a negative test accepts from the SMSC: one of
+// Responses with one of Statuses, or the connection being closed when Close
+// is set. An empty Statuses accepts any error status.
+type NegativeExpectation struct {
+	Responses []string `json:"responses"`
+	Statuses  []string `json:"statuses,omitempty"`
+	Close     bool     `json:"close,omitempty"`
+}
+
+// NegativeTestCase sends a hand-crafted PDU on a fresh connection. The PDU
+// is given as raw_hex or as a PDU description, as the pdu subcommand reads
+// them. Bind defaults to true; a false Bind sends the PDU before binding.
+type NegativeTestCase struct {
+	TestCaseID int                 `json:"test_case_id"`
+	Name       string              `json:"name"`
+	Bind       *bool               `json:"bind,omitempty"`
+	RawHex     string              `json:"raw_hex,omitempty"`
+	PDU        *PDUDescription     `json:"pdu,omitempty"`
+	Expect     NegativeExpectation `json:"expect"`
+}
+
+// NegativeResult is the outcome of a negative test: "response", "closed",
+// "timeout" or "error".
+type NegativeResult struct {
+	TestCaseID int    `json:"test_case_id"`
+	Name       string `json:"name"`
+	Outcome    string `json:"outcome"`
+	Response   string `json:"response,omitempty"`
+	Passed     bool   `json:"passed"`
+	Reason     string `json:"reason,omitempty"`
+}
+
+// negativeSequence is the sequence number of the malformed PDU; the bind
+// uses 1.
+const negativeSequence = 2
+
+// frame returns the octets tc sends.
+func (tc NegativeTestCase) frame(packing GSMPacking) ([]byte, error) {
+	switch {
+	case tc.RawHex != "":
+		return decodePayload(tc.RawHex, false)
+	case tc.PDU != nil:
+		return EncodePDU(*tc.PDU, packing)
+	}
+	return nil, errors.New("neither raw_hex nor pdu given")
+}
+
+func (tc NegativeTestCase) binds() bool {
+	return tc.Bind == nil || *tc.Bind
+}
+
+// negativeSubmitSM is the well-formed submit_sm the built-in cases corrupt.
+func negativeSubmitSM(cfg Config, sequence uint32) []byte {
+	sm := rawSubmitSM{
+		SourceAddrTON:   5,
+		SourceAddr:      "ACME",
+		DestAddrTON:     1,
+		DestAddrNPI:     1,
+		DestinationAddr: "447700900001",
+		ShortMessage:    []byte("negative test"),
+	}
+	if cfg.SourceAddr != "" {
+		sm.SourceAddr = cfg.SourceAddr
+	}
+	if cfg.DestAddr != "" {
+		sm.DestinationAddr = cfg.DestAddr
+	}
+	frame, err := sm.bytes(sequence)
+	if err != nil {
+		// Only NUL octets in the configured addresses get here.
+		frame, _ = rawSubmitSM{ShortMessage: sm.ShortMessage}.bytes(sequence)
+	}
+	return frame
+}
+
+// withLength returns a copy of frame declaring command_length n.
+func withLength(frame []byte, n uint32) []byte {
+	out := append([]byte(nil), frame...)
+	binary.BigEndian.PutUint32(out[0:4], n)
+	return out
+}
+
+// withSequence returns a copy of frame with sequence_number seq.
+func withSequence(frame []byte, seq uint32) []byte {
+	out := append([]byte(nil), frame...)
+	binary.BigEndian.PutUint32(out[12:16], seq)
+	return out
+}
+
+// defaultNegativeTests are the built-in malformed PDUs. Framing errors
+// desynchronise the stream, so closing the connection is accepted for
+// them; the others must be answered.
+func defaultNegativeTests(cfg Config) []NegativeTestCase {
+	submit := negativeSubmitSM(cfg, negativeSequence)
+	empty := func(commandID uint32) []byte {
+		var b pduBuilder
+		return b.bytes(commandID, ESME_ROK, negativeSequence)
+	}
+	nack := func(close bool, statuses ...CommandStatus) NegativeExpectation {
+		e := NegativeExpectation{Responses: []string{"generic_nack"}, Close: close}
+		for _, s := range statuses {
+			e.Statuses = append(e.Statuses, s.String())
+		}
+		return e
+	}
+	orResp := func(e NegativeExpectation) NegativeExpectation {
+		e.Responses = append(e.Responses, "submit_sm_resp")
+		return e
+	}
+
+	// The mandatory fields up to destination_addr.
+	addressed := func(dest []byte) *pduBuilder {
+		var b pduBuilder
+		b.cstring("")
+		b.octet(5)
+		b.octet(0)
+		b.cstring("ACME")
+		b.octet(1)
+		b.octet(1)
+		b.octets(dest)
+		return &b
+	}
+	truncated := addressed([]byte("447700900001\x00"))
+	// destination_addr runs to the end of the PDU.
+	unterminated := addressed(bytes.Repeat([]byte("4477009000"), 4))
+
+	// Two message_payload TLVs take the PDU past 64 KiB.
+	var oversized pduBuilder
+	oversized.octets(submit[pduHeaderLen:])
+	oversized.tlv(tagMessagePayload, bytes.Repeat([]byte{'A'}, 0xFFFF))
+	oversized.tlv(tagMessagePayload, bytes.Repeat([]byte{'B'}, 0x1000))
+
+	unbound := false
+	tests := []NegativeTestCase{
+		{Name: "command_length below the header size",
+			RawHex: hex.EncodeToString(withLength(empty(cmdEnquireLink), 12)),
+			Expect: nack(true, ESME_RINVCMDLEN)},
+		{Name: "command_length above any PDU size",
+			RawHex: hex.EncodeToString(withLength(submit, 0x7FFFFFFF)),
+			Expect: nack(true, ESME_RINVCMDLEN)},
+		{Name: "command_length shorter than the PDU",
+			RawHex: hex.EncodeToString(withLength(submit, uint32(len(submit)-5))),
+			Expect: orResp(nack(true, ESME_RINVCMDLEN, ESME_RINVMSGLEN, ESME_RSYSERR))},
+		{Name: "truncated submit_sm body",
+			RawHex: hex.EncodeToString(truncated.bytes(cmdSubmitSM, ESME_ROK, negativeSequence)),
+			Expect: orResp(nack(true, ESME_RINVCMDLEN, ESME_RINVMSGLEN, ESME_RSYSERR))},
+		{Name: "missing C-string terminator",
+			RawHex: hex.EncodeToString(unterminated.bytes(cmdSubmitSM, ESME_ROK, negativeSequence)),
+			Expect: orResp(nack(true, ESME_RINVCMDLEN, ESME_RINVDSTADR, ESME_RSYSERR))},
+		{Name: "unknown command_id",
+			RawHex: hex.EncodeToString(empty(0x000000FF)),
+			Expect: nack(false, ESME_RINVCMDID)},
+		{Name: "oversized PDU",
+			RawHex: hex.EncodeToString(oversized.bytes(cmdSubmitSM, ESME_ROK, negativeSequence)),
+			Expect: orResp(nack(true, ESME_RINVCMDLEN, ESME_RINVMSGLEN))},
+		{Name: "sequence_number 0",
+			RawHex: hex.EncodeToString(withSequence(submit, 0)),
+			Expect: orResp(nack(false))},
+		{Name: "sequence_number above 0x7FFFFFFF",
+			RawHex: hex.EncodeToString(withSequence(submit, 0x80000000)),
+			Expect: orResp(nack(false))},
+		{Name: "submit_sm before bind", Bind: &unbound,
+			RawHex: hex.EncodeToString(submit),
+			Expect: orResp(nack(true, ESME_RINVBNDSTS))},
+	}
+	for i := range tests {
+		tests[i].TestCaseID = i + 1
+	}
+	return tests
+}
+
+// loadNegativeTests reads negative test cases from a JSON array or JSONL
+// file.
+func loadNegativeTests(path string) ([]NegativeTestCase, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	b = bytes.TrimPrefix(b, []byte{0xEF, 0xBB, 0xBF})
+	cases, err := splitTestCases(b)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	tests := make([]NegativeTestCase, 0, len(cases))
+	for n, c := range cases {
+		// Unknown keys are typos everywhere but in the header and body of
+		// pdu, which carry informational keys such as command_length_hex.
+		var strict struct {
+			NegativeTestCase
+			PDU json.RawMessage `json:"pdu,omitempty"`
+		}
+		dec := json.NewDecoder(bytes.NewReader(c.Raw))
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(&strict); err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", path, lineAt(b, c.Start), err)
+		}
+		tc := strict.NegativeTestCase
+		if len(strict.PDU) > 0 && string(strict.PDU) != "null" {
+			desc, err := parsePDUDescription(strict.PDU)
+			if err != nil {
+				return nil, fmt.Errorf("%s:%d: pdu: %w", path, lineAt(b, c.Start), err)
+			}
+			tc.PDU = &desc
+		}
+		if len(tc.Expect.Responses) == 0 && !tc.Expect.Close {
+			return nil, fmt.Errorf("%s:%d: expect gives neither responses nor close", path, lineAt(b, c.Start))
+		}
+		if tc.TestCaseID == 0 {
+			tc.TestCaseID = n + 1
+		}
+		tests = append(tests, tc)
+	}
+	return tests, nil
+}
+
+// rawBindFrame is a bind_transceiver for cfg.
+func rawBindFrame(cfg Config) []byte {
+	var b pduBuilder
+	b.cstring(cfg.SystemID)
+	b.cstring(cfg.Password)
+	b.cstring(cfg.SystemType)
+	b.octet(cfg.InterfaceVersion)
+	b.octet(0)
+	b.octet(0)
+	b.cstring("")
+	return b.bytes(cmdBindTransceiver, ESME_ROK, 1)
+}
+
+// readResponse returns the next frame that is not an SMSC-initiated
+// enquire_link or deliver_sm, answering those as a bound ESME would.
+func readResponse(p *pduConn) ([]byte, pduHeader, error) {
+	for {
+		frame, err := p.readFrame()
+		if err != nil {
+			return nil, pduHeader{}, err
+		}
+		h, _ := parsePDUHeader(frame)
+		var reply pduBuilder
+		switch h.CommandID {
+		case cmdEnquireLink:
+			err = p.writeFrame(reply.bytes(cmdEnquireLinkResp, ESME_ROK, h.Sequence))
+		case cmdDeliverSM:
+			reply.cstring("")
+			err = p.writeFrame(reply.bytes(cmdDeliverSMResp, ESME_ROK, h.Sequence))
+		default:
+			return frame, h, nil
+		}
+		if err != nil {
+			return nil, pduHeader{}, err
+		}
+	}
+}
+
+// isTimeout reports whether err is a read deadline expiring.
+func isTimeout(err error) bool {
+	var ne net.Error
+	return errors.As(err, &ne) && ne.Timeout()
+}
+
+// runNegativeTest sends tc on a new connection to addr and checks the
+// SMSC's reaction against tc.Expect. capture, when not nil, records the
+// connection.
+func runNegativeTest(cfg Config, addr string, tc NegativeTestCase, timeout time.Duration, packing GSMPacking, capture *Capture) NegativeResult {
+	res := NegativeResult{TestCaseID: tc.TestCaseID, Name: tc.Name, Outcome: "error"}
+	frame, err := tc.frame(packing)
+	if err != nil {
+		res.Reason = err.Error()
+		return res
+	}
+	conn, err := TLSDialer(addr)
+	if err != nil {
+		res.Reason = err.Error()
+		return res
+	}
+	conn = capture.Wrap(conn)
+	defer conn.Close()
+	p := newPDUConn(conn, nil)
+
+	if tc.binds() {
+		_ = conn.SetDeadline(time.Now().Add(timeout))
+		if err := p.writeFrame(rawBindFrame(cfg)); err != nil {
+			res.Reason = "bind: " + err.Error()
+			return res
+		}
+		_, h, err := readResponse(p)
+		switch {
+		case err != nil:
+			res.Reason = "bind: " + err.Error()
+			return res
+		case h.CommandID != cmdBindTransceiverResp || h.Status != ESME_ROK:
+			res.Reason = fmt.Sprintf("bind: %s %s", commandName(h.CommandID), h.Status)
+			return res
+		}
+	}
+
+	_ = conn.SetDeadline(time.Now().Add(timeout))
+	if err := p.writeFrame(frame); err != nil {
+		// A peer that resets while we are still writing has closed on us.
+		res.Outcome = "closed"
+		res.Reason = err.Error()
+	} else if _, h, err := readResponse(p); err == nil {
+		res.Outcome = "response"
+		res.Response = fmt.Sprintf("%s %s", commandName(h.CommandID), h.Status)
+		res.Passed, res.Reason = tc.Expect.matches(h)
+		if tc.binds() {
+			var unbind pduBuilder
+			_ = p.writeFrame(unbind.bytes(cmdUnbind, ESME_ROK, negativeSequence+1))
+		}
+		return res
+	} else if isTimeout(err) {
+		res.Outcome = "timeout"
+		res.Reason = fmt.Sprintf("no response within %s", timeout)
+		return res
+	} else {
+		res.Outcome = "closed"
+		res.Reason = err.Error()
+	}
+	res.Passed = tc.Expect.Close
+	if !res.Passed {
+		res.Reason = "connection closed instead of a response: " + res.Reason
+	}
+	return res
+}
+
+// matches checks a response header against e.
+func (e NegativeExpectation) matches(h pduHeader) (bool, string) {
+	name := commandName(h.CommandID)
+	known := false
+	for _, r := range e.Responses {
+		if strings.EqualFold(r, name) {
+			known = true
+		}
+	}
+	if !known {
+		return false, fmt.Sprintf("expected %s, got %s", strings.Join(e.Responses, " or "), name)
+	}
+	if len(e.Statuses) == 0 {
+		if h.Status == ESME_ROK {
+			return false, "expected an error status, got ESME_ROK"
+		}
+		return true, ""
+	}
+	for _, s := range e.Statuses {
+		if want, err := parseCommandStatus(s); err == nil && want == h.Status {
+			return true, ""
+		}
+	}
+	return false, fmt.Sprintf("expected status %s, got %s", strings.Join(e.Statuses, " or "), h.Status)
+}
+
+// runNegative implements the "negative" subcommand.
+func runNegative(args []string) int {
+	fs := flag.NewFlagSet("negative", flag.ExitOnError)
+	profile := fs.String("profile", "", "load .env.PROFILE before the environment")
+	file := fs.String("file", "", "negative test cases, JSON array or JSONL (default: built-in cases)")
+	timeout := fs.Duration("timeout", 5*time.Second, "how long to wait for each response")
+	list := fs.Bool("list", false, "print the test cases and their octets without connecting")
+	asJSON := fs.Bool("json", false, "print results as JSON")
+	packing := packingFlag(fs)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: negative [-profile NAME] [-file FILE] [-timeout D] [-list] [-json]")
+		fs.PrintDefaults()
+	}
+	_ = fs.Parse(args)
+	if fs.NArg() != 0 {
+		fs.Usage()
+		return 2
+	}
+	gsmPacking := *packing
+
+	cfg, err := LoadProfile(*profile)
+	if err != nil && !*list {
+		color.Red("%v", err)
+		return 1
+	}
+	tests := defaultNegativeTests(cfg)
+	if *file != "" {
+		if tests, err = loadNegativeTests(*file); err != nil {
+			color.Red("%v", err)
+			return 1
+		}
+	}
+
+	if *list {
+		for _, tc := range tests {
+			frame, err := tc.frame(gsmPacking)
+			if err != nil {
+				color.Red("#%d %s: %v", tc.TestCaseID, tc.Name, err)
+				continue
+			}
+			shown := frame
+			if len(shown) > 64 {
+				shown = shown[:64]
+			}
+			fmt.Printf("#%d %s (%d octets, bind=%t)\n  %X", tc.TestCaseID, tc.Name, len(frame), tc.binds(), shown)
+			if len(shown) < len(frame) {
+				fmt.Print("...")
+			}
+			fmt.Println()
+		}
+		return 0
+	}
+
+	endpoints := cfg.endpoints()
+	if len(endpoints) == 0 {
+		color.Red("no SMSC endpoint configured")
+		return 1
+	}
+	addr := endpoints[0].Addr()
+	capture, err := captureForConfig(cfg)
+	if err != nil {
+		color.Red("opening capture: %v", err)
+		return 1
+	}
+	defer capture.Close()
+
+	status := 0
+	results := make([]NegativeResult, 0, len(tests))
+	for _, tc := range tests {
+		res := runNegativeTest(cfg, addr, tc, *timeout, gsmPacking, capture)
+		results = append(results, res)
+		if !res.Passed {
+			status = 1
+		}
+		if *asJSON {
+			continue
+		}
+		line := fmt.Sprintf("#%d %s: %s", res.TestCaseID, res.Name, res.Outcome)
+		if res.Response != "" {
+			line += " (" + res.Response + ")"
+		}
+		if res.Reason != "" {
+			line += ": " + res.Reason
+		}
+		if res.Passed {
+			color.Green("PASS %s", line)
+		} else {
+			color.Red("FAIL %s", line)
+		}
+	}
+	if *asJSON {
+		b, _ := json.MarshalIndent(results, "", "  ")
+		fmt.Println(string(b))
+	}
+	return status
+}
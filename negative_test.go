@@ -0,0 +1,55 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLoadNegativeTests(t *testing.T) {
+	// The pdu of single-test-case.json carries informational header keys.
+	var doc struct {
+		Input struct {
+			PDU json.RawMessage `json:"pdu"`
+		} `json:"input"`
+	}
+	b, err := os.ReadFile("single-test-case.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(b, &doc); err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	write := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+
+	valid := write("valid.jsonl", `{"name":"described","pdu":`+string(doc.Input.PDU)+`,"expect":{"responses":["submit_sm_resp"]}}`+"\n")
+	tests, err := loadNegativeTests(valid)
+	if err != nil {
+		t.Fatalf("loading a PDU description with informational keys: %v", err)
+	}
+	if len(tests) != 1 || tests[0].PDU == nil || tests[0].PDU.Name != "submit_sm" {
+		t.Fatalf("loaded %+v", tests)
+	}
+	if _, err := tests[0].frame(GSMUnpacked); err != nil {
+		t.Errorf("encoding the loaded PDU: %v", err)
+	}
+
+	for name, content := range map[string]string{
+		"top-level": `{"name":"typo","raw_hex":"00","expct":{"close":true}}`,
+		"expect":    `{"name":"typo","raw_hex":"00","expect":{"close":true,"respones":["generic_nack"]}}`,
+	} {
+		_, err := loadNegativeTests(write(name+".jsonl", content+"\n"))
+		if err == nil || !strings.Contains(err.Error(), "unknown field") {
+			t.Errorf("%s: unknown key gave %v, want an unknown field error", name, err)
+		}
+	}
+}
@@ -0,0 +1,216 @@
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/linxGnu/gosmpp"
+	"github.com/linxGnu/gosmpp/pdu"
+)
+
+// errOutbindAuth is returned when an SMSC's outbind credentials do not match.
+var errOutbindAuth = errors.New("outbind authentication failed")
+
+// boundOutbind is a connection bound as receiver after an outbind.
+type boundOutbind struct {
+	conn    *gosmpp.Connection
+	version byte
+}
+
+// outbindConnector implements gosmpp.Connector for SMSC-initiated sessions.
+// Instead of dialing, Connect waits for the SMSC to connect to our listener
+// and send an outbind, then binds as a receiver on that same connection.
+type outbindConnector struct {
+	ln          net.Listener
+	auth        gosmpp.Auth
+	smscID      string
+	smscPass    string
+	readTimeout time.Duration
+	version     byte
+
+	// capture, if set, is applied to every accepted connection before the
+	// outbind is read, so rejected attempts are recorded too.
+	capture func(net.Conn) net.Conn
+	// wrap, if set, is applied to an authenticated connection before binding.
+	wrap func(net.Conn) net.Conn
+
+	ready chan boundOutbind
+	done  chan struct{}
+
+	// waiting is set while Connect waits for an outbind that no other
+	// connection has claimed yet.
+	mu      sync.Mutex
+	waiting bool
+
+	onConnecting func()
+	onBound      func(version byte)
+	onBindFailed func(error)
+}
+
+func newOutbindConnector(cfg Config, ln net.Listener) *outbindConnector {
+	return &outbindConnector{
+		ln: ln,
+		auth: gosmpp.Auth{
+			SystemID:   cfg.SystemID,
+			Password:   cfg.Password,
+			SystemType: cfg.SystemType,
+		},
+		smscID:      cfg.OutbindSystemID,
+		smscPass:    cfg.OutbindPassword,
+		readTimeout: cfg.ReadTimeout,
+		version:     cfg.InterfaceVersion,
+		ready:       make(chan boundOutbind),
+		done:        make(chan struct{}),
+	}
+}
+
+// GetBindType implements gosmpp.Connector.
+func (o *outbindConnector) GetBindType() pdu.BindingType {
+	return pdu.Receiver
+}
+
+// Connect implements gosmpp.Connector by waiting for the next authenticated outbind.
+func (o *outbindConnector) Connect() (*gosmpp.Connection, error) {
+	if o.onConnecting != nil {
+		o.onConnecting()
+	}
+	o.setWaiting(true)
+	defer o.setWaiting(false)
+	select {
+	case b := <-o.ready:
+		if o.onBound != nil {
+			o.onBound(b.version)
+		}
+		return b.conn, nil
+	case <-o.done:
+		return nil, ErrSessionClosed
+	}
+}
+
+// serve accepts SMSC connections until ctx is done or the listener is closed.
+func (o *outbindConnector) serve(ctx context.Context) {
+	go func() {
+		<-ctx.Done()
+		_ = o.ln.Close()
+	}()
+	defer close(o.done)
+
+	for {
+		conn, err := o.ln.Accept()
+		if err != nil {
+			if !errors.Is(err, net.ErrClosed) {
+				log.Printf("Outbind listener error: %v", err)
+			}
+			return
+		}
+		go o.handle(ctx, conn)
+	}
+}
+
+// handle reads the outbind from conn, checks the SMSC credentials and binds as receiver.
+func (o *outbindConnector) handle(ctx context.Context, conn net.Conn) {
+	remote := conn.RemoteAddr()
+	if o.capture != nil {
+		conn = o.capture(conn)
+	}
+	if o.readTimeout > 0 {
+		_ = conn.SetReadDeadline(time.Now().Add(o.readTimeout))
+	}
+
+	p, err := pdu.Parse(conn)
+	if err != nil {
+		log.Printf("Outbind from %v: failed to read PDU: %v", remote, err)
+		_ = conn.Close()
+		return
+	}
+	outbind, ok := p.(*pdu.Outbind)
+	if !ok {
+		log.Printf("Outbind from %v: expected outbind, got %T", remote, p)
+		_ = conn.Close()
+		return
+	}
+	if !o.authenticate(outbind) {
+		log.Printf("Outbind from %v: %v (system_id %q)", remote, errOutbindAuth, outbind.SystemID)
+		_ = conn.Close()
+		if o.onBindFailed != nil {
+			o.onBindFailed(errOutbindAuth)
+		}
+		return
+	}
+
+	// Bind only for a waiting session: a receiver bound for nobody would
+	// leave the SMSC delivering to a connection no one reads.
+	if !o.claim() {
+		log.Printf("Outbind from %v: a session is already bound or binding, closing", remote)
+		_ = conn.Close()
+		return
+	}
+	if o.wrap != nil {
+		conn = o.wrap(conn)
+	}
+	c, version, err := bindConnection(conn, pdu.Receiver, o.auth, o.version)
+	if err != nil {
+		log.Printf("Outbind from %v: bind_receiver failed: %v", remote, err)
+		o.setWaiting(true)
+		if o.onBindFailed != nil {
+			o.onBindFailed(err)
+		}
+		return
+	}
+	_ = conn.SetReadDeadline(time.Time{})
+	log.Printf("Outbind from %v (system_id %q): bound as receiver", remote, outbind.SystemID)
+
+	select {
+	case o.ready <- boundOutbind{conn: c, version: version}:
+	case <-ctx.Done():
+		_ = c.Close()
+	case <-o.done:
+		_ = c.Close()
+	}
+}
+
+// claim reserves the waiting Connect for one outbind. It reports false when
+// no Connect is waiting or another outbind has claimed it.
+func (o *outbindConnector) claim() bool {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if !o.waiting {
+		return false
+	}
+	o.waiting = false
+	return true
+}
+
+func (o *outbindConnector) setWaiting(waiting bool) {
+	o.mu.Lock()
+	o.waiting = waiting
+	o.mu.Unlock()
+}
+
+func (o *outbindConnector) authenticate(p *pdu.Outbind) bool {
+	idOK := subtle.ConstantTimeCompare([]byte(p.SystemID), []byte(o.smscID)) == 1
+	passOK := subtle.ConstantTimeCompare([]byte(p.Password), []byte(o.smscPass)) == 1
+	return idOK && passOK
+}
+
+// outbindListener opens the TCP or TLS listener configured for outbind mode.
+func outbindListener(cfg Config) (net.Listener, error) {
+	if cfg.OutbindAddr == "" {
+		return nil, fmt.Errorf("outbind listen address is not configured")
+	}
+	if cfg.OutbindCertFile == "" {
+		return net.Listen("tcp", cfg.OutbindAddr)
+	}
+	cert, err := tls.LoadX509KeyPair(cfg.OutbindCertFile, cfg.OutbindKeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("loading outbind certificate: %w", err)
+	}
+	return tls.Listen("tcp", cfg.OutbindAddr, &tls.Config{Certificates: []tls.Certificate{cert}})
+}
@@ -0,0 +1,183 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/linxGnu/gosmpp/pdu"
+)
+
+// startOutbind serves an outbind connector on a local listener.
+func startOutbind(t *testing.T) *outbindConnector {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	o := newOutbindConnector(Config{
+		SystemID:         "esme",
+		Password:         "esmepass",
+		OutbindSystemID:  "smsc",
+		OutbindPassword:  "secret",
+		ReadTimeout:      5 * time.Second,
+		InterfaceVersion: SMPPVersion34,
+	}, ln)
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	go o.serve(ctx)
+	return o
+}
+
+// sendOutbind connects to o as an SMSC and sends an outbind.
+func sendOutbind(t *testing.T, o *outbindConnector, password string) net.Conn {
+	t.Helper()
+	conn, err := net.Dial("tcp", o.ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = conn.Close() })
+	outbind := pdu.NewOutbind().(*pdu.Outbind)
+	outbind.SystemID = "smsc"
+	outbind.Password = password
+	buf := pdu.NewBuffer(nil)
+	outbind.Marshal(buf)
+	if _, err := conn.Write(buf.Bytes()); err != nil {
+		t.Fatal(err)
+	}
+	_ = conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	return conn
+}
+
+// answerBind reads the ESME's next PDU from conn and, if it is a bind,
+// accepts it. It returns the PDU read, or nil when conn was closed.
+func answerBind(t *testing.T, conn net.Conn) pdu.PDU {
+	t.Helper()
+	p, err := pdu.Parse(conn)
+	if err != nil {
+		return nil
+	}
+	if bind, ok := p.(*pdu.BindRequest); ok {
+		resp := pdu.NewBindResp(*bind)
+		resp.SystemID = "smsc"
+		buf := pdu.NewBuffer(nil)
+		resp.Marshal(buf)
+		if _, err := conn.Write(buf.Bytes()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return p
+}
+
+// connectOutbind runs o.Connect in the background.
+func connectOutbind(o *outbindConnector) <-chan error {
+	done := make(chan error, 1)
+	go func() {
+		conn, err := o.Connect()
+		if err == nil {
+			_ = conn.Close()
+		}
+		done <- err
+	}()
+	return done
+}
+
+func TestOutbindAuthFailure(t *testing.T) {
+	o := startOutbind(t)
+	failed := make(chan error, 1)
+	o.onBindFailed = func(err error) { failed <- err }
+	connected := connectOutbind(o)
+
+	conn := sendOutbind(t, o, "wrong")
+	select {
+	case err := <-failed:
+		if !errors.Is(err, errOutbindAuth) {
+			t.Errorf("onBindFailed(%v), want errOutbindAuth", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("an outbind with a wrong password was not rejected")
+	}
+	if p := answerBind(t, conn); p != nil {
+		t.Errorf("rejected outbind was answered with %T", p)
+	}
+	select {
+	case err := <-connected:
+		t.Fatalf("Connect returned %v after a rejected outbind", err)
+	default:
+	}
+}
+
+func TestOutbindBindsReceiver(t *testing.T) {
+	o := startOutbind(t)
+	bound := make(chan byte, 1)
+	o.onBound = func(version byte) { bound <- version }
+	connected := connectOutbind(o)
+	waitFor(t, "Connect to wait", func() bool {
+		o.mu.Lock()
+		defer o.mu.Unlock()
+		return o.waiting
+	})
+
+	conn := sendOutbind(t, o, "secret")
+	p := answerBind(t, conn)
+	bind, ok := p.(*pdu.BindRequest)
+	if !ok || bind.BindingType != pdu.Receiver || bind.SystemID != "esme" || bind.Password != "esmepass" {
+		t.Fatalf("outbind answered with %#v, want bind_receiver as esme", p)
+	}
+	if err := <-connected; err != nil {
+		t.Fatalf("Connect: %v", err)
+	}
+	if v := <-bound; v != SMPPVersion33 {
+		t.Errorf("negotiated version 0x%02X, want 0x33 when the SMSC omits sc_interface_version", v)
+	}
+}
+
+func TestOutbindRefusesDuplicates(t *testing.T) {
+	o := startOutbind(t)
+	connected := connectOutbind(o)
+	waitFor(t, "Connect to wait", func() bool {
+		o.mu.Lock()
+		defer o.mu.Unlock()
+		return o.waiting
+	})
+
+	// Two SMSC connections race for the one waiting session: only one binds.
+	first, second := sendOutbind(t, o, "secret"), sendOutbind(t, o, "secret")
+	results := make(chan pdu.PDU, 2)
+	for _, conn := range []net.Conn{first, second} {
+		go func(conn net.Conn) { results <- answerBind(t, conn) }(conn)
+	}
+	binds := 0
+	for i := 0; i < 2; i++ {
+		if _, ok := (<-results).(*pdu.BindRequest); ok {
+			binds++
+		}
+	}
+	if binds != 1 {
+		t.Fatalf("%d concurrent outbinds were bound, want 1", binds)
+	}
+	if err := <-connected; err != nil {
+		t.Fatalf("Connect: %v", err)
+	}
+
+	// With the session bound, another outbind is closed without a bind.
+	if p := answerBind(t, sendOutbind(t, o, "secret")); p != nil {
+		t.Errorf("outbind to a bound session was answered with %T", p)
+	}
+
+	// Once the session wants a connection again, outbinds are bound.
+	connected = connectOutbind(o)
+	waitFor(t, "Connect to wait", func() bool {
+		o.mu.Lock()
+		defer o.mu.Unlock()
+		return o.waiting
+	})
+	if _, ok := answerBind(t, sendOutbind(t, o, "secret")).(*pdu.BindRequest); !ok {
+		t.Error("outbind after a reconnect was not bound")
+	}
+	if err := <-connected; err != nil {
+		t.Fatalf("Connect: %v", err)
+	}
+}
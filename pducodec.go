@@ -0,0 +1,847 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+
+	"github.com/fatih/color"
+	"github.com/linxGnu/gosmpp/pdu"
+)
+
+// pduLayout names a command and its mandatory body fields in wire order.
+type pduLayout struct {
+	Name   string
+	Fields []string
+}
+
+var (
+	bindFields = []string{"system_id", "password", "system_type", "interface_version", "addr_ton", "addr_npi", "address_range"}
+	smFields   = []string{"service_type", "source_addr_ton", "source_addr_npi", "source_addr",
+		"dest_addr_ton", "dest_addr_npi", "destination_addr", "esm_class", "protocol_id", "priority_flag",
+		"schedule_delivery_time", "validity_period", "registered_delivery", "replace_if_present_flag",
+		"data_coding", "sm_default_msg_id", "sm_length", "short_message"}
+	dataSMFields = []string{"service_type", "source_addr_ton", "source_addr_npi", "source_addr",
+		"dest_addr_ton", "dest_addr_npi", "destination_addr", "esm_class", "registered_delivery", "data_coding"}
+)
+
+// pduLayouts lists the PDUs the codec knows field by field. Other commands
+// are shown with an undecoded body.
+var pduLayouts = map[uint32]pduLayout{
+	0x00000001:             {"bind_receiver", bindFields},
+	0x80000001:             {"bind_receiver_resp", []string{"system_id"}},
+	0x00000002:             {"bind_transmitter", bindFields},
+	0x80000002:             {"bind_transmitter_resp", []string{"system_id"}},
+	0x00000003:             {"query_sm", []string{"message_id", "source_addr_ton", "source_addr_npi", "source_addr"}},
+	0x80000003:             {"query_sm_resp", []string{"message_id", "final_date", "message_state", "error_code"}},
+	cmdSubmitSM:            {"submit_sm", smFields},
+	cmdSubmitSMResp:        {"submit_sm_resp", []string{"message_id"}},
+	cmdDeliverSM:           {"deliver_sm", smFields},
+	cmdDeliverSMResp:       {"deliver_sm_resp", []string{"message_id"}},
+	cmdUnbind:              {"unbind", nil},
+	cmdUnbindResp:          {"unbind_resp", nil},
+	cmdBindTransceiver:     {"bind_transceiver", bindFields},
+	cmdBindTransceiverResp: {"bind_transceiver_resp", []string{"system_id"}},
+	0x0000000B:             {"outbind", []string{"system_id", "password"}},
+	cmdEnquireLink:         {"enquire_link", nil},
+	cmdEnquireLinkResp:     {"enquire_link_resp", nil},
+	cmdGenericNack:         {"generic_nack", nil},
+	cmdDataSM:              {"data_sm", dataSMFields},
+	cmdDataSMResp:          {"data_sm_resp", []string{"message_id"}},
+}
+
+// pduCStrings are the body fields sent as C-Octet strings. sm_length and
+// short_message are handled together; every other field is one octet.
+var pduCStrings = map[string]bool{
+	"service_type": true, "source_addr": true, "destination_addr": true,
+	"schedule_delivery_time": true, "validity_period": true, "message_id": true,
+	"system_id": true, "password": true, "system_type": true, "address_range": true,
+	"final_date": true,
+}
+
+// udhIENames names the information elements commonly found in a UDH.
+var udhIENames = map[byte]string{
+	0x00:                    "concatenation (8-bit reference)",
+	0x04:                    "application port (8-bit)",
+	0x05:                    "application port (16-bit)",
+	0x08:                    "concatenation (16-bit reference)",
+	ieiNationalSingleShift:  "national language single shift",
+	ieiNationalLockingShift: "national language locking shift",
+}
+
+// commandName returns the name of an SMPP command_id.
+func commandName(id uint32) string {
+	if l, ok := pduLayouts[id]; ok {
+		return l.Name
+	}
+	return fmt.Sprintf("0x%08X", id)
+}
+
+// commandIDByName looks up a command_id by its name.
+func commandIDByName(name string) (uint32, bool) {
+	for id, l := range pduLayouts {
+		if strings.EqualFold(l.Name, name) {
+			return id, true
+		}
+	}
+	return 0, false
+}
+
+// PDUField is one decoded field: where it starts in the PDU, its octets and
+// a readable value.
+type PDUField struct {
+	Offset int    `json:"offset"`
+	Name   string `json:"name"`
+	Hex    string `json:"hex"`
+	Value  string `json:"value"`
+}
+
+// DecodedPDU is an annotated field listing of a raw PDU.
+type DecodedPDU struct {
+	Name   string     `json:"name"`
+	Fields []PDUField `json:"fields"`
+	// Error describes why decoding stopped early; the fields before it are
+	// still listed.
+	Error string `json:"error,omitempty"`
+}
+
+// DecodePDU decodes frame into an annotated field listing: the header, the
+// mandatory fields of known commands, the UDH and text of short_message,
+// and the TLVs. GSM 7-bit text is read in the given packing. The listing
+// is returned with the error for malformed PDUs.
+func DecodePDU(frame []byte, packing GSMPacking) (d DecodedPDU, err error) {
+	add := func(offset int, name string, raw []byte, value string) {
+		d.Fields = append(d.Fields, PDUField{Offset: offset, Name: name, Hex: strings.ToUpper(hex.EncodeToString(raw)), Value: value})
+	}
+	fail := func(err error) (DecodedPDU, error) {
+		d.Error = err.Error()
+		return d, err
+	}
+
+	hdr, err := parsePDUHeader(frame)
+	if err != nil {
+		return fail(fmt.Errorf("header: %w", err))
+	}
+	d.Name = commandName(hdr.CommandID)
+	add(0, "command_length", frame[0:4], strconv.FormatUint(uint64(hdr.Length), 10))
+	add(4, "command_id", frame[4:8], fmt.Sprintf("%s (0x%08X)", d.Name, hdr.CommandID))
+	add(8, "command_status", frame[8:12], hdr.Status.String())
+	add(12, "sequence_number", frame[12:16], strconv.FormatUint(uint64(hdr.Sequence), 10))
+	switch {
+	case int(hdr.Length) < pduHeaderLen:
+		return fail(fmt.Errorf("command_length %d is shorter than the header", hdr.Length))
+	case int(hdr.Length) > len(frame):
+		return fail(fmt.Errorf("command_length %d but only %d octets: %w", hdr.Length, len(frame), errShortPDU))
+	case int(hdr.Length) < len(frame):
+		defer func() {
+			if err == nil {
+				err = fmt.Errorf("%d octets after command_length", len(frame)-int(hdr.Length))
+				d.Error = err.Error()
+			}
+		}()
+	}
+
+	body := frame[pduHeaderLen:hdr.Length]
+	layout, known := pduLayouts[hdr.CommandID]
+	if !known {
+		if len(body) > 0 {
+			add(pduHeaderLen, "body", body, fmt.Sprintf("%d octets, command not decoded", len(body)))
+		}
+		return d, nil
+	}
+	if hdr.CommandID&0x80000000 != 0 && hdr.Status != ESME_ROK && len(body) == 0 {
+		// Error responses may omit the body.
+		return d, nil
+	}
+
+	r := newPDUReader(body)
+	var esmClass, dataCoding byte
+	for _, name := range layout.Fields {
+		start := r.off
+		switch {
+		case pduCStrings[name]:
+			s := r.cstring(name)
+			if r.err == nil {
+				add(pduHeaderLen+start, name, body[start:r.off], strconv.Quote(s))
+			}
+		case name == "sm_length":
+			n := r.octet(name)
+			if r.err != nil {
+				break
+			}
+			add(pduHeaderLen+start, name, body[start:r.off], strconv.Itoa(int(n)))
+			smStart := r.off
+			sm := r.octets("short_message", int(n))
+			if r.err == nil {
+				d.Fields = append(d.Fields, shortMessageFields(pduHeaderLen+smStart, sm, esmClass, dataCoding, packing)...)
+			}
+		case name == "short_message":
+			// Read with sm_length.
+		default:
+			v := r.octet(name)
+			if r.err != nil {
+				break
+			}
+			switch name {
+			case "esm_class":
+				esmClass = v
+			case "data_coding":
+				dataCoding = v
+			}
+			add(pduHeaderLen+start, name, body[start:r.off], octetValue(name, v))
+		}
+		if r.err != nil {
+			return fail(r.err)
+		}
+	}
+	for r.err == nil && r.remaining() > 0 {
+		start := r.off
+		th := r.octets("tlv header", 4)
+		if r.err != nil {
+			break
+		}
+		tag := binary.BigEndian.Uint16(th[0:2])
+		value := r.octets(fmt.Sprintf("tlv 0x%04X", tag), int(binary.BigEndian.Uint16(th[2:4])))
+		if r.err == nil {
+			add(pduHeaderLen+start, tlvName(tag), body[start:r.off], tlvValue(rawTLV{Tag: tag, Value: value}))
+		}
+	}
+	if r.err != nil {
+		return fail(r.err)
+	}
+	return d, nil
+}
+
+// shortMessageFields annotates a short_message: its UDH elements when UDHI
+// is set, then the user data decoded by data_coding.
+func shortMessageFields(offset int, sm []byte, esmClass, dataCoding byte, packing GSMPacking) []PDUField {
+	var fields []PDUField
+	add := func(off int, name string, raw []byte, value string) {
+		fields = append(fields, PDUField{Offset: off, Name: name, Hex: strings.ToUpper(hex.EncodeToString(raw)), Value: value})
+	}
+	header := 0
+	if esmClass&esmUDHI != 0 && len(sm) > 0 && int(sm[0]) < len(sm) {
+		header = 1 + int(sm[0])
+		udh, err := parseUDH(sm[:header])
+		if err != nil {
+			add(offset, "short_message.udh", sm[:header], err.Error())
+		} else {
+			add(offset, "short_message.udhl", sm[:1], strconv.Itoa(int(sm[0])))
+			pos := 1
+			for _, ie := range udh {
+				name := udhIENames[ie.ID]
+				if name == "" {
+					name = "unknown"
+				}
+				add(offset+pos, fmt.Sprintf("short_message.udh.ie 0x%02X", ie.ID), sm[pos:pos+2+len(ie.Data)],
+					fmt.Sprintf("%s: %X", name, ie.Data))
+				pos += 2 + len(ie.Data)
+			}
+		}
+	}
+	user := sm[header:]
+	udh, _ := parseUDH(sm[:header])
+	add(offset+header, "short_message", user, userDataText(user, udh, header, dataCoding, packing))
+	return fields
+}
+
+// userDataText renders user data following a UDH of header octets. GSM
+// 7-bit text is decoded with the shift tables the UDH selects.
+func userDataText(user []byte, udh pdu.UDH, header int, dataCoding byte, packing GSMPacking) string {
+	if len(user) == 0 {
+		return `""`
+	}
+	switch DecodeDCS(dataCoding).Alphabet {
+	case AlphabetGSM7:
+		septets := user
+		if packing == GSMPacked {
+			fill := (7 - header*8%7) % 7
+			septets = unpackSeptets(user, fill, (len(user)*8-fill)/7)
+		}
+		if text, err := nationalTables(udh).decode(septets); err == nil {
+			return strconv.Quote(text)
+		}
+	case AlphabetUCS2:
+		if len(user)%2 == 0 {
+			units := make([]uint16, len(user)/2)
+			for i := range units {
+				units[i] = binary.BigEndian.Uint16(user[2*i:])
+			}
+			return strconv.Quote(string(utf16.Decode(units)))
+		}
+	case AlphabetLatin1:
+		rs := make([]rune, len(user))
+		for i, b := range user {
+			rs[i] = rune(b)
+		}
+		return strconv.Quote(string(rs))
+	case AlphabetASCII:
+		return strconv.Quote(string(user))
+	}
+	if utf8.Valid(user) && !bytes.ContainsAny(user, "\x00") {
+		return strconv.Quote(string(user))
+	}
+	return fmt.Sprintf("%d octets", len(user))
+}
+
+// octetValue renders a one-octet field.
+func octetValue(name string, v byte) string {
+	switch name {
+	case "esm_class", "registered_delivery", "interface_version":
+		return fmt.Sprintf("0x%02X", v)
+	case "data_coding":
+		return DecodeDCS(v).String()
+	}
+	return strconv.Itoa(int(v))
+}
+
+func tlvName(tag uint16) string {
+	if def, ok := lookupTLVTag(tag); ok {
+		return fmt.Sprintf("tlv %s (0x%04X)", def.Name, tag)
+	}
+	return fmt.Sprintf("tlv 0x%04X", tag)
+}
+
+// tlvValue renders a TLV value by its known type.
+func tlvValue(t rawTLV) string {
+	def, _ := lookupTLVTag(t.Tag)
+	switch {
+	case def.Type == tlvInt8 && len(t.Value) == 1,
+		def.Type == tlvInt16 && len(t.Value) == 2,
+		def.Type == tlvInt32 && len(t.Value) == 4:
+		var n uint64
+		for _, b := range t.Value {
+			n = n<<8 | uint64(b)
+		}
+		return strconv.FormatUint(n, 10)
+	case def.Type == tlvCString && len(t.Value) > 0 && t.Value[len(t.Value)-1] == 0:
+		return strconv.Quote(string(t.Value[:len(t.Value)-1]))
+	case len(t.Value) == 0:
+		return "(empty)"
+	}
+	return fmt.Sprintf("%d octets", len(t.Value))
+}
+
+// PDUDescription is a PDU written as JSON, in the shape of the pdu objects
+// of single-test-case.json. Body fields omitted are sent as zero or empty;
+// command_length and sm_length are computed unless given, so that
+// malformed PDUs can be described too.
+type PDUDescription struct {
+	Name   string                     `json:"name"`
+	Header PDUHeaderDescription       `json:"header"`
+	Body   map[string]json.RawMessage `json:"body"`
+	TLVs   []TLVSpec                  `json:"tlvs,omitempty"`
+	RawHex string                     `json:"raw_hex,omitempty"`
+}
+
+// PDUHeaderDescription is the header of a PDUDescription.
+type PDUHeaderDescription struct {
+	CommandLength  *uint32 `json:"command_length,omitempty"`
+	CommandID      *uint32 `json:"command_id,omitempty"`
+	CommandStatus  uint32  `json:"command_status"`
+	SequenceNumber uint32  `json:"sequence_number"`
+}
+
+// EncodePDU encodes a PDU description. short_message text is encoded with
+// data_coding (GSM 7-bit in the given packing, with the shift tables of
+// the UDH given as udh_hex); short_message_hex gives the octets, UDH
+// included, instead. esm_class is sent as given.
+func EncodePDU(desc PDUDescription, packing GSMPacking) ([]byte, error) {
+	id, ok := commandIDByName(desc.Name)
+	if desc.Header.CommandID != nil {
+		id, ok = *desc.Header.CommandID, true
+	}
+	if !ok {
+		return nil, fmt.Errorf("unknown command %q (give header.command_id)", desc.Name)
+	}
+	layout := pduLayouts[id]
+
+	known := map[string]bool{"short_message_hex": true, "udh_hex": true}
+	for _, name := range layout.Fields {
+		known[name] = true
+	}
+	for name := range desc.Body {
+		if !known[name] {
+			return nil, fmt.Errorf("%s has no body field %q", commandName(id), name)
+		}
+	}
+	str := func(name string) (string, error) {
+		var s string
+		if raw, ok := desc.Body[name]; ok {
+			if err := json.Unmarshal(raw, &s); err != nil {
+				return "", fmt.Errorf("%s: want a string: %w", name, err)
+			}
+		}
+		return s, nil
+	}
+	octet := func(name string) (byte, error) {
+		var n int
+		if raw, ok := desc.Body[name]; ok {
+			if err := json.Unmarshal(raw, &n); err != nil {
+				return 0, fmt.Errorf("%s: want a number: %w", name, err)
+			}
+			if n < 0 || n > 0xFF {
+				return 0, fmt.Errorf("%s: %d does not fit in one octet", name, n)
+			}
+		}
+		return byte(n), nil
+	}
+
+	var b pduBuilder
+	dataCoding := byte(0)
+	for _, name := range layout.Fields {
+		switch {
+		case pduCStrings[name]:
+			s, err := str(name)
+			if err != nil {
+				return nil, err
+			}
+			b.cstring(s)
+		case name == "sm_length":
+			sm, err := descShortMessage(desc, dataCoding, packing)
+			if err != nil {
+				return nil, err
+			}
+			n := len(sm)
+			if _, ok := desc.Body["sm_length"]; ok {
+				v, err := octet("sm_length")
+				if err != nil {
+					return nil, err
+				}
+				n = int(v)
+			} else if n > 0xFF {
+				return nil, fmt.Errorf("short_message: %d octets do not fit sm_length", n)
+			}
+			b.octet(byte(n))
+			b.octets(sm)
+		case name == "short_message":
+		default:
+			v, err := octet(name)
+			if err != nil {
+				return nil, err
+			}
+			if name == "data_coding" {
+				dataCoding = v
+			}
+			b.octet(v)
+		}
+	}
+	enc, _ := byteToDataCoding(dataCoding)
+	for _, t := range desc.TLVs {
+		tag, value, err := t.encode(enc)
+		if err != nil {
+			return nil, err
+		}
+		b.tlv(tag, value)
+	}
+
+	frame := b.bytes(id, CommandStatus(desc.Header.CommandStatus), desc.Header.SequenceNumber)
+	if desc.Header.CommandLength != nil {
+		binary.BigEndian.PutUint32(frame[0:4], *desc.Header.CommandLength)
+	}
+	return frame, nil
+}
+
+// descShortMessage returns the short_message octets of desc: short_message
+// text, after the UDH given as udh_hex if any, or short_message_hex.
+func descShortMessage(desc PDUDescription, dataCoding byte, packing GSMPacking) ([]byte, error) {
+	if raw, ok := desc.Body["short_message_hex"]; ok {
+		var s string
+		if err := json.Unmarshal(raw, &s); err != nil {
+			return nil, fmt.Errorf("short_message_hex: want a string: %w", err)
+		}
+		b, err := decodePayload(s, false)
+		if err != nil {
+			return nil, fmt.Errorf("short_message_hex: %w", err)
+		}
+		return b, nil
+	}
+	var udh pdu.UDH
+	if raw, ok := desc.Body["udh_hex"]; ok {
+		var s string
+		if err := json.Unmarshal(raw, &s); err != nil {
+			return nil, fmt.Errorf("udh_hex: want a string: %w", err)
+		}
+		b, err := decodePayload(s, false)
+		if err == nil {
+			udh, err = parseUDH(b)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("udh_hex: %w", err)
+		}
+	}
+	var text string
+	if raw, ok := desc.Body["short_message"]; ok {
+		if err := json.Unmarshal(raw, &text); err != nil {
+			return nil, fmt.Errorf("short_message: want a string: %w", err)
+		}
+	}
+	out := udhBytes(udh)
+	switch DecodeDCS(dataCoding).Alphabet {
+	case AlphabetGSM7:
+		// National language IEs in the UDH select the shift tables.
+		septets, err := nationalTables(udh).encode(text)
+		if err != nil {
+			return nil, fmt.Errorf("short_message: %w", err)
+		}
+		return gsmUserData(septets, udh, packing), nil
+	case AlphabetUCS2:
+		for _, u := range utf16.Encode([]rune(text)) {
+			out = append(out, byte(u>>8), byte(u))
+		}
+		return out, nil
+	case AlphabetLatin1:
+		if !isLatin1(text) {
+			return nil, fmt.Errorf("short_message: %q is not Latin-1", text)
+		}
+		for _, r := range text {
+			out = append(out, byte(r))
+		}
+		return out, nil
+	}
+	return append(out, text...), nil
+}
+
+// PDUFieldDiff is a field whose octets differ between two PDUs. A or B is
+// nil when the field is missing from that PDU.
+type PDUFieldDiff struct {
+	Name string    `json:"name"`
+	A    *PDUField `json:"a,omitempty"`
+	B    *PDUField `json:"b,omitempty"`
+}
+
+// DiffPDUs compares two decoded PDUs field by field. Repeated fields, such
+// as TLVs with the same tag, are matched in order.
+func DiffPDUs(a, b DecodedPDU) []PDUFieldDiff {
+	key := func(fields []PDUField) ([]string, map[string]*PDUField) {
+		seen := map[string]int{}
+		var order []string
+		byKey := map[string]*PDUField{}
+		for i := range fields {
+			k := fields[i].Name
+			if n := seen[k]; n > 0 {
+				k = fmt.Sprintf("%s #%d", k, n+1)
+			}
+			seen[fields[i].Name]++
+			order = append(order, k)
+			byKey[k] = &fields[i]
+		}
+		return order, byKey
+	}
+	orderA, fa := key(a.Fields)
+	orderB, fb := key(b.Fields)
+	var diffs []PDUFieldDiff
+	for _, k := range orderA {
+		if fb[k] == nil || fb[k].Hex != fa[k].Hex {
+			diffs = append(diffs, PDUFieldDiff{Name: k, A: fa[k], B: fb[k]})
+		}
+	}
+	for _, k := range orderB {
+		if fa[k] == nil {
+			diffs = append(diffs, PDUFieldDiff{Name: k, B: fb[k]})
+		}
+	}
+	return diffs
+}
+
+// readPDUArg reads a PDU given on the command line: a hex string, "-" for
+// standard input, or a file holding hex, binary octets or a JSON PDU
+// description. JSON test files are searched for input.pdu, or
+// expected_output.pdu when expected is set. Hex arguments are never looked
+// up as files: a long PDU would exceed the maximum file name length.
+func readPDUArg(arg string, expected bool, packing GSMPacking) ([]byte, error) {
+	var content []byte
+	switch {
+	case arg == "-":
+		b, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			return nil, err
+		}
+		content = b
+	case isHexPDU([]byte(arg)):
+		content = []byte(arg)
+	default:
+		b, err := os.ReadFile(arg)
+		if err != nil {
+			return nil, err
+		}
+		content = b
+	}
+
+	trimmed := bytes.TrimSpace(content)
+	switch {
+	case bytes.HasPrefix(trimmed, []byte{'{'}):
+		desc, err := findPDUDescription(trimmed, expected)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", arg, err)
+		}
+		if len(desc.Body) == 0 && desc.RawHex != "" {
+			return decodePayload(desc.RawHex, false)
+		}
+		return EncodePDU(desc, packing)
+	case isHexPDU(trimmed):
+		s := strings.TrimPrefix(strings.TrimPrefix(string(trimmed), "0x"), "0X")
+		return decodePayload(s, false)
+	}
+	return content, nil
+}
+
+// isHexPDU reports whether b is hex digits and white space.
+func isHexPDU(b []byte) bool {
+	b = bytes.TrimPrefix(bytes.TrimPrefix(b, []byte("0x")), []byte("0X"))
+	if len(bytes.TrimSpace(b)) == 0 {
+		return false
+	}
+	for _, c := range b {
+		if !strings.ContainsRune("0123456789abcdefABCDEF \t\r\n", rune(c)) {
+			return false
+		}
+	}
+	return true
+}
+
+// findPDUDescription locates the PDU description in a JSON document: the
+// document itself, its "pdu" object, or that of input (or expected_output).
+// A pdu decode -json listing is read as the octets of its fields.
+func findPDUDescription(doc []byte, expected bool) (PDUDescription, error) {
+	var obj map[string]json.RawMessage
+	if err := json.Unmarshal(doc, &obj); err != nil {
+		return PDUDescription{}, err
+	}
+	section := "input"
+	if expected {
+		section = "expected_output"
+	}
+	if raw, ok := obj[section]; ok {
+		return findPDUDescription(raw, expected)
+	}
+	if raw, ok := obj["pdu"]; ok {
+		return findPDUDescription(raw, expected)
+	}
+	if _, ok := obj["fields"]; ok {
+		return listingDescription(doc)
+	}
+	if _, ok := obj["name"]; !ok {
+		return PDUDescription{}, fmt.Errorf("no PDU description found (want name/header/body, pdu or %s.pdu)", section)
+	}
+	return parsePDUDescription(doc)
+}
+
+// listingDescription reads a pdu decode -json listing back as the raw_hex
+// of its fields, so that an edited listing can be encoded again.
+func listingDescription(doc []byte) (PDUDescription, error) {
+	var d DecodedPDU
+	dec := json.NewDecoder(bytes.NewReader(doc))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&d); err != nil {
+		return PDUDescription{}, fmt.Errorf("PDU listing: %w", err)
+	}
+	if d.Error != "" {
+		return PDUDescription{}, fmt.Errorf("PDU listing of a malformed PDU: %s", d.Error)
+	}
+	var raw strings.Builder
+	next := 0
+	for _, f := range d.Fields {
+		if f.Offset != next {
+			return PDUDescription{}, fmt.Errorf("PDU listing: %s starts at offset %d, want %d", f.Name, f.Offset, next)
+		}
+		raw.WriteString(f.Hex)
+		next += len(f.Hex) / 2
+	}
+	return PDUDescription{Name: d.Name, RawHex: raw.String()}, nil
+}
+
+// pduDescriptionKeys are the top-level keys of a PDUDescription.
+var pduDescriptionKeys = map[string]bool{"name": true, "header": true, "body": true, "tlvs": true, "raw_hex": true}
+
+// parsePDUDescription parses a PDU description. Unknown top-level keys are
+// rejected, so that other JSON, such as a pdu decode -json listing, is not
+// encoded as an empty PDU; the header and body may carry informational
+// keys such as command_length_hex.
+func parsePDUDescription(doc []byte) (PDUDescription, error) {
+	var obj map[string]json.RawMessage
+	if err := json.Unmarshal(doc, &obj); err != nil {
+		return PDUDescription{}, err
+	}
+	keys := make([]string, 0, len(obj))
+	for key := range obj {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		if !pduDescriptionKeys[key] {
+			return PDUDescription{}, fmt.Errorf("PDU description has unknown key %q (want name, header, body, tlvs or raw_hex)", key)
+		}
+	}
+	var desc PDUDescription
+	err := json.Unmarshal(doc, &desc)
+	return desc, err
+}
+
+// regroup inserts a space every n characters of s.
+func regroup(s string, n int) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i += n {
+		end := i + n
+		if end > len(s) {
+			end = len(s)
+		}
+		b.WriteString(s[i:end])
+		b.WriteByte(' ')
+	}
+	return b.String()
+}
+
+// printDecodedPDU prints an annotated listing: offset, octets (the first
+// 16 of long fields), field name and value.
+func printDecodedPDU(d DecodedPDU) {
+	color.Green("%s", d.Name)
+	for _, f := range d.Fields {
+		octets := regroup(f.Hex, 2)
+		if len(octets) > 48 {
+			octets = octets[:45] + "..."
+		}
+		fmt.Printf("  %04X  %-48s  %-28s %s\n", f.Offset, strings.TrimSpace(octets), f.Name, f.Value)
+	}
+	if d.Error != "" {
+		color.Red("  malformed: %s", d.Error)
+	}
+}
+
+// runPDU implements the "pdu" subcommand: decode, encode and diff.
+func runPDU(args []string) int {
+	usage := func() {
+		fmt.Fprintln(os.Stderr, "Usage: pdu decode [-packing MODE] [-json] [-expected] PDU...")
+		fmt.Fprintln(os.Stderr, "       pdu encode [-packing MODE] [-expected] FILE")
+		fmt.Fprintln(os.Stderr, "       pdu diff [-packing MODE] [-expected] PDU PDU")
+		fmt.Fprintln(os.Stderr, "PDU is a hex string, a file of hex, binary or JSON (a PDU description or a decode -json listing), or - for standard input.")
+	}
+	if len(args) == 0 {
+		usage()
+		return 2
+	}
+	fs := flag.NewFlagSet("pdu "+args[0], flag.ExitOnError)
+	packing := packingFlag(fs)
+	expected := fs.Bool("expected", false, "use expected_output.pdu of JSON test files instead of input.pdu")
+	asJSON := fs.Bool("json", false, "print the decoded fields as JSON")
+	fs.Usage = func() {
+		usage()
+		fs.PrintDefaults()
+	}
+	_ = fs.Parse(args[1:])
+	p := *packing
+	read := func(arg string) ([]byte, bool) {
+		b, err := readPDUArg(arg, *expected, p)
+		if err != nil {
+			color.Red("%v", err)
+			return nil, false
+		}
+		return b, true
+	}
+
+	switch args[0] {
+	case "decode":
+		if fs.NArg() == 0 {
+			fs.Usage()
+			return 2
+		}
+		status := 0
+		for _, arg := range fs.Args() {
+			frame, ok := read(arg)
+			if !ok {
+				status = 1
+				continue
+			}
+			d, err := DecodePDU(frame, p)
+			if err != nil || d.Error != "" {
+				status = 1
+			}
+			if *asJSON {
+				b, _ := json.Marshal(d)
+				fmt.Println(string(b))
+				continue
+			}
+			printDecodedPDU(d)
+		}
+		return status
+	case "encode":
+		if fs.NArg() != 1 {
+			fs.Usage()
+			return 2
+		}
+		frame, ok := read(fs.Arg(0))
+		if !ok {
+			return 1
+		}
+		fmt.Println(strings.ToLower(hex.EncodeToString(frame)))
+		// Check a raw_hex written next to the description.
+		if b, err := os.ReadFile(fs.Arg(0)); err == nil {
+			if desc, err := findPDUDescription(b, *expected); err == nil && desc.RawHex != "" && len(desc.Body) > 0 {
+				if want, err := decodePayload(desc.RawHex, false); err == nil && !bytes.Equal(want, frame) {
+					color.Yellow("raw_hex in %s differs from the encoded PDU", fs.Arg(0))
+					return printPDUDiff(want, frame, p)
+				}
+			}
+		}
+		return 0
+	case "diff":
+		if fs.NArg() != 2 {
+			fs.Usage()
+			return 2
+		}
+		a, okA := read(fs.Arg(0))
+		b, okB := read(fs.Arg(1))
+		if !okA || !okB {
+			return 1
+		}
+		return printPDUDiff(a, b, p)
+	}
+	usage()
+	return 2
+}
+
+// printPDUDiff prints the fields that differ between two PDUs and returns
+// the exit status: 0 when they are identical.
+func printPDUDiff(a, b []byte, packing GSMPacking) int {
+	da, _ := DecodePDU(a, packing)
+	db, _ := DecodePDU(b, packing)
+	diffs := DiffPDUs(da, db)
+	if len(diffs) == 0 && bytes.Equal(a, b) {
+		color.Green("PDUs are identical (%d octets)", len(a))
+		return 0
+	}
+	show := func(f *PDUField) string {
+		if f == nil {
+			return "(absent)"
+		}
+		return fmt.Sprintf("%s [%s]", f.Value, f.Hex)
+	}
+	sort.SliceStable(diffs, func(i, j int) bool { return fieldOffset(diffs[i]) < fieldOffset(diffs[j]) })
+	for _, d := range diffs {
+		color.Red("%s", d.Name)
+		fmt.Printf("  - %s\n  + %s\n", show(d.A), show(d.B))
+	}
+	for _, x := range []DecodedPDU{da, db} {
+		if x.Error != "" {
+			color.Yellow("malformed %s: %s", x.Name, x.Error)
+		}
+	}
+	return 1
+}
+
+func fieldOffset(d PDUFieldDiff) int {
+	if d.A != nil {
+		return d.A.Offset
+	}
+	return d.B.Offset
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestReadPDUArg(t *testing.T) {
+	text := strings.Repeat("a", 200)
+	frame, err := rawSubmitSM{
+		SourceAddr:      "ACME",
+		DestinationAddr: "447700900001",
+		ShortMessage:    []byte(text),
+	}.bytes(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Longer than any file name the filesystem accepts.
+	arg := hex.EncodeToString(frame)
+	if len(arg) <= 255 {
+		t.Fatalf("test PDU is only %d hex digits", len(arg))
+	}
+	for _, in := range []string{arg, "0x" + strings.ToUpper(arg)} {
+		got, err := readPDUArg(in, false, GSMUnpacked)
+		if err != nil {
+			t.Fatalf("readPDUArg(%.20s...): %v", in, err)
+		}
+		if !bytes.Equal(got, frame) {
+			t.Errorf("readPDUArg(%.20s...) = %X, want %X", in, got, frame)
+		}
+	}
+
+	path := filepath.Join(t.TempDir(), "submit.hex")
+	if err := os.WriteFile(path, []byte(arg+"\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := readPDUArg(path, false, GSMUnpacked); err != nil || !bytes.Equal(got, frame) {
+		t.Errorf("readPDUArg(%s) = %X, %v; want %X", path, got, err, frame)
+	}
+	if _, err := readPDUArg(filepath.Join(t.TempDir(), "missing"), false, GSMUnpacked); err == nil {
+		t.Error("readPDUArg of a missing file succeeded")
+	}
+}
+
+func TestDecodeEncodeRoundTrip(t *testing.T) {
+	turkish, err := EncodePDU(PDUDescription{
+		Name: "submit_sm",
+		Body: map[string]json.RawMessage{
+			"esm_class":     json.RawMessage(`64`),
+			"data_coding":   json.RawMessage(`0`),
+			"udh_hex":       json.RawMessage(`"03250101"`),
+			"short_message": json.RawMessage(`"şğı"`),
+		},
+	}, GSMPacked)
+	if err != nil {
+		t.Fatalf("encoding Turkish text after a locking shift UDH: %v", err)
+	}
+	sample, err := readPDUArg("single-test-case.json", false, GSMUnpacked)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	for name, frame := range map[string][]byte{"turkish": turkish, "sample": sample} {
+		for _, packing := range []GSMPacking{GSMUnpacked, GSMPacked} {
+			d, err := DecodePDU(frame, packing)
+			if err != nil {
+				t.Fatalf("%s: %v", name, err)
+			}
+			b, err := json.Marshal(d)
+			if err != nil {
+				t.Fatal(err)
+			}
+			path := filepath.Join(dir, name+".json")
+			if err := os.WriteFile(path, b, 0o644); err != nil {
+				t.Fatal(err)
+			}
+			got, err := readPDUArg(path, false, packing)
+			if err != nil {
+				t.Fatalf("%s: encoding the decode -json listing: %v", name, err)
+			}
+			if !bytes.Equal(got, frame) {
+				t.Errorf("%s: decode -json listing encodes to %X, want %X", name, got, frame)
+			}
+		}
+	}
+
+	d, _ := DecodePDU(turkish, GSMPacked)
+	if text := d.Fields[len(d.Fields)-1].Value; text != `"şğı"` {
+		t.Errorf("Turkish short_message decodes to %s, want \"şğı\"", text)
+	}
+
+	for name, doc := range map[string]string{
+		"misspelt key":     `{"name":"submit_sm","body":{},"tlv":[]}`,
+		"truncated PDU":    `{"name":"submit_sm","fields":[],"error":"header: short"}`,
+		"gap in a listing": `{"name":"enquire_link","fields":[{"offset":4,"name":"command_id","hex":"00000015"}]}`,
+	} {
+		if _, err := findPDUDescription([]byte(doc), false); err == nil {
+			t.Errorf("%s: %s was accepted", name, doc)
+		}
+	}
+}
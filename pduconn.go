@@ -0,0 +1,72 @@
+package main
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+)
+
+// pduConn wraps a net.Conn and re-frames the inbound byte stream into whole
+// PDUs before gosmpp reads them. This lets the client observe every inbound
+// PDU and consume those gosmpp cannot parse (such as SMPP 5.0 broadcast
+// responses) instead of having them tear down the session.
+type pduConn struct {
+	net.Conn
+	r       *bufio.Reader
+	pending []byte
+
+	// inbound is called with every complete PDU read from the connection.
+	// Returning true consumes the PDU so it is not passed on to gosmpp.
+	inbound func(frame []byte) bool
+}
+
+func newPDUConn(conn net.Conn, inbound func(frame []byte) bool) *pduConn {
+	return &pduConn{
+		Conn:    conn,
+		r:       bufio.NewReader(conn),
+		inbound: inbound,
+	}
+}
+
+// Read implements net.Conn, returning bytes of whole PDUs only.
+func (p *pduConn) Read(b []byte) (int, error) {
+	for len(p.pending) == 0 {
+		frame, err := p.readFrame()
+		if err != nil {
+			return 0, err
+		}
+		if p.inbound != nil && p.inbound(frame) {
+			continue
+		}
+		p.pending = frame
+	}
+	n := copy(b, p.pending)
+	p.pending = p.pending[n:]
+	return n, nil
+}
+
+func (p *pduConn) readFrame() ([]byte, error) {
+	var lenBuf [4]byte
+	if _, err := io.ReadFull(p.r, lenBuf[:]); err != nil {
+		return nil, err
+	}
+	length := binary.BigEndian.Uint32(lenBuf[:])
+	if length < pduHeaderLen || length > maxPDULen {
+		return nil, fmt.Errorf("invalid command_length %d", length)
+	}
+	frame := make([]byte, length)
+	copy(frame, lenBuf[:])
+	if _, err := io.ReadFull(p.r, frame[4:]); err != nil {
+		return nil, err
+	}
+	return frame, nil
+}
+
+// writeFrame writes a pre-encoded PDU. A single Write keeps the PDU
+// contiguous with respect to writes issued by gosmpp on the same conn.
+func (p *pduConn) writeFrame(frame []byte) error {
+	_, err := p.Conn.Write(frame)
+	return err
+}
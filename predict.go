@@ -0,0 +1,113 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"strings"
+
+	"github.com/fatih/color"
+)
+
+// predictedResponse is the response command a compliant SMSC sends for
+// status: generic_nack when it cannot tell which command it received.
+func predictedResponse(status CommandStatus) string {
+	if status == ESME_RINVCMDID {
+		return "generic_nack"
+	}
+	return "submit_sm_resp"
+}
+
+// compareExpectedOutput fills the expected-output fields of res by
+// comparing the test case's expected response with the predicted one.
+func compareExpectedOutput(tc TestCase, res *ValidationResult) {
+	exp := tc.ExpectedOutput
+	res.Mismatches = nil
+	if res.TestDataError {
+		// Nothing is sent, so no expected output can match.
+		res.Mismatches = append(res.Mismatches, "the test case cannot be sent as written, fix the test data")
+		res.ExpectedOutputMatch = false
+		return
+	}
+	if exp.CommandID != nil && !strings.EqualFold(*exp.CommandID, predictedResponse(res.PredictedStatus)) {
+		res.Mismatches = append(res.Mismatches, fmt.Sprintf("expected command_id %s but a compliant SMSC answers %s",
+			*exp.CommandID, predictedResponse(res.PredictedStatus)))
+	}
+	if exp.CommandStatus != nil && CommandStatus(*exp.CommandStatus) != res.PredictedStatus {
+		res.Mismatches = append(res.Mismatches, fmt.Sprintf("expected command_status %s but a compliant SMSC returns %s",
+			CommandStatus(*exp.CommandStatus), res.PredictedStatus))
+	}
+	if exp.DeliveryStatus != nil {
+		accepted := res.PredictedStatus == ESME_ROK
+		switch strings.ToLower(strings.TrimSpace(*exp.DeliveryStatus)) {
+		case "accepted":
+			if !accepted {
+				res.Mismatches = append(res.Mismatches, fmt.Sprintf("expected delivery_status accepted but the SMSC should reject with %s", res.PredictedStatus))
+			}
+		case "failed", "rejected":
+			if accepted {
+				res.Mismatches = append(res.Mismatches, fmt.Sprintf("expected delivery_status %s but the PDU is valid and should be accepted", *exp.DeliveryStatus))
+			}
+		}
+	}
+	res.ExpectedOutputMatch = len(res.Mismatches) == 0
+}
+
+// runPredict implements the "predict" subcommand: it validates every test
+// case offline and reports those whose expected output contradicts SMPP.
+func runPredict(args []string) int {
+	fs := flag.NewFlagSet("predict", flag.ExitOnError)
+	file := fs.String("file", "test-case.jsonl", "path to JSON/JSONL file containing test cases")
+	asJSON := fs.Bool("json", false, "print results as JSON lines")
+	packing := packingFlag(fs)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: predict [-file TESTCASES] [-packing MODE] [-json]")
+		fs.PrintDefaults()
+	}
+	_ = fs.Parse(args)
+
+	opts := ValidationOptions{Packing: *packing}
+	tests, err := parseFile(*file)
+	if err != nil {
+		color.Red("error parsing file: %v", err)
+		return 1
+	}
+
+	status := 0
+	for i, tc := range tests {
+		res := validateTestCase(i+1, tc, opts)
+		if !res.ExpectedOutputMatch {
+			status = 1
+		}
+		if *asJSON {
+			b, _ := json.Marshal(res)
+			fmt.Println(string(b))
+			continue
+		}
+		printPrediction(tc, res)
+	}
+	return status
+}
+
+func printPrediction(tc TestCase, res ValidationResult) {
+	color.Green("Test #%d (test_case_id %d):", res.Index, tc.TestCaseId)
+	if res.TestDataError {
+		fmt.Println("  predicted: none (test data error)")
+	} else {
+		fmt.Printf("  predicted: %s %s\n", predictedResponse(res.PredictedStatus), res.PredictedStatus)
+	}
+	for _, e := range res.Errors {
+		fmt.Printf("  error: %s\n", e)
+	}
+	if tc.ExpectedOutput.CommandStatus != nil {
+		fmt.Printf("  expected: %s\n", CommandStatus(*tc.ExpectedOutput.CommandStatus))
+	}
+	if res.ExpectedOutputMatch {
+		color.Green("  expected_output_match: YES")
+		return
+	}
+	color.Red("  expected_output_match: NO")
+	for _, m := range res.Mismatches {
+		color.Yellow("    mismatch: %s", m)
+	}
+}
@@ -0,0 +1,39 @@
+package main
+
+import "testing"
+
+func TestPredictTestDataErrors(t *testing.T) {
+	base := func() InputPDU {
+		return InputPDU{
+			SourceAddr:      strPtr("ACME"),
+			DestinationAddr: strPtr("447700900001"),
+			DataCoding:      intPtr(0),
+			ShortMessage:    strPtr("Hello"),
+		}
+	}
+	stale := base()
+	stale.SmLength = intPtr(10)
+	badUDH := base()
+	badUDH.UDHHex = strPtr("05000")
+	badPayload := base()
+	badPayload.ShortMessage = nil
+	badPayload.ShortMessageHex = strPtr("zz")
+
+	for name, in := range map[string]InputPDU{"stale sm_length": stale, "bad udh_hex": badUDH, "bad payload": badPayload} {
+		tc := TestCase{TestCaseId: 1, InputPdu: in, ExpectedOutput: ExpectedOutput{CommandStatus: intPtr(int(ESME_RINVCMDLEN))}}
+		res := validateTestCase(1, tc, ValidationOptions{})
+		if res.Valid || !res.TestDataError {
+			t.Errorf("%s: valid %v, test data error %v; want a test data error", name, res.Valid, res.TestDataError)
+		}
+		if res.ExpectedOutputMatch {
+			t.Errorf("%s: an expected SMSC status matches a test case that is never sent", name)
+		}
+	}
+
+	current := base()
+	current.SmLength = intPtr(5)
+	res := validateTestCase(1, TestCase{TestCaseId: 1, InputPdu: current}, ValidationOptions{})
+	if !res.Valid || res.TestDataError || res.PredictedStatus != ESME_ROK {
+		t.Errorf("matching sm_length: %+v, want a valid test case", res)
+	}
+}
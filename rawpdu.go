@@ -0,0 +1,334 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/linxGnu/gosmpp/pdu"
+)
+
+// pduHeaderLen is the size of the fixed SMPP PDU header.
+const pduHeaderLen = 16
+
+// maxPDULen bounds command_length for PDUs we are willing to buffer.
+const maxPDULen = 64 * 1024
+
+// SMPP command_id values handled outside gosmpp.
+const (
+	cmdGenericNack          uint32 = 0x80000000
+	cmdSubmitSM             uint32 = 0x00000004
+	cmdSubmitSMResp         uint32 = 0x80000004
+	cmdDeliverSM            uint32 = 0x00000005
+	cmdDeliverSMResp        uint32 = 0x80000005
+	cmdUnbind               uint32 = 0x00000006
+	cmdUnbindResp           uint32 = 0x80000006
+	cmdBindTransceiver      uint32 = 0x00000009
+	cmdBindTransceiverResp  uint32 = 0x80000009
+	cmdEnquireLink          uint32 = 0x00000015
+	cmdEnquireLinkResp      uint32 = 0x80000015
+	cmdDataSM               uint32 = 0x00000103
+	cmdDataSMResp           uint32 = 0x80000103
+	cmdBroadcastSM          uint32 = 0x00000111
+	cmdQueryBroadcastSM     uint32 = 0x00000112
+	cmdCancelBroadcastSM    uint32 = 0x00000113
+	cmdBroadcastSMResp      uint32 = 0x80000111
+	cmdQueryBroadcastSMResp uint32 = 0x80000112
+	cmdCancelBroadcastResp  uint32 = 0x80000113
+)
+
+// Optional parameter tags used by the raw PDU layer.
+const (
+	tagScInterfaceVersion         uint16 = 0x0210
+	tagUserMessageReference       uint16 = 0x0204
+	tagMessagePayload             uint16 = 0x0424
+	tagMessageState               uint16 = 0x0427
+	tagCongestionState            uint16 = 0x0428
+	tagBroadcastChannelIndicator  uint16 = 0x0600
+	tagBroadcastContentType       uint16 = 0x0601
+	tagBroadcastMessageClass      uint16 = 0x0603
+	tagBroadcastRepNum            uint16 = 0x0604
+	tagBroadcastFrequencyInterval uint16 = 0x0605
+	tagBroadcastAreaIdentifier    uint16 = 0x0606
+	tagBroadcastErrorStatus       uint16 = 0x0607
+	tagBroadcastAreaSuccess       uint16 = 0x0608
+	tagBroadcastEndTime           uint16 = 0x0609
+	tagBroadcastServiceGroup      uint16 = 0x060A
+)
+
+var errShortPDU = errors.New("pdu too short")
+
+// pduHeader is the decoded fixed header of an SMPP PDU.
+type pduHeader struct {
+	Length    uint32
+	CommandID uint32
+	Status    CommandStatus
+	Sequence  uint32
+}
+
+func parsePDUHeader(b []byte) (pduHeader, error) {
+	if len(b) < pduHeaderLen {
+		return pduHeader{}, errShortPDU
+	}
+	return pduHeader{
+		Length:    binary.BigEndian.Uint32(b[0:4]),
+		CommandID: binary.BigEndian.Uint32(b[4:8]),
+		Status:    CommandStatus(binary.BigEndian.Uint32(b[8:12])),
+		Sequence:  binary.BigEndian.Uint32(b[12:16]),
+	}, nil
+}
+
+// rawTLV is an undecoded optional parameter.
+type rawTLV struct {
+	Tag   uint16
+	Value []byte
+}
+
+// pduBuilder assembles a PDU body field by field.
+type pduBuilder struct {
+	body bytes.Buffer
+}
+
+func (b *pduBuilder) cstring(s string) {
+	b.body.WriteString(s)
+	b.body.WriteByte(0)
+}
+
+func (b *pduBuilder) octet(v byte) {
+	b.body.WriteByte(v)
+}
+
+func (b *pduBuilder) uint16(v uint16) {
+	_ = binary.Write(&b.body, binary.BigEndian, v)
+}
+
+func (b *pduBuilder) octets(v []byte) {
+	b.body.Write(v)
+}
+
+func (b *pduBuilder) tlv(tag uint16, value []byte) {
+	b.uint16(tag)
+	b.uint16(uint16(len(value)))
+	b.body.Write(value)
+}
+
+// bytes prepends the header to the body assembled so far.
+func (b *pduBuilder) bytes(commandID uint32, status CommandStatus, sequence uint32) []byte {
+	out := make([]byte, pduHeaderLen, pduHeaderLen+b.body.Len())
+	binary.BigEndian.PutUint32(out[0:4], uint32(pduHeaderLen+b.body.Len()))
+	binary.BigEndian.PutUint32(out[4:8], commandID)
+	binary.BigEndian.PutUint32(out[8:12], uint32(status))
+	binary.BigEndian.PutUint32(out[12:16], sequence)
+	return append(out, b.body.Bytes()...)
+}
+
+// pduReader reads body fields sequentially. The first error sticks and
+// subsequent reads return zero values.
+type pduReader struct {
+	b   []byte
+	off int
+	err error
+}
+
+func newPDUReader(body []byte) *pduReader {
+	return &pduReader{b: body}
+}
+
+func (r *pduReader) remaining() int {
+	return len(r.b) - r.off
+}
+
+func (r *pduReader) cstring(field string) string {
+	if r.err != nil {
+		return ""
+	}
+	i := bytes.IndexByte(r.b[r.off:], 0)
+	if i < 0 {
+		r.err = fmt.Errorf("%s: missing C-string terminator", field)
+		return ""
+	}
+	s := string(r.b[r.off : r.off+i])
+	r.off += i + 1
+	return s
+}
+
+func (r *pduReader) octet(field string) byte {
+	if r.err != nil {
+		return 0
+	}
+	if r.remaining() < 1 {
+		r.err = fmt.Errorf("%s: %w", field, errShortPDU)
+		return 0
+	}
+	v := r.b[r.off]
+	r.off++
+	return v
+}
+
+func (r *pduReader) octets(field string, n int) []byte {
+	if r.err != nil {
+		return nil
+	}
+	if n < 0 || r.remaining() < n {
+		r.err = fmt.Errorf("%s: %w", field, errShortPDU)
+		return nil
+	}
+	v := r.b[r.off : r.off+n]
+	r.off += n
+	return v
+}
+
+// tlvs reads optional parameters until the end of the body.
+func (r *pduReader) tlvs() []rawTLV {
+	var out []rawTLV
+	for r.err == nil && r.remaining() > 0 {
+		hdr := r.octets("tlv header", 4)
+		if r.err != nil {
+			break
+		}
+		tag := binary.BigEndian.Uint16(hdr[0:2])
+		length := int(binary.BigEndian.Uint16(hdr[2:4]))
+		value := r.octets(fmt.Sprintf("tlv 0x%04X", tag), length)
+		out = append(out, rawTLV{Tag: tag, Value: value})
+	}
+	return out
+}
+
+// findTLV returns the value of the first TLV with the given tag.
+func findTLV(tlvs []rawTLV, tag uint16) ([]byte, bool) {
+	for _, t := range tlvs {
+		if t.Tag == tag {
+			return t.Value, true
+		}
+	}
+	return nil, false
+}
+
+// rawSubmitSM is a submit_sm body field by field, as it goes on the wire.
+type rawSubmitSM struct {
+	ServiceType          string
+	SourceAddrTON        byte
+	SourceAddrNPI        byte
+	SourceAddr           string
+	DestAddrTON          byte
+	DestAddrNPI          byte
+	DestinationAddr      string
+	EsmClass             byte
+	ProtocolID           byte
+	PriorityFlag         byte
+	ScheduleDeliveryTime string
+	ValidityPeriod       string
+	RegisteredDelivery   byte
+	ReplaceIfPresentFlag byte
+	DataCoding           byte
+	SMDefaultMsgID       byte
+	ShortMessage         []byte // UDH included
+	TLVs                 []rawTLV
+}
+
+// rawSubmitSMOf returns the fields gosmpp sends for p. Optional parameters
+// are ordered by tag.
+func rawSubmitSMOf(p *pdu.SubmitSM) (rawSubmitSM, error) {
+	data, err := p.Message.GetMessageData()
+	if err != nil {
+		return rawSubmitSM{}, err
+	}
+	s := rawSubmitSM{
+		ServiceType:          p.ServiceType,
+		SourceAddrTON:        p.SourceAddr.Ton(),
+		SourceAddrNPI:        p.SourceAddr.Npi(),
+		SourceAddr:           p.SourceAddr.Address(),
+		DestAddrTON:          p.DestAddr.Ton(),
+		DestAddrNPI:          p.DestAddr.Npi(),
+		DestinationAddr:      p.DestAddr.Address(),
+		EsmClass:             p.EsmClass,
+		ProtocolID:           p.ProtocolID,
+		PriorityFlag:         p.PriorityFlag,
+		ScheduleDeliveryTime: p.ScheduleDeliveryTime,
+		ValidityPeriod:       p.ValidityPeriod,
+		RegisteredDelivery:   p.RegisteredDelivery,
+		ReplaceIfPresentFlag: p.ReplaceIfPresentFlag,
+		ShortMessage:         append(udhBytes(p.Message.UDH()), data...),
+	}
+	if enc := p.Message.Encoding(); enc != nil {
+		s.DataCoding = enc.DataCoding()
+	}
+	for tag, f := range p.OptionalParameters {
+		s.TLVs = append(s.TLVs, rawTLV{Tag: uint16(tag), Value: f.Data})
+	}
+	sort.Slice(s.TLVs, func(i, j int) bool { return s.TLVs[i].Tag < s.TLVs[j].Tag })
+	return s, nil
+}
+
+// bytes encodes s as a complete submit_sm PDU.
+func (s rawSubmitSM) bytes(sequence uint32) ([]byte, error) {
+	for _, f := range []struct{ name, value string }{
+		{"service_type", s.ServiceType},
+		{"source_addr", s.SourceAddr},
+		{"destination_addr", s.DestinationAddr},
+		{"schedule_delivery_time", s.ScheduleDeliveryTime},
+		{"validity_period", s.ValidityPeriod},
+	} {
+		if strings.IndexByte(f.value, 0) >= 0 {
+			return nil, fmt.Errorf("%s: contains a NUL octet", f.name)
+		}
+	}
+	if len(s.ShortMessage) > 0xFF {
+		return nil, fmt.Errorf("short_message: %d octets do not fit sm_length", len(s.ShortMessage))
+	}
+	var b pduBuilder
+	b.cstring(s.ServiceType)
+	b.octet(s.SourceAddrTON)
+	b.octet(s.SourceAddrNPI)
+	b.cstring(s.SourceAddr)
+	b.octet(s.DestAddrTON)
+	b.octet(s.DestAddrNPI)
+	b.cstring(s.DestinationAddr)
+	b.octet(s.EsmClass)
+	b.octet(s.ProtocolID)
+	b.octet(s.PriorityFlag)
+	b.cstring(s.ScheduleDeliveryTime)
+	b.cstring(s.ValidityPeriod)
+	b.octet(s.RegisteredDelivery)
+	b.octet(s.ReplaceIfPresentFlag)
+	b.octet(s.DataCoding)
+	b.octet(s.SMDefaultMsgID)
+	b.octet(byte(len(s.ShortMessage)))
+	b.octets(s.ShortMessage)
+	for _, t := range s.TLVs {
+		if len(t.Value) > 0xFFFF {
+			return nil, fmt.Errorf("tlv 0x%04X: %d octets do not fit the length field", t.Tag, len(t.Value))
+		}
+		b.tlv(t.Tag, t.Value)
+	}
+	return b.bytes(cmdSubmitSM, ESME_ROK, sequence), nil
+}
+
+// decodeSubmitSM decodes a submit_sm body.
+func decodeSubmitSM(body []byte) (rawSubmitSM, error) {
+	r := newPDUReader(body)
+	var s rawSubmitSM
+	s.ServiceType = r.cstring("service_type")
+	s.SourceAddrTON = r.octet("source_addr_ton")
+	s.SourceAddrNPI = r.octet("source_addr_npi")
+	s.SourceAddr = r.cstring("source_addr")
+	s.DestAddrTON = r.octet("dest_addr_ton")
+	s.DestAddrNPI = r.octet("dest_addr_npi")
+	s.DestinationAddr = r.cstring("destination_addr")
+	s.EsmClass = r.octet("esm_class")
+	s.ProtocolID = r.octet("protocol_id")
+	s.PriorityFlag = r.octet("priority_flag")
+	s.ScheduleDeliveryTime = r.cstring("schedule_delivery_time")
+	s.ValidityPeriod = r.cstring("validity_period")
+	s.RegisteredDelivery = r.octet("registered_delivery")
+	s.ReplaceIfPresentFlag = r.octet("replace_if_present_flag")
+	s.DataCoding = r.octet("data_coding")
+	s.SMDefaultMsgID = r.octet("sm_default_msg_id")
+	smLength := r.octet("sm_length")
+	s.ShortMessage = r.octets("short_message", int(smLength))
+	s.TLVs = r.tlvs()
+	return s, r.err
+}
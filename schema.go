@@ -0,0 +1,264 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/fatih/color"
+)
+
+const jsonSchemaDraft = "https://json-schema.org/draft/2020-12/schema"
+
+// JSONSchema is the subset of JSON Schema used to describe test files.
+type JSONSchema struct {
+	Schema     string                 `json:"$schema,omitempty"`
+	ID         string                 `json:"$id,omitempty"`
+	Title      string                 `json:"title,omitempty"`
+	Type       string                 `json:"type,omitempty"`
+	Properties map[string]*JSONSchema `json:"properties,omitempty"`
+	Required   []string               `json:"required,omitempty"`
+	Items      *JSONSchema            `json:"items,omitempty"`
+	OneOf      []*JSONSchema          `json:"oneOf,omitempty"`
+	Pattern    string                 `json:"pattern,omitempty"`
+	MinItems   int                    `json:"minItems,omitempty"`
+}
+
+// schemaOverrides gives the schema of types whose JSON form differs from
+// their Go kind.
+var schemaOverrides = map[reflect.Type]*JSONSchema{
+	reflect.TypeOf(TLVTag(0)): {OneOf: []*JSONSchema{
+		{Type: "integer"},
+		{Type: "string", Pattern: `^\s*(0[xX][0-9a-fA-F]+|[0-9]+)\s*$`},
+	}},
+	reflect.TypeOf(json.RawMessage(nil)): {},
+}
+
+// schemaRequired lists the properties a test file must give. encoding/json
+// itself requires none; these are the ones the runner cannot do without.
+var schemaRequired = map[reflect.Type][]string{
+	reflect.TypeOf(TestCase{}): {"input_pdu", "expected_output_pdu"},
+}
+
+// schemaFor derives a schema from t following encoding/json rules.
+func schemaFor(t reflect.Type) *JSONSchema {
+	if s, ok := schemaOverrides[t]; ok {
+		return s
+	}
+	switch t.Kind() {
+	case reflect.Ptr:
+		return schemaFor(t.Elem())
+	case reflect.Struct:
+		s := &JSONSchema{Type: "object", Properties: map[string]*JSONSchema{}, Required: schemaRequired[t]}
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			if f.PkgPath != "" {
+				continue
+			}
+			name := strings.Split(f.Tag.Get("json"), ",")[0]
+			if name == "-" {
+				continue
+			}
+			if name == "" {
+				name = f.Name
+			}
+			s.Properties[name] = schemaFor(f.Type)
+		}
+		return s
+	case reflect.Slice, reflect.Array:
+		return &JSONSchema{Type: "array", Items: schemaFor(t.Elem())}
+	case reflect.String:
+		return &JSONSchema{Type: "string"}
+	case reflect.Bool:
+		return &JSONSchema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return &JSONSchema{Type: "integer"}
+	case reflect.Float32, reflect.Float64:
+		return &JSONSchema{Type: "number"}
+	}
+	return &JSONSchema{}
+}
+
+// testCaseSchema describes one test case: a JSONL line or an array element.
+func testCaseSchema() *JSONSchema {
+	s := schemaFor(reflect.TypeOf(TestCase{}))
+	s.Schema = jsonSchemaDraft
+	s.ID = "test-case.schema.json"
+	s.Title = "SMPP test case"
+	return s
+}
+
+// testCasesSchema describes a JSON array test file.
+func testCasesSchema() *JSONSchema {
+	item := schemaFor(reflect.TypeOf(TestCase{}))
+	return &JSONSchema{
+		Schema:   jsonSchemaDraft,
+		ID:       "test-cases.schema.json",
+		Title:    "SMPP test cases",
+		Type:     "array",
+		Items:    item,
+		MinItems: 1,
+	}
+}
+
+// schemaFiles are the schemas shipped in the schema directory, one per
+// supported test file format.
+var schemaFiles = map[string]func() *JSONSchema{
+	"test-case.schema.json":  testCaseSchema,  // JSONL: one test case per line
+	"test-cases.schema.json": testCasesSchema, // JSON array of test cases
+}
+
+// validateJSON checks v, decoded with UseNumber, against s and returns the
+// violations with their JSON paths. null is accepted wherever a value is,
+// as encoding/json does.
+func (s *JSONSchema) validateJSON(v interface{}, path string) []string {
+	if v == nil {
+		return nil
+	}
+	if len(s.OneOf) > 0 {
+		for _, alt := range s.OneOf {
+			if len(alt.validateJSON(v, path)) == 0 {
+				return nil
+			}
+		}
+		return []string{fmt.Sprintf("%s: %s does not match any allowed form", path, jsonKind(v))}
+	}
+	mismatch := func() []string {
+		return []string{fmt.Sprintf("%s: expected %s, got %s", path, s.Type, jsonKind(v))}
+	}
+	switch s.Type {
+	case "object":
+		obj, ok := v.(map[string]interface{})
+		if !ok {
+			return mismatch()
+		}
+		var errs []string
+		for _, name := range s.Required {
+			if _, ok := obj[name]; !ok {
+				errs = append(errs, fmt.Sprintf("%s: missing required property %q", path, name))
+			}
+		}
+		names := make([]string, 0, len(obj))
+		for name := range obj {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			if p, ok := s.Properties[name]; ok {
+				errs = append(errs, p.validateJSON(obj[name], path+"."+name)...)
+			}
+		}
+		return errs
+	case "array":
+		arr, ok := v.([]interface{})
+		if !ok {
+			return mismatch()
+		}
+		var errs []string
+		if len(arr) < s.MinItems {
+			errs = append(errs, fmt.Sprintf("%s: expected at least %d item(s)", path, s.MinItems))
+		}
+		for i, item := range arr {
+			errs = append(errs, s.Items.validateJSON(item, fmt.Sprintf("%s[%d]", path, i))...)
+		}
+		return errs
+	case "string":
+		str, ok := v.(string)
+		if !ok {
+			return mismatch()
+		}
+		if s.Pattern != "" && !regexp.MustCompile(s.Pattern).MatchString(str) {
+			return []string{fmt.Sprintf("%s: %q does not match %s", path, str, s.Pattern)}
+		}
+	case "integer":
+		n, ok := v.(json.Number)
+		if !ok {
+			return mismatch()
+		}
+		if _, err := n.Int64(); err != nil {
+			return []string{fmt.Sprintf("%s: expected integer, got %s", path, n)}
+		}
+	case "number":
+		if _, ok := v.(json.Number); !ok {
+			return mismatch()
+		}
+	case "boolean":
+		if _, ok := v.(bool); !ok {
+			return mismatch()
+		}
+	}
+	return nil
+}
+
+func jsonKind(v interface{}) string {
+	switch v.(type) {
+	case map[string]interface{}:
+		return "object"
+	case []interface{}:
+		return "array"
+	case string:
+		return "string"
+	case json.Number:
+		return "number"
+	case bool:
+		return "boolean"
+	}
+	return "null"
+}
+
+// validateTestFile checks raw against s. path names the root, e.g. "$" or
+// "$[3]" for a JSONL line.
+func validateTestFile(s *JSONSchema, raw []byte, path string) []string {
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.UseNumber()
+	var v interface{}
+	if err := dec.Decode(&v); err != nil {
+		return []string{fmt.Sprintf("%s: %v", path, err)}
+	}
+	return s.validateJSON(v, path)
+}
+
+// schemaError reports schema violations found by parseFile.
+func schemaError(errs []string) error {
+	return fmt.Errorf("test file does not match the test case schema:\n  %s", strings.Join(errs, "\n  "))
+}
+
+// runSchema implements the "schema" subcommand, writing the schema files.
+func runSchema(args []string) int {
+	fs := flag.NewFlagSet("schema", flag.ExitOnError)
+	out := fs.String("out", "schema", "directory to write the schema files to")
+	_ = fs.Parse(args)
+
+	names := make([]string, 0, len(schemaFiles))
+	for name := range schemaFiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		b, err := json.MarshalIndent(schemaFiles[name](), "", "  ")
+		if err != nil {
+			color.Red("%v", err)
+			return 1
+		}
+		b = append(b, '\n')
+		if err := os.MkdirAll(*out, 0o755); err != nil {
+			color.Red("%v", err)
+			return 1
+		}
+		path := filepath.Join(*out, name)
+		if err := os.WriteFile(path, b, 0o644); err != nil {
+			color.Red("%v", err)
+			return 1
+		}
+		color.Green("wrote %s", path)
+	}
+	return 0
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestSchemaFilesUpToDate fails when the shipped schemas drift from the
+// test case types.
+func TestSchemaFilesUpToDate(t *testing.T) {
+	for name, schema := range schemaFiles {
+		want, err := json.MarshalIndent(schema(), "", "  ")
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := os.ReadFile(filepath.Join("schema", name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != string(want)+"\n" {
+			t.Errorf("schema/%s is out of date: run the schema subcommand to regenerate it", name)
+		}
+	}
+}
+
+func TestValidateTestFile(t *testing.T) {
+	cases := []struct {
+		schema *JSONSchema
+		file   string
+		want   []string
+	}{
+		{testCaseSchema(), `{"input_pdu": {"short_message": "Hi", "unknown": [1]}, "expected_output_pdu": null}`, nil},
+		{testCaseSchema(), `{"input_pdu": {}}`, []string{`$: missing required property "expected_output_pdu"`}},
+		{testCaseSchema(), `{
+			"test_case_id": "7",
+			"input_pdu": {"data_coding": "8", "sm_length": 1.5, "transliterate": "yes",
+				"tlvs": [{"name": "source_port", "value": 1}, {"tag": "port"}, {"tag": "0x1400"}, 5]},
+			"expected_output_pdu": {"command_status": -1, "deliver_sm_tlvs": {}}
+		}`, []string{
+			`$.expected_output_pdu.deliver_sm_tlvs: expected array, got object`,
+			`$.input_pdu.data_coding: expected integer, got string`,
+			`$.input_pdu.sm_length: expected integer, got 1.5`,
+			`$.input_pdu.tlvs[1].tag: string does not match any allowed form`,
+			`$.input_pdu.tlvs[3]: expected object, got number`,
+			`$.input_pdu.transliterate: expected boolean, got string`,
+			`$.test_case_id: expected integer, got string`,
+		}},
+		{testCasesSchema(), `[]`, []string{`$: expected at least 1 item(s)`}},
+		{testCasesSchema(), `[{"input_pdu": {}, "expected_output_pdu": {}}, {"input_pdu": []}]`, []string{
+			`$[1]: missing required property "expected_output_pdu"`,
+			`$[1].input_pdu: expected object, got array`,
+		}},
+		{testCasesSchema(), `{"input_pdu": {}}`, []string{`$: expected array, got object`}},
+	}
+	for _, tc := range cases {
+		got := validateTestFile(tc.schema, []byte(tc.file), "$")
+		if strings.Join(got, "\n") != strings.Join(tc.want, "\n") {
+			t.Errorf("%.60s:\n%s\nwant:\n%s", tc.file, strings.Join(got, "\n"), strings.Join(tc.want, "\n"))
+		}
+	}
+}
+
+func TestParseFileSchemaErrors(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cases.jsonl")
+	lines := `{"input_pdu": {"short_message": "ok"}, "expected_output_pdu": {}}
+{"input_pdu": {"data_coding": "8"}, "expected_output_pdu": {}}
+{"input_pdu": {}, "expected_output_pdu": {"message_id": 5}}
+`
+	if err := os.WriteFile(path, []byte(lines), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	_, err := parseFile(path)
+	if err == nil {
+		t.Fatal("parseFile accepted a file with schema violations")
+	}
+	for _, want := range []string{"$[1].input_pdu.data_coding: expected integer, got string", "$[2].expected_output_pdu.message_id: expected string, got number"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("parseFile error %q does not report %s", err, want)
+		}
+	}
+}
@@ -0,0 +1,226 @@
+package main
+
+import (
+	"fmt"
+	"sync/atomic"
+	"unicode"
+	"unicode/utf16"
+)
+
+// concatIEI is the 8-bit reference concatenated message information element.
+const concatIEI byte = 0x00
+
+// concatRef numbers concatenated messages; only the low byte is sent.
+var concatRef atomic.Uint32
+
+// MessageSegment is one part of a message split for concatenation.
+type MessageSegment struct {
+	Text string
+	// Data is the encoded user data without UDH: unpacked septets for GSM
+	// 7-bit, UTF-16BE for UCS2 and single octets for Latin-1.
+	Data []byte
+	// Length is in septets for GSM 7-bit and in octets otherwise.
+	Length int
+}
+
+// segmentCapacity returns the user data capacity of a segment in septets
+// (GSM 7-bit) or octets, given the national language IEs and whether the
+// concatenation IE is needed.
+func segmentCapacity(report EncodingReport, concat bool) int {
+	udh := len(report.gsmTables().udh())
+	if concat {
+		udh += 5
+	}
+	if report.Encoding == EncodingGSM7 {
+		if udh == 0 {
+			return 160
+		}
+		return 160 - udhSeptets(1+udh)
+	}
+	if udh == 0 {
+		return 140
+	}
+	return 140 - (1 + udh)
+}
+
+// segmentCount is the number of segments report's message needs, assuming
+// no segment boundary is forced early by a character that cannot be split.
+func segmentCount(report EncodingReport) int {
+	if report.Length <= 0 {
+		return 0
+	}
+	if report.Length <= segmentCapacity(report, false) {
+		return 1
+	}
+	capacity := segmentCapacity(report, true)
+	return (report.Length + capacity - 1) / capacity
+}
+
+// splitMessage splits text into segments that never separate a UTF-16
+// surrogate pair or a GSM escape from its character. With graphemes set,
+// user-perceived characters (combining marks, emoji modifier and ZWJ
+// sequences, flags) are also kept together unless one alone exceeds a segment.
+func splitMessage(text string, report EncodingReport, graphemes bool) ([]MessageSegment, error) {
+	var clusters [][]rune
+	if graphemes {
+		clusters = graphemeClusters(text)
+	} else {
+		for _, r := range text {
+			clusters = append(clusters, []rune{r})
+		}
+	}
+
+	total := 0
+	for _, cl := range clusters {
+		n, err := encodedLength(cl, report)
+		if err != nil {
+			return nil, err
+		}
+		total += n
+	}
+	if total <= segmentCapacity(report, false) {
+		seg, err := newMessageSegment(string(flatten(clusters)), report)
+		if err != nil {
+			return nil, err
+		}
+		return []MessageSegment{seg}, nil
+	}
+
+	capacity := segmentCapacity(report, true)
+	var segments []MessageSegment
+	var current []rune
+	used := 0
+	flush := func() error {
+		if len(current) == 0 {
+			return nil
+		}
+		seg, err := newMessageSegment(string(current), report)
+		if err != nil {
+			return err
+		}
+		segments = append(segments, seg)
+		current, used = nil, 0
+		return nil
+	}
+	for _, cl := range clusters {
+		n, _ := encodedLength(cl, report)
+		if used+n > capacity {
+			if err := flush(); err != nil {
+				return nil, err
+			}
+		}
+		if n <= capacity {
+			current = append(current, cl...)
+			used += n
+			continue
+		}
+		// A cluster larger than a segment is split between its code points.
+		for _, r := range cl {
+			m, _ := encodedLength([]rune{r}, report)
+			if used+m > capacity {
+				if err := flush(); err != nil {
+					return nil, err
+				}
+			}
+			current = append(current, r)
+			used += m
+		}
+	}
+	if err := flush(); err != nil {
+		return nil, err
+	}
+	if len(segments) > 255 {
+		return nil, fmt.Errorf("message needs %d segments, at most 255 are allowed", len(segments))
+	}
+	return segments, nil
+}
+
+func flatten(clusters [][]rune) []rune {
+	var out []rune
+	for _, cl := range clusters {
+		out = append(out, cl...)
+	}
+	return out
+}
+
+// encodedLength is the size of runes in septets or octets under report's encoding.
+func encodedLength(runes []rune, report EncodingReport) (int, error) {
+	switch report.Encoding {
+	case EncodingGSM7:
+		return report.gsmTables().septetCount(string(runes))
+	case EncodingLatin1:
+		return len(runes), nil
+	}
+	return len(utf16.Encode(runes)) * 2, nil
+}
+
+func newMessageSegment(text string, report EncodingReport) (MessageSegment, error) {
+	seg := MessageSegment{Text: text}
+	switch report.Encoding {
+	case EncodingGSM7:
+		septets, err := report.gsmTables().encode(text)
+		if err != nil {
+			return seg, err
+		}
+		seg.Data = septets
+	case EncodingLatin1:
+		for _, r := range text {
+			if r > 0xFF {
+				return seg, fmt.Errorf("rune %U (%q) not representable in Latin-1", r, r)
+			}
+			seg.Data = append(seg.Data, byte(r))
+		}
+	default:
+		for _, u := range utf16.Encode([]rune(text)) {
+			seg.Data = append(seg.Data, byte(u>>8), byte(u))
+		}
+	}
+	seg.Length = len(seg.Data)
+	return seg, nil
+}
+
+// graphemeClusters groups runes into approximate extended grapheme clusters:
+// a base character followed by combining marks, variation selectors, emoji
+// modifiers and tag characters, ZWJ-joined sequences and regional indicator
+// pairs.
+func graphemeClusters(text string) [][]rune {
+	var clusters [][]rune
+	joined := false
+	for _, r := range text {
+		n := len(clusters)
+		switch {
+		case n > 0 && (joined || isGraphemeExtend(r)):
+			clusters[n-1] = append(clusters[n-1], r)
+		case n > 0 && isRegionalIndicator(r) && len(clusters[n-1]) == 1 && isRegionalIndicator(clusters[n-1][0]):
+			clusters[n-1] = append(clusters[n-1], r)
+		case n > 0 && r == '\n' && len(clusters[n-1]) == 1 && clusters[n-1][0] == '\r':
+			clusters[n-1] = append(clusters[n-1], r)
+		default:
+			clusters = append(clusters, []rune{r})
+		}
+		joined = r == zeroWidthJoiner
+	}
+	return clusters
+}
+
+const zeroWidthJoiner = '\u200D'
+
+func isGraphemeExtend(r rune) bool {
+	switch {
+	case r == zeroWidthJoiner:
+		return true
+	case unicode.In(r, unicode.Mn, unicode.Me, unicode.Mc):
+		return true
+	case r >= 0xFE00 && r <= 0xFE0F, r >= 0xE0100 && r <= 0xE01EF: // variation selectors
+		return true
+	case r >= 0x1F3FB && r <= 0x1F3FF: // emoji skin tone modifiers
+		return true
+	case r >= 0xE0020 && r <= 0xE007F: // tag characters (subdivision flags)
+		return true
+	}
+	return false
+}
+
+func isRegionalIndicator(r rune) bool {
+	return r >= 0x1F1E6 && r <= 0x1F1FF
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf16"
+)
+
+// vietnamese is the multilingual sample sent as UCS2 in main2.go.
+const vietnamese = "Đừng buồn thế dù ngoài kia vẫn mưa nghiễng rợi tý tỵ"
+
+func TestSplitMessage(t *testing.T) {
+	cases := []struct {
+		name string
+		text string
+		want MessageEncoding
+		// limit is the user data per concatenated segment: septets for
+		// GSM 7-bit, UTF-16 code units for UCS2 and octets for Latin-1.
+		limit int
+	}{
+		{"gsm escapes", strings.Repeat("Total: 5€ [paid] {ok} ", 20), EncodingGSM7, 153},
+		{"latin-1", strings.Repeat("Crème brûlée, façade à Zürich. ", 12), EncodingLatin1, 134},
+		{"vietnamese", strings.Repeat(vietnamese+" ", 4), EncodingUCS2, 67},
+		{"emoji", strings.Repeat(vietnamese+" 👍🏽 👨‍👩‍👧 🇬🇧 🏴󠁧󠁢󠁳󠁣󠁴󠁿 ", 3), EncodingUCS2, 67},
+	}
+	for _, tc := range cases {
+		// Shifting the text moves every segment boundary.
+		for shift := 0; shift < 4; shift++ {
+			text := strings.Repeat("x", shift) + tc.text
+			report, err := chooseEncoding(text, EncodingAuto, nil)
+			if err != nil {
+				t.Fatalf("%s: %v", tc.name, err)
+			}
+			if report.Encoding != tc.want {
+				t.Fatalf("%s: chose %s, want %s", tc.name, report.Encoding, tc.want)
+			}
+			for _, graphemes := range []bool{false, true} {
+				segments, err := splitMessage(text, report, graphemes)
+				if err != nil {
+					t.Fatalf("%s: %v", tc.name, err)
+				}
+				checkSegments(t, tc.name, text, report, graphemes, segments, tc.limit)
+			}
+		}
+	}
+}
+
+func checkSegments(t *testing.T, name, text string, report EncodingReport, graphemes bool, segments []MessageSegment, limit int) {
+	t.Helper()
+	if len(segments) < 2 {
+		t.Fatalf("%s: %d segment(s), want the text split", name, len(segments))
+	}
+	// Characters that cannot be split may end a segment early.
+	if len(segments) < report.Segments {
+		t.Errorf("%s: split into %d segments, the report predicts at least %d", name, len(segments), report.Segments)
+	}
+	var joined strings.Builder
+	boundaries := map[int]bool{}
+	for i, seg := range segments {
+		joined.WriteString(seg.Text)
+		boundaries[len([]rune(joined.String()))] = true
+
+		size := seg.Length
+		if report.Encoding == EncodingUCS2 {
+			size = len(seg.Data) / 2
+		}
+		if size > limit {
+			t.Errorf("%s: segment %d holds %d, the limit is %d", name, i, size, limit)
+		}
+		switch report.Encoding {
+		case EncodingGSM7:
+			if seg.Data[len(seg.Data)-1] == 0x1B {
+				t.Errorf("%s: segment %d ends with a GSM escape", name, i)
+			}
+		case EncodingUCS2:
+			units := make([]uint16, len(seg.Data)/2)
+			for j := range units {
+				units[j] = uint16(seg.Data[2*j])<<8 | uint16(seg.Data[2*j+1])
+			}
+			if first, last := units[0], units[len(units)-1]; utf16.IsSurrogate(rune(first)) && first >= 0xDC00 ||
+				utf16.IsSurrogate(rune(last)) && last < 0xDC00 {
+				t.Errorf("%s: segment %d splits a surrogate pair", name, i)
+			}
+		}
+	}
+	if joined.String() != text {
+		t.Fatalf("%s: segments join to %q, want %q", name, joined.String(), text)
+	}
+	if !graphemes {
+		return
+	}
+	offset := 0
+	for _, cl := range graphemeClusters(text) {
+		for j := 1; j < len(cl); j++ {
+			if boundaries[offset+j] {
+				t.Errorf("%s: a segment boundary splits the cluster %q", name, string(cl))
+			}
+		}
+		offset += len(cl)
+	}
+}
+
+func TestGraphemeClusters(t *testing.T) {
+	for text, want := range map[string][]string{
+		"👍🏽a":            {"👍🏽", "a"},
+		"👨‍👩‍👧!":         {"👨‍👩‍👧", "!"},
+		"🇬🇧🇫🇷":           {"🇬🇧", "🇫🇷"},
+		"e\u0301x":       {"e\u0301", "x"},
+		"o\u031B\u0323i": {"o\u031B\u0323", "i"},
+		"\r\n":           {"\r\n"},
+	} {
+		var got []string
+		for _, cl := range graphemeClusters(text) {
+			got = append(got, string(cl))
+		}
+		if strings.Join(got, "|") != strings.Join(want, "|") {
+			t.Errorf("graphemeClusters(%q) = %q, want %q", text, got, want)
+		}
+	}
+}
@@ -0,0 +1,190 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/linxGnu/gosmpp/pdu"
+)
+
+// ErrShuttingDown is returned by SendSMS once Shutdown has been called.
+var ErrShuttingDown = errors.New("client is shutting down")
+
+// tagReceiptedMessageID is the receipted_message_id TLV carried by delivery receipts.
+const tagReceiptedMessageID pdu.Tag = 0x001E
+
+// ShutdownOptions controls how much outstanding work Shutdown waits for.
+type ShutdownOptions struct {
+	// WaitReceipts also waits for delivery receipts of accepted submits
+	// that requested one on success or failure (registered_delivery bits
+	// 0-1 set to 1). Receipts requested on failure only are not awaited.
+	WaitReceipts bool
+}
+
+// ShutdownReport lists the work that was still outstanding when Shutdown finished.
+type ShutdownReport struct {
+	// Unanswered holds the sequence numbers of submits without a response.
+	Unanswered []int32
+	// PendingReceipts holds the message IDs still awaiting a delivery receipt.
+	PendingReceipts []string
+	// UnbindAcked reports whether the SMSC answered our unbind.
+	UnbindAcked bool
+}
+
+func (r ShutdownReport) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%d unanswered submit(s), %d pending receipt(s)", len(r.Unanswered), len(r.PendingReceipts))
+	if !r.UnbindAcked {
+		b.WriteString(", unbind not acknowledged")
+	}
+	return b.String()
+}
+
+// inflightTracker records submits awaiting a response and accepted
+// messages awaiting a delivery receipt.
+type inflightTracker struct {
+	mu       sync.Mutex
+	submits  map[int32]bool   // sequence number -> receipt requested
+	receipts map[string]int32 // message_id -> submit sequence number
+	changed  chan struct{}    // closed and replaced on every removal
+}
+
+func newInflightTracker() *inflightTracker {
+	return &inflightTracker{
+		submits:  make(map[int32]bool),
+		receipts: make(map[string]int32),
+		changed:  make(chan struct{}),
+	}
+}
+
+func (t *inflightTracker) add(seq int32, wantReceipt bool) {
+	t.mu.Lock()
+	t.submits[seq] = wantReceipt
+	t.mu.Unlock()
+}
+
+// respond records the response to a submit. Accepted submits that asked
+// for a receipt move on to wait for it.
+func (t *inflightTracker) respond(seq int32, messageID string, ok bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	wantReceipt, found := t.submits[seq]
+	if !found {
+		return
+	}
+	delete(t.submits, seq)
+	if ok && wantReceipt && messageID != "" {
+		t.receipts[messageID] = seq
+	}
+	t.notify()
+}
+
+func (t *inflightTracker) receipt(messageID string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if _, found := t.receipts[messageID]; found {
+		delete(t.receipts, messageID)
+		t.notify()
+	}
+}
+
+// notify wakes up waiters; t.mu must be held.
+func (t *inflightTracker) notify() {
+	close(t.changed)
+	t.changed = make(chan struct{})
+}
+
+// wait blocks until no submits (and, if receipts is set, no receipts) are outstanding.
+func (t *inflightTracker) wait(ctx context.Context, receipts bool) error {
+	for {
+		t.mu.Lock()
+		done := len(t.submits) == 0 && (!receipts || len(t.receipts) == 0)
+		changed := t.changed
+		t.mu.Unlock()
+		if done {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-changed:
+		}
+	}
+}
+
+func (t *inflightTracker) report() ShutdownReport {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	var r ShutdownReport
+	for seq := range t.submits {
+		r.Unanswered = append(r.Unanswered, seq)
+	}
+	for id := range t.receipts {
+		r.PendingReceipts = append(r.PendingReceipts, id)
+	}
+	sort.Slice(r.Unanswered, func(i, j int) bool { return r.Unanswered[i] < r.Unanswered[j] })
+	sort.Strings(r.PendingReceipts)
+	return r
+}
+
+// receiptMessageID extracts the receipted message id from a delivery receipt,
+// preferring the receipted_message_id TLV over the "id:" field of the text.
+func receiptMessageID(d *pdu.DeliverSM) (string, bool) {
+	if d.EsmClass&0x3C != 0x04 {
+		return "", false
+	}
+	if f, ok := d.OptionalParameters[tagReceiptedMessageID]; ok {
+		return strings.TrimRight(string(f.Data), "\x00"), true
+	}
+	text, err := d.Message.GetMessage()
+	if err != nil {
+		return "", false
+	}
+	for _, field := range strings.Fields(text) {
+		if strings.HasPrefix(strings.ToLower(field), "id:") {
+			return field[3:], true
+		}
+	}
+	return "", false
+}
+
+// Shutdown stops accepting new submits, waits for outstanding responses (and
+// optionally delivery receipts), unbinds and closes the session. If ctx expires
+// first the session is closed anyway and the undelivered work is reported.
+func (c *Client) Shutdown(ctx context.Context, opts ShutdownOptions) (ShutdownReport, error) {
+	c.draining.Store(true)
+
+	waitErr := c.inflight.wait(ctx, opts.WaitReceipts)
+
+	report := c.inflight.report()
+	if c.session != nil && c.State().IsBound() && ctx.Err() == nil {
+		c.state.set(StateUnbinding, nil)
+		if err := c.session.Transceiver().Submit(pdu.NewUnbind()); err == nil {
+			select {
+			case <-c.unbindResp:
+				report.UnbindAcked = true
+			case <-ctx.Done():
+			case <-time.After(c.cfg.ReadTimeout):
+			}
+		}
+	}
+
+	closeErr := c.Close()
+	if report.UnbindAcked && errors.Is(closeErr, net.ErrClosed) {
+		// The SMSC closed the connection after answering the unbind.
+		closeErr = nil
+	}
+	if waitErr != nil {
+		return report, fmt.Errorf("shutdown deadline reached with %s: %w", report, waitErr)
+	}
+	return report, closeErr
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// smppTimeLen is the length of an absolute or relative SMPP time string.
+const smppTimeLen = 16
+
+// FormatSMPPTime formats t as an absolute SMPP time (YYMMDDhhmmsstnnp),
+// keeping t's UTC offset rounded to quarter hours.
+func FormatSMPPTime(t time.Time) string {
+	_, offset := t.Zone()
+	if offset > 48*900 || offset < -48*900 {
+		// SMPP offsets stop at 12 hours; zones beyond are sent in UTC.
+		return FormatSMPPTime(t.UTC())
+	}
+	sign := byte('+')
+	if offset < 0 {
+		sign = '-'
+		offset = -offset
+	}
+	return fmt.Sprintf("%s%d%02d%c", t.Format("060102150405"), t.Nanosecond()/int(100*time.Millisecond), offset/900, sign)
+}
+
+// FormatSMPPRelative formats d as a relative SMPP time (YYMMDDhhmmss000R).
+// Receivers resolve the year and month fields as calendar years and months,
+// which no fixed duration matches, so d is limited to what the day, hour,
+// minute and second fields hold: just under 100 days.
+func FormatSMPPRelative(d time.Duration) (string, error) {
+	if d < time.Second {
+		return "", fmt.Errorf("relative time %s must be at least one second", d)
+	}
+	if d >= maxSMPPRelative {
+		return "", fmt.Errorf("relative time %s exceeds 99 days; use an absolute time", d)
+	}
+	secs := int64(d / time.Second)
+	days := secs / 86400
+	secs %= 86400
+	return fmt.Sprintf("0000%02d%02d%02d%02d000R", days, secs/3600, secs%3600/60, secs%60), nil
+}
+
+// maxSMPPRelative bounds the durations FormatSMPPRelative accepts.
+const maxSMPPRelative = 100 * 24 * time.Hour
+
+// ParseSMPPTime parses an absolute or relative SMPP time. Relative times
+// are resolved against now. An empty string returns the zero time.
+func ParseSMPPTime(s string, now time.Time) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	if len(s) != smppTimeLen {
+		return time.Time{}, fmt.Errorf("SMPP time %q must be %d characters", s, smppTimeLen)
+	}
+	for i := 0; i < 13; i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return time.Time{}, fmt.Errorf("SMPP time %q: non-digit at position %d", s, i+1)
+		}
+	}
+	num := func(i int) int {
+		v, _ := strconv.Atoi(s[i : i+2])
+		return v
+	}
+
+	switch s[15] {
+	case 'R':
+		if s[12:15] != "000" {
+			return time.Time{}, fmt.Errorf("relative SMPP time %q must have 000 before R", s)
+		}
+		return now.AddDate(num(0), num(2), num(4)).
+			Add(time.Duration(num(6))*time.Hour + time.Duration(num(8))*time.Minute + time.Duration(num(10))*time.Second), nil
+	case '+', '-':
+		if s[13] < '0' || s[13] > '9' || s[14] < '0' || s[14] > '9' {
+			return time.Time{}, fmt.Errorf("SMPP time %q: invalid UTC offset", s)
+		}
+		quarters := num(13)
+		if quarters > 48 {
+			return time.Time{}, fmt.Errorf("SMPP time %q: UTC offset of %d quarter hours exceeds 48", s, quarters)
+		}
+		offset := quarters * 900
+		if s[15] == '-' {
+			offset = -offset
+		}
+		loc := time.FixedZone("", offset)
+		month, day, hour, min, sec := num(2), num(4), num(6), num(8), num(10)
+		if month < 1 || month > 12 || day < 1 || day > 31 || hour > 23 || min > 59 || sec > 59 {
+			return time.Time{}, fmt.Errorf("SMPP time %q: date or time out of range", s)
+		}
+		t := time.Date(2000+num(0), time.Month(month), day, hour, min, sec, int(s[12]-'0')*int(100*time.Millisecond), loc)
+		if t.Day() != day {
+			return time.Time{}, fmt.Errorf("SMPP time %q: invalid day of month", s)
+		}
+		return t, nil
+	}
+	return time.Time{}, fmt.Errorf("SMPP time %q must end in R, + or -", s)
+}
+
+// normalizeSMPPTime accepts an SMPP time string, an RFC 3339 timestamp or a
+// Go duration such as "90m" (relative) and returns the SMPP form.
+func normalizeSMPPTime(s string) (string, error) {
+	if s == "" {
+		return "", nil
+	}
+	if len(s) == smppTimeLen {
+		if _, err := ParseSMPPTime(s, time.Now()); err == nil {
+			return s, nil
+		}
+	}
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return FormatSMPPTime(t), nil
+	}
+	if d, err := time.ParseDuration(s); err == nil {
+		return FormatSMPPRelative(d)
+	}
+	_, err := ParseSMPPTime(s, time.Now())
+	return "", err
+}
+
+// checkDeliveryWindow validates schedule and validity strings and that the
+// message does not expire before it is scheduled.
+func checkDeliveryWindow(schedule, validity string, now time.Time) error {
+	at, err := ParseSMPPTime(schedule, now)
+	if err != nil {
+		return fmt.Errorf("schedule_delivery_time: %w", err)
+	}
+	until, err := ParseSMPPTime(validity, now)
+	if err != nil {
+		return fmt.Errorf("validity_period: %w", err)
+	}
+	if !until.IsZero() && until.Before(now) {
+		return fmt.Errorf("validity_period %s is in the past", validity)
+	}
+	if !at.IsZero() && !until.IsZero() && until.Before(at) {
+		return fmt.Errorf("validity_period %s ends before schedule_delivery_time %s", validity, schedule)
+	}
+	return nil
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFormatSMPPRelativeRoundTrip(t *testing.T) {
+	now := time.Date(2024, time.January, 31, 12, 0, 0, 0, time.UTC)
+	for _, d := range []time.Duration{
+		time.Second,
+		90 * time.Minute,
+		24 * time.Hour,
+		30 * 24 * time.Hour,
+		99*24*time.Hour + 23*time.Hour + 59*time.Minute + 59*time.Second,
+	} {
+		s, err := FormatSMPPRelative(d)
+		if err != nil {
+			t.Errorf("FormatSMPPRelative(%s): %v", d, err)
+			continue
+		}
+		got, err := ParseSMPPTime(s, now)
+		if err != nil {
+			t.Errorf("ParseSMPPTime(%q): %v", s, err)
+			continue
+		}
+		if got.Sub(now) != d {
+			t.Errorf("%s formatted as %q parses back as %s", d, s, got.Sub(now))
+		}
+	}
+}
+
+func TestFormatSMPPRelativeRejects(t *testing.T) {
+	for _, d := range []time.Duration{0, 500 * time.Millisecond, 100 * 24 * time.Hour, 400 * 24 * time.Hour} {
+		if s, err := FormatSMPPRelative(d); err == nil {
+			t.Errorf("FormatSMPPRelative(%s) = %q, want an error", d, s)
+		}
+	}
+}
+
+func TestFormatSMPPTimeRoundTrip(t *testing.T) {
+	for _, tc := range []struct {
+		t    time.Time
+		want string
+	}{
+		{time.Date(2024, time.March, 5, 14, 30, 15, 700*int(time.Millisecond), time.UTC), "240305143015700+"},
+		{time.Date(2024, time.March, 5, 14, 30, 15, 0, time.FixedZone("", 5*3600+45*60)), "240305143015023+"},
+		{time.Date(2024, time.March, 5, 14, 30, 15, 0, time.FixedZone("", -4*3600)), "240305143015016-"},
+		// Offsets beyond 12 hours are sent in UTC.
+		{time.Date(2024, time.March, 5, 14, 0, 0, 0, time.FixedZone("", 14*3600)), "240305000000000+"},
+	} {
+		s := FormatSMPPTime(tc.t)
+		if s != tc.want {
+			t.Errorf("FormatSMPPTime(%s) = %q, want %q", tc.t, s, tc.want)
+		}
+		got, err := ParseSMPPTime(s, time.Now())
+		if err != nil {
+			t.Errorf("ParseSMPPTime(%q): %v", s, err)
+			continue
+		}
+		if !got.Equal(tc.t) {
+			t.Errorf("%q parses as %s, want %s", s, got, tc.t)
+		}
+	}
+}
+
+func TestParseSMPPTimeInvalid(t *testing.T) {
+	for _, s := range []string{
+		"2403051430157",
+		"24030514301570+",
+		"240305143015700X",
+		"240230143015700+", // 30 February
+		"241305143015700+",
+		"240305143015049+",
+		"000000000100100R",
+		"24a305143015700+",
+	} {
+		if _, err := ParseSMPPTime(s, time.Now()); err == nil {
+			t.Errorf("ParseSMPPTime(%q) succeeded, want an error", s)
+		}
+	}
+}
+
+func TestNormalizeSMPPTime(t *testing.T) {
+	for in, want := range map[string]string{
+		"":                     "",
+		"90m":                  "000000013000000R",
+		"240305143015700+":     "240305143015700+",
+		"2024-03-05T14:30:15Z": "240305143015000+",
+	} {
+		got, err := normalizeSMPPTime(in)
+		if err != nil || got != want {
+			t.Errorf("normalizeSMPPTime(%q) = %q, %v; want %q", in, got, err, want)
+		}
+	}
+	if _, err := normalizeSMPPTime("2400h"); err == nil {
+		t.Error("normalizeSMPPTime(2400h) succeeded, want an error for 100 days")
+	}
+}
@@ -0,0 +1,178 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/linxGnu/gosmpp/pdu"
+)
+
+// ConnState is the lifecycle state of a Client's SMPP session.
+type ConnState int
+
+const (
+	StateDisconnected ConnState = iota
+	StateConnecting
+	StateBoundTX
+	StateBoundRX
+	StateBoundTRX
+	StateUnbinding
+	StateClosed
+)
+
+func (s ConnState) String() string {
+	switch s {
+	case StateDisconnected:
+		return "Disconnected"
+	case StateConnecting:
+		return "Connecting"
+	case StateBoundTX:
+		return "Bound_TX"
+	case StateBoundRX:
+		return "Bound_RX"
+	case StateBoundTRX:
+		return "Bound_TRX"
+	case StateUnbinding:
+		return "Unbinding"
+	case StateClosed:
+		return "Closed"
+	}
+	return "Unknown"
+}
+
+// IsBound reports whether the session can exchange PDUs in this state.
+func (s ConnState) IsBound() bool {
+	return s == StateBoundTX || s == StateBoundRX || s == StateBoundTRX
+}
+
+// boundState maps a gosmpp binding type to its bound state.
+func boundState(t pdu.BindingType) ConnState {
+	switch t {
+	case pdu.Transmitter:
+		return StateBoundTX
+	case pdu.Receiver:
+		return StateBoundRX
+	}
+	return StateBoundTRX
+}
+
+// StateChange describes a single state transition.
+type StateChange struct {
+	From ConnState
+	To   ConnState
+	At   time.Time
+	Err  error // cause of the transition, if any
+}
+
+// SessionStats holds lifecycle counters for a Client.
+type SessionStats struct {
+	Binds        int
+	Rebinds      int
+	BindFailures int
+	LastBound    time.Time
+}
+
+// ErrSessionClosed is returned by WaitBound once the client has been closed.
+var ErrSessionClosed = errors.New("session closed")
+
+// stateMachine tracks the current ConnState and notifies subscribers of transitions.
+type stateMachine struct {
+	mu          sync.Mutex
+	state       ConnState
+	changed     chan struct{} // closed and replaced on every transition
+	subscribers map[int]func(StateChange)
+	nextID      int
+	stats       SessionStats
+}
+
+func newStateMachine() *stateMachine {
+	return &stateMachine{
+		state:       StateDisconnected,
+		changed:     make(chan struct{}),
+		subscribers: make(map[int]func(StateChange)),
+	}
+}
+
+// set moves to state to and notifies subscribers. Transitions out of
+// StateClosed are ignored, as are transitions to the current state.
+func (m *stateMachine) set(to ConnState, cause error) {
+	m.mu.Lock()
+	from := m.state
+	if from == to || from == StateClosed {
+		m.mu.Unlock()
+		return
+	}
+	m.state = to
+	if to.IsBound() {
+		m.stats.Binds++
+		if m.stats.Binds > 1 {
+			m.stats.Rebinds++
+		}
+		m.stats.LastBound = time.Now()
+	}
+	close(m.changed)
+	m.changed = make(chan struct{})
+	subs := make([]func(StateChange), 0, len(m.subscribers))
+	for id := 0; id < m.nextID; id++ {
+		if fn, ok := m.subscribers[id]; ok {
+			subs = append(subs, fn)
+		}
+	}
+	m.mu.Unlock()
+
+	change := StateChange{From: from, To: to, At: time.Now(), Err: cause}
+	for _, fn := range subs {
+		fn(change)
+	}
+}
+
+func (m *stateMachine) bindFailed() {
+	m.mu.Lock()
+	m.stats.BindFailures++
+	m.mu.Unlock()
+}
+
+func (m *stateMachine) current() (ConnState, <-chan struct{}) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.state, m.changed
+}
+
+func (m *stateMachine) subscribe(fn func(StateChange)) func() {
+	m.mu.Lock()
+	id := m.nextID
+	m.nextID++
+	m.subscribers[id] = fn
+	m.mu.Unlock()
+
+	return func() {
+		m.mu.Lock()
+		delete(m.subscribers, id)
+		m.mu.Unlock()
+	}
+}
+
+func (m *stateMachine) waitBound(ctx context.Context) error {
+	for {
+		state, changed := m.current()
+		if state.IsBound() {
+			return nil
+		}
+		if state == StateClosed {
+			return ErrSessionClosed
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-changed:
+		}
+	}
+}
+
+func (m *stateMachine) snapshot() SessionStats {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.stats
+}
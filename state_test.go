@@ -0,0 +1,130 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestStateMachineTransitions(t *testing.T) {
+	m := newStateMachine()
+	var changes []StateChange
+	unsubscribe := m.subscribe(func(c StateChange) { changes = append(changes, c) })
+
+	lost := errors.New("connection closed")
+	m.set(StateConnecting, nil)
+	m.set(StateBoundTRX, nil)
+	m.set(StateBoundTRX, nil) // no transition
+	m.set(StateDisconnected, lost)
+	m.bindFailed()
+	m.set(StateConnecting, nil)
+	m.set(StateBoundTRX, nil)
+	unsubscribe()
+	m.set(StateClosed, nil)
+	m.set(StateConnecting, nil) // closed is final
+
+	want := []StateChange{
+		{From: StateDisconnected, To: StateConnecting},
+		{From: StateConnecting, To: StateBoundTRX},
+		{From: StateBoundTRX, To: StateDisconnected, Err: lost},
+		{From: StateDisconnected, To: StateConnecting},
+		{From: StateConnecting, To: StateBoundTRX},
+	}
+	if len(changes) != len(want) {
+		t.Fatalf("subscriber saw %d transitions, want %d: %v", len(changes), len(want), changes)
+	}
+	for i, w := range want {
+		if c := changes[i]; c.From != w.From || c.To != w.To || c.Err != w.Err || c.At.IsZero() {
+			t.Errorf("transition %d = %v -> %v (%v), want %v -> %v (%v)", i, c.From, c.To, c.Err, w.From, w.To, w.Err)
+		}
+	}
+	if state, _ := m.current(); state != StateClosed {
+		t.Errorf("state after close = %v", state)
+	}
+	stats := m.snapshot()
+	if stats.Binds != 2 || stats.Rebinds != 1 || stats.BindFailures != 1 || stats.LastBound.IsZero() {
+		t.Errorf("stats = %+v", stats)
+	}
+}
+
+func TestStateMachineWaitBound(t *testing.T) {
+	m := newStateMachine()
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := m.waitBound(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("waitBound while disconnected = %v, want the context's error", err)
+	}
+
+	done := make(chan error, 1)
+	go func() { done <- m.waitBound(context.Background()) }()
+	m.set(StateConnecting, nil)
+	m.set(StateBoundRX, nil)
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("waitBound = %v once bound", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("waitBound did not return once bound")
+	}
+
+	go func() { done <- m.waitBound(context.Background()) }()
+	m.set(StateDisconnected, nil)
+	m.set(StateClosed, nil)
+	select {
+	case err := <-done:
+		if !errors.Is(err, ErrSessionClosed) {
+			t.Errorf("waitBound = %v once closed, want ErrSessionClosed", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("waitBound did not return once closed")
+	}
+}
+
+func TestClientStateLifecycle(t *testing.T) {
+	m := newMockSMSC(t)
+	client := NewClient(m.config(t))
+	var mu sync.Mutex
+	var seen []ConnState
+	client.Subscribe(func(c StateChange) {
+		mu.Lock()
+		seen = append(seen, c.To)
+		mu.Unlock()
+	})
+	if err := client.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := client.WaitBound(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	// A dropped connection is rebound.
+	m.drop()
+	waitFor(t, "the rebind", func() bool { return m.bindCount() == 2 && client.State().IsBound() })
+	if stats := client.Stats(); stats.Binds != 2 || stats.Rebinds != 1 {
+		t.Errorf("stats after a rebind = %+v", stats)
+	}
+
+	if err := client.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.WaitBound(ctx); !errors.Is(err, ErrSessionClosed) {
+		t.Errorf("WaitBound after Close = %v, want ErrSessionClosed", err)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	// Close may pass through other states before StateClosed.
+	want := []ConnState{StateConnecting, StateBoundTRX, StateDisconnected, StateConnecting, StateBoundTRX}
+	if len(seen) <= len(want) || seen[len(seen)-1] != StateClosed {
+		t.Fatalf("transitions to %v, want %v and then to Closed", seen, want)
+	}
+	for i := range want {
+		if seen[i] != want[i] {
+			t.Fatalf("transitions to %v, want %v and then to Closed", seen, want)
+		}
+	}
+}
@@ -0,0 +1,175 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// CommandStatus is an SMPP command_status value.
+type CommandStatus uint32
+
+// Command status codes from SMPP v3.4 section 5.1.3 and SMPP v5.0 section 4.7.6.
+const (
+	ESME_ROK              CommandStatus = 0x00000000
+	ESME_RINVMSGLEN       CommandStatus = 0x00000001
+	ESME_RINVCMDLEN       CommandStatus = 0x00000002
+	ESME_RINVCMDID        CommandStatus = 0x00000003
+	ESME_RINVBNDSTS       CommandStatus = 0x00000004
+	ESME_RALYBND          CommandStatus = 0x00000005
+	ESME_RINVPRTFLG       CommandStatus = 0x00000006
+	ESME_RINVREGDLVFLG    CommandStatus = 0x00000007
+	ESME_RSYSERR          CommandStatus = 0x00000008
+	ESME_RINVSRCADR       CommandStatus = 0x0000000A
+	ESME_RINVDSTADR       CommandStatus = 0x0000000B
+	ESME_RINVMSGID        CommandStatus = 0x0000000C
+	ESME_RBINDFAIL        CommandStatus = 0x0000000D
+	ESME_RINVPASWD        CommandStatus = 0x0000000E
+	ESME_RINVSYSID        CommandStatus = 0x0000000F
+	ESME_RCANCELFAIL      CommandStatus = 0x00000011
+	ESME_RREPLACEFAIL     CommandStatus = 0x00000013
+	ESME_RMSGQFUL         CommandStatus = 0x00000014
+	ESME_RINVSERTYP       CommandStatus = 0x00000015
+	ESME_RINVNUMDESTS     CommandStatus = 0x00000033
+	ESME_RINVDLNAME       CommandStatus = 0x00000034
+	ESME_RINVDESTFLAG     CommandStatus = 0x00000040
+	ESME_RINVSUBREP       CommandStatus = 0x00000042
+	ESME_RINVESMCLASS     CommandStatus = 0x00000043
+	ESME_RCNTSUBDL        CommandStatus = 0x00000044
+	ESME_RSUBMITFAIL      CommandStatus = 0x00000045
+	ESME_RINVSRCTON       CommandStatus = 0x00000048
+	ESME_RINVSRCNPI       CommandStatus = 0x00000049
+	ESME_RINVDSTTON       CommandStatus = 0x00000050
+	ESME_RINVDSTNPI       CommandStatus = 0x00000051
+	ESME_RINVSYSTYP       CommandStatus = 0x00000053
+	ESME_RINVREPFLAG      CommandStatus = 0x00000054
+	ESME_RINVNUMMSGS      CommandStatus = 0x00000055
+	ESME_RTHROTTLED       CommandStatus = 0x00000058
+	ESME_RINVSCHED        CommandStatus = 0x00000061
+	ESME_RINVEXPIRY       CommandStatus = 0x00000062
+	ESME_RINVDFTMSGID     CommandStatus = 0x00000063
+	ESME_RX_T_APPN        CommandStatus = 0x00000064
+	ESME_RX_P_APPN        CommandStatus = 0x00000065
+	ESME_RX_R_APPN        CommandStatus = 0x00000066
+	ESME_RQUERYFAIL       CommandStatus = 0x00000067
+	ESME_RINVOPTPARSTREAM CommandStatus = 0x000000C0
+	ESME_ROPTPARNOTALLWD  CommandStatus = 0x000000C1
+	ESME_RINVPARLEN       CommandStatus = 0x000000C2
+	ESME_RMISSINGOPTPARAM CommandStatus = 0x000000C3
+	ESME_RINVOPTPARAMVAL  CommandStatus = 0x000000C4
+	ESME_RDELIVERYFAILURE CommandStatus = 0x000000FE
+	ESME_RUNKNOWNERR      CommandStatus = 0x000000FF
+
+	// SMPP v5.0 additions.
+	ESME_RSERTYPUNAUTH       CommandStatus = 0x00000100
+	ESME_RPROHIBITED         CommandStatus = 0x00000101
+	ESME_RSERTYPUNAVAIL      CommandStatus = 0x00000102
+	ESME_RSERTYPDENIED       CommandStatus = 0x00000103
+	ESME_RINVDCS             CommandStatus = 0x00000104
+	ESME_RINVSRCADDRSUBUNIT  CommandStatus = 0x00000105
+	ESME_RINVDSTADDRSUBUNIT  CommandStatus = 0x00000106
+	ESME_RINVBCASTFREQINT    CommandStatus = 0x00000107
+	ESME_RINVBCASTALIAS_NAME CommandStatus = 0x00000108
+	ESME_RINVBCASTAREAFMT    CommandStatus = 0x00000109
+	ESME_RINVNUMBCAST_AREAS  CommandStatus = 0x0000010A
+	ESME_RINVBCASTCNTTYPE    CommandStatus = 0x0000010B
+	ESME_RINVBCASTMSGCLASS   CommandStatus = 0x0000010C
+	ESME_RBCASTFAIL          CommandStatus = 0x0000010D
+	ESME_RBCASTQUERYFAIL     CommandStatus = 0x0000010E
+	ESME_RBCASTCANCELFAIL    CommandStatus = 0x0000010F
+	ESME_RINVBCAST_REP       CommandStatus = 0x00000110
+	ESME_RINVBCASTSRVGRP     CommandStatus = 0x00000111
+	ESME_RINVBCASTCHANIND    CommandStatus = 0x00000112
+)
+
+var commandStatusNames = map[CommandStatus]string{
+	ESME_ROK:                 "ESME_ROK",
+	ESME_RINVMSGLEN:          "ESME_RINVMSGLEN",
+	ESME_RINVCMDLEN:          "ESME_RINVCMDLEN",
+	ESME_RINVCMDID:           "ESME_RINVCMDID",
+	ESME_RINVBNDSTS:          "ESME_RINVBNDSTS",
+	ESME_RALYBND:             "ESME_RALYBND",
+	ESME_RINVPRTFLG:          "ESME_RINVPRTFLG",
+	ESME_RINVREGDLVFLG:       "ESME_RINVREGDLVFLG",
+	ESME_RSYSERR:             "ESME_RSYSERR",
+	ESME_RINVSRCADR:          "ESME_RINVSRCADR",
+	ESME_RINVDSTADR:          "ESME_RINVDSTADR",
+	ESME_RINVMSGID:           "ESME_RINVMSGID",
+	ESME_RBINDFAIL:           "ESME_RBINDFAIL",
+	ESME_RINVPASWD:           "ESME_RINVPASWD",
+	ESME_RINVSYSID:           "ESME_RINVSYSID",
+	ESME_RCANCELFAIL:         "ESME_RCANCELFAIL",
+	ESME_RREPLACEFAIL:        "ESME_RREPLACEFAIL",
+	ESME_RMSGQFUL:            "ESME_RMSGQFUL",
+	ESME_RINVSERTYP:          "ESME_RINVSERTYP",
+	ESME_RINVNUMDESTS:        "ESME_RINVNUMDESTS",
+	ESME_RINVDLNAME:          "ESME_RINVDLNAME",
+	ESME_RINVDESTFLAG:        "ESME_RINVDESTFLAG",
+	ESME_RINVSUBREP:          "ESME_RINVSUBREP",
+	ESME_RINVESMCLASS:        "ESME_RINVESMCLASS",
+	ESME_RCNTSUBDL:           "ESME_RCNTSUBDL",
+	ESME_RSUBMITFAIL:         "ESME_RSUBMITFAIL",
+	ESME_RINVSRCTON:          "ESME_RINVSRCTON",
+	ESME_RINVSRCNPI:          "ESME_RINVSRCNPI",
+	ESME_RINVDSTTON:          "ESME_RINVDSTTON",
+	ESME_RINVDSTNPI:          "ESME_RINVDSTNPI",
+	ESME_RINVSYSTYP:          "ESME_RINVSYSTYP",
+	ESME_RINVREPFLAG:         "ESME_RINVREPFLAG",
+	ESME_RINVNUMMSGS:         "ESME_RINVNUMMSGS",
+	ESME_RTHROTTLED:          "ESME_RTHROTTLED",
+	ESME_RINVSCHED:           "ESME_RINVSCHED",
+	ESME_RINVEXPIRY:          "ESME_RINVEXPIRY",
+	ESME_RINVDFTMSGID:        "ESME_RINVDFTMSGID",
+	ESME_RX_T_APPN:           "ESME_RX_T_APPN",
+	ESME_RX_P_APPN:           "ESME_RX_P_APPN",
+	ESME_RX_R_APPN:           "ESME_RX_R_APPN",
+	ESME_RQUERYFAIL:          "ESME_RQUERYFAIL",
+	ESME_RINVOPTPARSTREAM:    "ESME_RINVOPTPARSTREAM",
+	ESME_ROPTPARNOTALLWD:     "ESME_ROPTPARNOTALLWD",
+	ESME_RINVPARLEN:          "ESME_RINVPARLEN",
+	ESME_RMISSINGOPTPARAM:    "ESME_RMISSINGOPTPARAM",
+	ESME_RINVOPTPARAMVAL:     "ESME_RINVOPTPARAMVAL",
+	ESME_RDELIVERYFAILURE:    "ESME_RDELIVERYFAILURE",
+	ESME_RUNKNOWNERR:         "ESME_RUNKNOWNERR",
+	ESME_RSERTYPUNAUTH:       "ESME_RSERTYPUNAUTH",
+	ESME_RPROHIBITED:         "ESME_RPROHIBITED",
+	ESME_RSERTYPUNAVAIL:      "ESME_RSERTYPUNAVAIL",
+	ESME_RSERTYPDENIED:       "ESME_RSERTYPDENIED",
+	ESME_RINVDCS:             "ESME_RINVDCS",
+	ESME_RINVSRCADDRSUBUNIT:  "ESME_RINVSRCADDRSUBUNIT",
+	ESME_RINVDSTADDRSUBUNIT:  "ESME_RINVDSTADDRSUBUNIT",
+	ESME_RINVBCASTFREQINT:    "ESME_RINVBCASTFREQINT",
+	ESME_RINVBCASTALIAS_NAME: "ESME_RINVBCASTALIAS_NAME",
+	ESME_RINVBCASTAREAFMT:    "ESME_RINVBCASTAREAFMT",
+	ESME_RINVNUMBCAST_AREAS:  "ESME_RINVNUMBCAST_AREAS",
+	ESME_RINVBCASTCNTTYPE:    "ESME_RINVBCASTCNTTYPE",
+	ESME_RINVBCASTMSGCLASS:   "ESME_RINVBCASTMSGCLASS",
+	ESME_RBCASTFAIL:          "ESME_RBCASTFAIL",
+	ESME_RBCASTQUERYFAIL:     "ESME_RBCASTQUERYFAIL",
+	ESME_RBCASTCANCELFAIL:    "ESME_RBCASTCANCELFAIL",
+	ESME_RINVBCAST_REP:       "ESME_RINVBCAST_REP",
+	ESME_RINVBCASTSRVGRP:     "ESME_RINVBCASTSRVGRP",
+	ESME_RINVBCASTCHANIND:    "ESME_RINVBCASTCHANIND",
+}
+
+func (s CommandStatus) String() string {
+	if name, ok := commandStatusNames[s]; ok {
+		return name
+	}
+	return fmt.Sprintf("0x%08X", uint32(s))
+}
+
+// parseCommandStatus accepts either an ESME_* name or a decimal/hex number.
+func parseCommandStatus(s string) (CommandStatus, error) {
+	s = strings.TrimSpace(s)
+	for status, name := range commandStatusNames {
+		if strings.EqualFold(name, s) {
+			return status, nil
+		}
+	}
+	v, err := strconv.ParseUint(s, 0, 32)
+	if err != nil {
+		return 0, fmt.Errorf("unknown command_status %q", s)
+	}
+	return CommandStatus(v), nil
+}
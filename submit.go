@@ -1,31 +1,366 @@
 package main
 
 import (
+	"fmt"
+	"strings"
+	"time"
+
 	"github.com/linxGnu/gosmpp/data"
 	"github.com/linxGnu/gosmpp/pdu"
 )
 
+// MessageEncoding selects the alphabet used for the short_message.
+type MessageEncoding int
+
+const (
+	// EncodingAuto picks the cheapest encoding able to represent the text.
+	EncodingAuto MessageEncoding = iota
+	EncodingGSM7
+	EncodingLatin1
+	EncodingUCS2
+)
+
+func (e MessageEncoding) String() string {
+	switch e {
+	case EncodingAuto:
+		return "auto"
+	case EncodingGSM7:
+		return "GSM 7-bit"
+	case EncodingLatin1:
+		return "Latin-1"
+	case EncodingUCS2:
+		return "UCS2"
+	}
+	return "unknown"
+}
+
+// parseMessageEncoding accepts auto, gsm7, latin1 or ucs2.
+func parseMessageEncoding(s string) (MessageEncoding, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "", "auto":
+		return EncodingAuto, nil
+	case "gsm7", "gsm":
+		return EncodingGSM7, nil
+	case "latin1":
+		return EncodingLatin1, nil
+	case "ucs2":
+		return EncodingUCS2, nil
+	}
+	return EncodingAuto, fmt.Errorf("unknown encoding %q (want auto, gsm7, latin1 or ucs2)", s)
+}
+
+// dataEncoding returns the gosmpp encoding for e.
+func (e MessageEncoding) dataEncoding() data.Encoding {
+	switch e {
+	case EncodingGSM7:
+		return data.GSM7BIT
+	case EncodingLatin1:
+		return data.LATIN1
+	}
+	return data.UCS2
+}
+
+// SubmitOptions controls how NewSubmitSM builds the PDU.
+type SubmitOptions struct {
+	// Encoding forces an alphabet; the zero value selects one automatically.
+	Encoding MessageEncoding
+	// Languages lists the national language shift tables that may be used
+	// for GSM 7-bit. They are signalled to the handset via UDH IEIs 0x24/0x25.
+	Languages []GSMLanguage
+	// Transliterate replaces characters outside the GSM alphabet using
+	// TranslitTable (the default table when nil) so the message can stay in
+	// GSM 7-bit. It has no effect when another encoding is forced.
+	Transliterate bool
+	TranslitTable TranslitTable
+	// Graphemes keeps user-perceived characters (emoji sequences, combining
+	// marks) in one segment when NewSubmitSMParts splits a long message.
+	Graphemes bool
+	// Flash sends the message as class 0 (displayed immediately, not
+	// stored). It requires GSM 7-bit or UCS2.
+	Flash bool
+	// ScheduleAt/ScheduleAfter set schedule_delivery_time as an absolute or
+	// relative time; ValidUntil/ValidFor do the same for validity_period.
+	ScheduleAt    time.Time
+	ScheduleAfter time.Duration
+	ValidUntil    time.Time
+	ValidFor      time.Duration
+	// DefaultCountry (ISO code or calling code) normalizes national numbers
+	// to E.164; when empty, numbers are sent as written.
+	DefaultCountry string
+	// Packing is how GSM 7-bit septets are carried in short_message.
+	Packing GSMPacking
+}
+
+// deliveryTimes returns schedule_delivery_time and validity_period for opts.
+func (opts SubmitOptions) deliveryTimes() (schedule, validity string, err error) {
+	format := func(at time.Time, after time.Duration, field string) (string, error) {
+		switch {
+		case !at.IsZero() && after != 0:
+			return "", fmt.Errorf("%s: set either an absolute or a relative time", field)
+		case !at.IsZero():
+			return FormatSMPPTime(at), nil
+		case after != 0:
+			return FormatSMPPRelative(after)
+		}
+		return "", nil
+	}
+	if schedule, err = format(opts.ScheduleAt, opts.ScheduleAfter, "schedule_delivery_time"); err != nil {
+		return "", "", err
+	}
+	if validity, err = format(opts.ValidUntil, opts.ValidFor, "validity_period"); err != nil {
+		return "", "", err
+	}
+	return schedule, validity, checkDeliveryWindow(schedule, validity, time.Now())
+}
+
+// EncodingReport describes the encoding chosen for a message.
+type EncodingReport struct {
+	Encoding   MessageEncoding
+	DataCoding byte
+	// Length is in septets for GSM 7-bit and in octets otherwise.
+	Length   int
+	Segments int
+	// LockingShift and SingleShift are the GSM 7-bit national language tables in use.
+	LockingShift GSMLanguage
+	SingleShift  GSMLanguage
+	// Substitutions lists the characters replaced by transliteration.
+	Substitutions []Substitution
+}
+
+func (r EncodingReport) gsmTables() gsmTables {
+	return gsmTables{Locking: r.LockingShift, Single: r.SingleShift}
+}
+
+// chooseEncoding picks GSM 7-bit when every character is in the default or
+// extension table (or one of the allowed national language tables), Latin-1
+// when every character is below U+0100, and UCS2 otherwise. A forced
+// encoding is validated against the text instead.
+func chooseEncoding(text string, forced MessageEncoding, languages []GSMLanguage) (EncodingReport, error) {
+	tables, septets, gsmErr := selectGSMTables(text, languages)
+	latin1 := isLatin1(text)
+
+	enc := forced
+	if enc == EncodingAuto {
+		switch {
+		case gsmErr == nil:
+			enc = EncodingGSM7
+		case latin1:
+			enc = EncodingLatin1
+		default:
+			enc = EncodingUCS2
+		}
+	}
+
+	report := EncodingReport{Encoding: enc, DataCoding: enc.dataEncoding().DataCoding()}
+	switch enc {
+	case EncodingGSM7:
+		if gsmErr != nil {
+			return report, gsmErr
+		}
+		report.Length = septets
+		report.LockingShift, report.SingleShift = tables.Locking, tables.Single
+	case EncodingLatin1:
+		if !latin1 {
+			return report, fmt.Errorf("message is not representable in Latin-1")
+		}
+		report.Length = len([]rune(text))
+	case EncodingUCS2:
+		report.Length = ucs2ByteLength(text)
+	default:
+		return report, fmt.Errorf("unknown encoding %d", enc)
+	}
+
+	report.Segments = segmentCount(report)
+	return report, nil
+}
+
+// nationalLanguageUDH returns the UDH information elements selecting t.
+func nationalLanguageUDH(t gsmTables) pdu.UDH {
+	var udh pdu.UDH
+	if t.Locking != GSMDefault {
+		udh = append(udh, pdu.InfoElement{ID: ieiNationalLockingShift, Data: []byte{byte(t.Locking)}})
+	}
+	if t.Single != GSMDefault {
+		udh = append(udh, pdu.InfoElement{ID: ieiNationalSingleShift, Data: []byte{byte(t.Single)}})
+	}
+	return udh
+}
+
+func isLatin1(s string) bool {
+	for _, r := range s {
+		if r > 0xFF {
+			return false
+		}
+	}
+	return true
+}
+
+// transliterateForGSM applies table to message and keeps the result only when
+// it becomes fully GSM-encodable; otherwise the original text is returned so
+// the message goes out unchanged as UCS2.
+func transliterateForGSM(message string, table TranslitTable, languages []GSMLanguage) (string, []Substitution) {
+	out, subs := transliterate(message, table, languages)
+	if len(subs) == 0 {
+		return message, nil
+	}
+	if _, _, err := selectGSMTables(out, languages); err != nil {
+		return message, nil
+	}
+	return out, subs
+}
+
+// prepareMessage applies transliteration and chooses the encoding for
+// message, returning the gosmpp encoding that carries the final data_coding.
+func prepareMessage(message string, opts SubmitOptions) (string, EncodingReport, data.Encoding, error) {
+	var subs []Substitution
+	if opts.Transliterate && (opts.Encoding == EncodingAuto || opts.Encoding == EncodingGSM7) {
+		message, subs = transliterateForGSM(message, opts.TranslitTable, opts.Languages)
+	}
+	report, err := chooseEncoding(message, opts.Encoding, opts.Languages)
+	report.Substitutions = subs
+	if err != nil {
+		return message, report, nil, err
+	}
+	enc := report.Encoding.dataEncoding()
+	if opts.Flash {
+		alphabet := AlphabetGSM7
+		switch report.Encoding {
+		case EncodingUCS2:
+			alphabet = AlphabetUCS2
+		case EncodingLatin1:
+			return message, report, nil, fmt.Errorf("flash SMS cannot be sent in %s", report.Encoding)
+		}
+		dcs, err := FlashDCS(alphabet)
+		if err != nil {
+			return message, report, nil, err
+		}
+		if enc, err = dcs.Encoding(); err != nil {
+			return message, report, nil, err
+		}
+		report.DataCoding = dcs.Raw
+	}
+	return message, report, enc, nil
+}
+
 // NewSubmitSM constructs a SubmitSM PDU with basic fields.
-// It uses UCS2 encoding for the message like the original sample.
-func NewSubmitSM(src, dest, message string) *pdu.SubmitSM {
-	srcAddr := pdu.NewAddress()
-	srcAddr.SetTon(5)
-	srcAddr.SetNpi(0)
-	_ = srcAddr.SetAddress(src)
-
-	destAddr := pdu.NewAddress()
-	destAddr.SetTon(1)
-	destAddr.SetNpi(1)
-	_ = destAddr.SetAddress(dest)
+// The message is encoded as GSM 7-bit, Latin-1 or UCS2 depending on its
+// content unless opts forces an encoding; the choice is returned in the report.
+// GSM 7-bit text is encoded with the selected shift tables as unpacked or
+// packed septets according to opts.Packing, with the UDH in the user data.
+func NewSubmitSM(src, dest, message string, opts SubmitOptions) (*pdu.SubmitSM, EncodingReport, error) {
+	message, report, enc, err := prepareMessage(message, opts)
+	if err != nil {
+		return nil, report, err
+	}
+
+	schedule, validity, err := opts.deliveryTimes()
+	if err != nil {
+		return nil, report, err
+	}
+	submit, err := newSubmitBase(src, dest, opts.DefaultCountry)
+	if err != nil {
+		return nil, report, err
+	}
+	submit.ScheduleDeliveryTime, submit.ValidityPeriod = schedule, validity
+
+	if report.Encoding == EncodingGSM7 {
+		septets, err := report.gsmTables().encode(message)
+		if err != nil {
+			return nil, report, err
+		}
+		udh := nationalLanguageUDH(report.gsmTables())
+		if err := submit.Message.SetMessageDataWithEncoding(gsmUserData(septets, udh, opts.Packing), enc); err != nil {
+			return nil, report, err
+		}
+		if len(udh) > 0 {
+			submit.EsmClass |= 0x40 // UDHI
+		}
+	} else if err := submit.Message.SetMessageWithEncoding(message, enc); err != nil {
+		return nil, report, err
+	}
+
+	return submit, report, nil
+}
+
+// newSubmitBase returns a SubmitSM with addresses and default flags set.
+// TON/NPI are inferred from the address shape; numbers are normalized to
+// E.164 when defaultCountry is set.
+func newSubmitBase(src, dest, defaultCountry string) (*pdu.SubmitSM, error) {
+	srcSME, err := resolveAddress(src, defaultCountry)
+	if err != nil {
+		return nil, fmt.Errorf("source_addr: %w", err)
+	}
+	destSME, err := resolveAddress(dest, defaultCountry)
+	if err != nil {
+		return nil, fmt.Errorf("destination_addr: %w", err)
+	}
+	srcAddr, err := srcSME.pduAddress()
+	if err != nil {
+		return nil, fmt.Errorf("source_addr: %w", err)
+	}
+	destAddr, err := destSME.pduAddress()
+	if err != nil {
+		return nil, fmt.Errorf("destination_addr: %w", err)
+	}
 
 	submit := pdu.NewSubmitSM().(*pdu.SubmitSM)
 	submit.SourceAddr = srcAddr
 	submit.DestAddr = destAddr
-	_ = submit.Message.SetMessageWithEncoding(message, data.UCS2)
 	submit.ProtocolID = 0
 	submit.RegisteredDelivery = 1
 	submit.ReplaceIfPresentFlag = 0
 	submit.EsmClass = 0
 
-	return submit
+	return submit, nil
+}
+
+// NewSubmitSMParts builds the SubmitSM PDUs for message, splitting it into
+// concatenated segments (UDH IEI 0x00) when it does not fit a single one.
+// Segment boundaries never fall inside a surrogate pair or GSM escape
+// sequence, nor inside a grapheme cluster when opts.Graphemes is set.
+func NewSubmitSMParts(src, dest, message string, opts SubmitOptions) ([]*pdu.SubmitSM, EncodingReport, error) {
+	message, report, enc, err := prepareMessage(message, opts)
+	if err != nil {
+		return nil, report, err
+	}
+
+	schedule, validity, err := opts.deliveryTimes()
+	if err != nil {
+		return nil, report, err
+	}
+	segments, err := splitMessage(message, report, opts.Graphemes)
+	if err != nil {
+		return nil, report, err
+	}
+	report.Segments = len(segments)
+
+	ref := byte(concatRef.Add(1))
+	parts := make([]*pdu.SubmitSM, 0, len(segments))
+	for i, seg := range segments {
+		submit, err := newSubmitBase(src, dest, opts.DefaultCountry)
+		if err != nil {
+			return nil, report, err
+		}
+		submit.ScheduleDeliveryTime, submit.ValidityPeriod = schedule, validity
+		udh := nationalLanguageUDH(report.gsmTables())
+		if len(segments) > 1 {
+			udh = append(pdu.UDH{{ID: concatIEI, Data: []byte{ref, byte(len(segments)), byte(i + 1)}}}, udh...)
+		}
+		userData := seg.Data
+		if report.Encoding == EncodingGSM7 {
+			// The UDH goes into the user data so packed septets are aligned after it.
+			userData = gsmUserData(seg.Data, udh, opts.Packing)
+		}
+		if err := submit.Message.SetMessageDataWithEncoding(userData, enc); err != nil {
+			return nil, report, err
+		}
+		if len(udh) > 0 {
+			if report.Encoding != EncodingGSM7 {
+				submit.Message.SetUDH(udh)
+			}
+			submit.EsmClass |= 0x40 // UDHI
+		}
+		parts = append(parts, submit)
+	}
+	return parts, report, nil
 }
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestChooseEncoding(t *testing.T) {
+	cases := []struct {
+		name       string
+		text       string
+		forced     MessageEncoding
+		languages  []GSMLanguage
+		want       MessageEncoding
+		dataCoding byte
+		length     int
+		segments   int
+	}{
+		{"ascii", "Hello", EncodingAuto, nil, EncodingGSM7, 0x00, 5, 1},
+		{"gsm accents", "é à ñ Ü", EncodingAuto, nil, EncodingGSM7, 0x00, 7, 1},
+		{"gsm extension", "5€ [ok]", EncodingAuto, nil, EncodingGSM7, 0x00, 10, 1},
+		{"latin-1", "crème brûlée", EncodingAuto, nil, EncodingLatin1, 0x03, 12, 1},
+		{"ucs2", "ж中", EncodingAuto, nil, EncodingUCS2, 0x08, 4, 1},
+		{"surrogate pair", "👍", EncodingAuto, nil, EncodingUCS2, 0x08, 4, 1},
+		{"forced ucs2", "abc", EncodingUCS2, nil, EncodingUCS2, 0x08, 6, 1},
+		{"forced latin-1", "abc", EncodingLatin1, nil, EncodingLatin1, 0x03, 3, 1},
+		{"gsm single", strings.Repeat("a", 160), EncodingAuto, nil, EncodingGSM7, 0x00, 160, 1},
+		{"gsm two", strings.Repeat("a", 161), EncodingAuto, nil, EncodingGSM7, 0x00, 161, 2},
+		{"gsm three", strings.Repeat("a", 307), EncodingAuto, nil, EncodingGSM7, 0x00, 307, 3},
+		{"latin-1 single", strings.Repeat("û", 140), EncodingAuto, nil, EncodingLatin1, 0x03, 140, 1},
+		{"latin-1 two", strings.Repeat("û", 141), EncodingAuto, nil, EncodingLatin1, 0x03, 141, 2},
+		{"ucs2 single", strings.Repeat("ж", 70), EncodingAuto, nil, EncodingUCS2, 0x08, 140, 1},
+		{"ucs2 two", strings.Repeat("ж", 71), EncodingAuto, nil, EncodingUCS2, 0x08, 142, 2},
+		{"turkish not allowed", "şğı", EncodingAuto, nil, EncodingUCS2, 0x08, 6, 1},
+		{"turkish allowed", "şğı", EncodingAuto, []GSMLanguage{GSMTurkish}, EncodingGSM7, 0x00, 3, 1},
+	}
+	for _, tc := range cases {
+		report, err := chooseEncoding(tc.text, tc.forced, tc.languages)
+		if err != nil {
+			t.Errorf("%s: %v", tc.name, err)
+			continue
+		}
+		if tc.languages != nil && report.LockingShift != GSMTurkish {
+			t.Errorf("%s: chose the %s locking shift table, want Turkish", tc.name, report.LockingShift)
+		}
+		if report.Encoding != tc.want || report.DataCoding != tc.dataCoding || report.Length != tc.length || report.Segments != tc.segments {
+			t.Errorf("%s: chose %s (data_coding 0x%02X), length %d, %d segments; want %s (0x%02X), %d, %d", tc.name,
+				report.Encoding, report.DataCoding, report.Length, report.Segments, tc.want, tc.dataCoding, tc.length, tc.segments)
+		}
+	}
+
+	for _, tc := range []struct {
+		text   string
+		forced MessageEncoding
+	}{
+		{"中", EncodingGSM7},
+		{"brûlée", EncodingGSM7},
+		{"中", EncodingLatin1},
+	} {
+		if report, err := chooseEncoding(tc.text, tc.forced, nil); err == nil {
+			t.Errorf("%q forced to %s was accepted: %+v", tc.text, tc.forced, report)
+		}
+	}
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"log"
+	"sync"
+	"time"
+)
+
+// congestionStaleAfter is how long a congestion_state reading is trusted
+// before the SMSC is assumed to have recovered.
+const congestionStaleAfter = 10 * time.Second
+
+// congestionThrottle slows submissions down according to the most recent
+// SMPP 5.0 congestion_state TLV (0-100) received from the SMSC.
+type congestionThrottle struct {
+	mu      sync.Mutex
+	level   int
+	updated time.Time
+}
+
+// observe records a congestion_state value from a response PDU.
+func (t *congestionThrottle) observe(level int) {
+	if level > 100 {
+		level = 100
+	}
+	t.mu.Lock()
+	prev := t.level
+	t.level = level
+	t.updated = time.Now()
+	t.mu.Unlock()
+
+	if congestionBand(prev) != congestionBand(level) {
+		log.Printf("SMSC congestion_state %d (%s)", level, congestionBand(level))
+	}
+}
+
+// delay returns how long to wait before the next submission. Levels below
+// 90, optimum load (80-89) included, are not throttled; nearing congestion
+// the delay grows with the level, up to one second when congested.
+func (t *congestionThrottle) delay() time.Duration {
+	t.mu.Lock()
+	level, updated := t.level, t.updated
+	t.mu.Unlock()
+
+	if time.Since(updated) > congestionStaleAfter {
+		return 0
+	}
+	switch {
+	case level < 90:
+		return 0
+	case level < 100:
+		return time.Duration(level-89) * 50 * time.Millisecond
+	}
+	return time.Second
+}
+
+// congestionBand names the congestion_state ranges from SMPP v5.0 section 4.8.4.8.
+func congestionBand(level int) string {
+	switch {
+	case level == 0:
+		return "idle"
+	case level < 30:
+		return "low load"
+	case level < 50:
+		return "medium load"
+	case level < 80:
+		return "high load"
+	case level < 90:
+		return "optimum load"
+	case level < 100:
+		return "nearing congestion"
+	}
+	return "congested"
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCongestionThrottle(t *testing.T) {
+	cases := []struct {
+		level int
+		band  string
+		delay time.Duration
+	}{
+		{0, "idle", 0},
+		{29, "low load", 0},
+		{30, "medium load", 0},
+		{79, "high load", 0},
+		{80, "optimum load", 0},
+		{89, "optimum load", 0},
+		{90, "nearing congestion", 50 * time.Millisecond},
+		{99, "nearing congestion", 500 * time.Millisecond},
+		{100, "congested", time.Second},
+		{150, "congested", time.Second},
+	}
+	for _, tc := range cases {
+		var th congestionThrottle
+		th.observe(tc.level)
+		if band := congestionBand(tc.level); band != tc.band {
+			t.Errorf("congestionBand(%d) = %q, want %q", tc.level, band, tc.band)
+		}
+		if d := th.delay(); d != tc.delay {
+			t.Errorf("delay at congestion_state %d = %s, want %s", tc.level, d, tc.delay)
+		}
+	}
+
+	// An old reading is no longer trusted.
+	var th congestionThrottle
+	th.observe(100)
+	th.updated = time.Now().Add(-congestionStaleAfter - time.Second)
+	if d := th.delay(); d != 0 {
+		t.Errorf("delay after a stale reading = %s, want 0", d)
+	}
+}
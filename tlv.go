@@ -0,0 +1,292 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/linxGnu/gosmpp/data"
+	"github.com/linxGnu/gosmpp/pdu"
+)
+
+// TLV value types accepted in test files.
+const (
+	tlvInt8    = "int8"
+	tlvInt16   = "int16"
+	tlvInt32   = "int32"
+	tlvCString = "cstring"
+	tlvOctets  = "octets" // string value sent as-is, no terminator
+	tlvText    = "text"   // string value encoded with the PDU's data_coding
+	tlvEmpty   = "empty"  // zero-length value, presence only; gosmpp cannot send it
+)
+
+type tlvDef struct {
+	Name string
+	Tag  uint16
+	Type string
+}
+
+// knownTLVs lists the SMPP 3.4/5.0 optional parameters by name.
+var knownTLVs = []tlvDef{
+	{"dest_addr_subunit", 0x0005, tlvInt8},
+	{"dest_network_type", 0x0006, tlvInt8},
+	{"dest_bearer_type", 0x0007, tlvInt8},
+	{"dest_telematics_id", 0x0008, tlvInt16},
+	{"source_addr_subunit", 0x000D, tlvInt8},
+	{"source_network_type", 0x000E, tlvInt8},
+	{"source_bearer_type", 0x000F, tlvInt8},
+	{"source_telematics_id", 0x0010, tlvInt8},
+	{"qos_time_to_live", 0x0017, tlvInt32},
+	{"payload_type", 0x0019, tlvInt8},
+	{"additional_status_info_text", 0x001D, tlvCString},
+	{"receipted_message_id", 0x001E, tlvCString},
+	{"ms_msg_wait_facilities", 0x0030, tlvInt8},
+	{"privacy_indicator", 0x0201, tlvInt8},
+	{"source_subaddress", 0x0202, tlvOctets},
+	{"dest_subaddress", 0x0203, tlvOctets},
+	{"user_message_reference", 0x0204, tlvInt16},
+	{"user_response_code", 0x0205, tlvInt8},
+	{"source_port", 0x020A, tlvInt16},
+	{"destination_port", 0x020B, tlvInt16},
+	{"sar_msg_ref_num", 0x020C, tlvInt16},
+	{"language_indicator", 0x020D, tlvInt8},
+	{"sar_total_segments", 0x020E, tlvInt8},
+	{"sar_segment_seqnum", 0x020F, tlvInt8},
+	{"sc_interface_version", 0x0210, tlvInt8},
+	{"callback_num_pres_ind", 0x0302, tlvInt8},
+	{"callback_num_atag", 0x0303, tlvOctets},
+	{"number_of_messages", 0x0304, tlvInt8},
+	{"callback_num", 0x0381, tlvOctets},
+	{"dpf_result", 0x0420, tlvInt8},
+	{"set_dpf", 0x0421, tlvInt8},
+	{"ms_availability_status", 0x0422, tlvInt8},
+	{"network_error_code", 0x0423, tlvOctets},
+	{"message_payload", 0x0424, tlvText},
+	{"delivery_failure_reason", 0x0425, tlvInt8},
+	{"more_messages_to_send", 0x0426, tlvInt8},
+	{"message_state", 0x0427, tlvInt8},
+	{"congestion_state", 0x0428, tlvInt8},
+	{"ussd_service_op", 0x0501, tlvInt8},
+	{"display_time", 0x1201, tlvInt8},
+	{"sms_signal", 0x1203, tlvInt16},
+	{"ms_validity", 0x1204, tlvInt8},
+	{"alert_on_message_delivery", 0x130C, tlvEmpty},
+	{"its_reply_type", 0x1380, tlvInt8},
+	{"its_session_info", 0x1383, tlvInt16},
+}
+
+func lookupTLVName(name string) (tlvDef, bool) {
+	for _, d := range knownTLVs {
+		if strings.EqualFold(d.Name, name) {
+			return d, true
+		}
+	}
+	return tlvDef{}, false
+}
+
+func lookupTLVTag(tag uint16) (tlvDef, bool) {
+	for _, d := range knownTLVs {
+		if d.Tag == tag {
+			return d, true
+		}
+	}
+	return tlvDef{}, false
+}
+
+// TLVTag is a tag number that may be written in JSON as a number or as a
+// string such as "0x1400".
+type TLVTag uint16
+
+func (t *TLVTag) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		s = string(b)
+	}
+	v, err := strconv.ParseUint(strings.TrimSpace(s), 0, 16)
+	if err != nil {
+		return fmt.Errorf("invalid TLV tag %s", b)
+	}
+	*t = TLVTag(v)
+	return nil
+}
+
+// TLVSpec is an optional parameter in a test file, identified by name or
+// tag. Value is typed by the known definition (or Type for vendor tags);
+// integers may also be strings such as "0x0B84". Hex gives the raw value
+// instead.
+type TLVSpec struct {
+	Name  string          `json:"name,omitempty"`
+	Tag   *TLVTag         `json:"tag,omitempty"`
+	Type  string          `json:"type,omitempty"`
+	Value json.RawMessage `json:"value,omitempty"`
+	Hex   string          `json:"hex,omitempty"`
+}
+
+func (s TLVSpec) label() string {
+	if s.Name != "" {
+		return s.Name
+	}
+	if s.Tag != nil {
+		return fmt.Sprintf("0x%04X", uint16(*s.Tag))
+	}
+	return "tlv"
+}
+
+// definition resolves the tag and value type of s.
+func (s TLVSpec) definition() (tlvDef, error) {
+	var def tlvDef
+	switch {
+	case s.Name != "":
+		d, ok := lookupTLVName(s.Name)
+		if !ok && s.Tag == nil {
+			return def, fmt.Errorf("unknown TLV name %q (give a tag for vendor-specific TLVs)", s.Name)
+		}
+		def = d
+		if s.Tag != nil {
+			if ok && d.Tag != uint16(*s.Tag) {
+				return def, fmt.Errorf("TLV %s has tag 0x%04X, not 0x%04X", s.Name, d.Tag, uint16(*s.Tag))
+			}
+			def.Name, def.Tag = s.Name, uint16(*s.Tag)
+		}
+	case s.Tag != nil:
+		def, _ = lookupTLVTag(uint16(*s.Tag))
+		def.Tag = uint16(*s.Tag)
+	default:
+		return def, fmt.Errorf("TLV needs a name or a tag")
+	}
+	if s.Type != "" {
+		def.Type = s.Type
+	}
+	return def, nil
+}
+
+// hasValue reports whether s specifies a value rather than presence only.
+func (s TLVSpec) hasValue() bool {
+	return s.Hex != "" || len(s.Value) > 0
+}
+
+// encode returns the tag and wire value of s. enc encodes "text" values.
+func (s TLVSpec) encode(enc data.Encoding) (uint16, []byte, error) {
+	def, err := s.definition()
+	if err != nil {
+		return 0, nil, err
+	}
+	if s.Hex != "" {
+		v, err := hex.DecodeString(strings.ReplaceAll(s.Hex, " ", ""))
+		if err != nil {
+			return 0, nil, fmt.Errorf("TLV %s: invalid hex: %w", s.label(), err)
+		}
+		return def.Tag, v, nil
+	}
+	if def.Type == tlvEmpty || len(s.Value) == 0 {
+		if def.Type != tlvEmpty {
+			return 0, nil, fmt.Errorf("TLV %s: missing value", s.label())
+		}
+		return def.Tag, nil, nil
+	}
+
+	switch def.Type {
+	case tlvInt8, tlvInt16, tlvInt32:
+		n := string(s.Value)
+		var str string
+		if json.Unmarshal(s.Value, &str) == nil {
+			n = str
+		}
+		bits := map[string]int{tlvInt8: 8, tlvInt16: 16, tlvInt32: 32}[def.Type]
+		v, err := strconv.ParseUint(strings.TrimSpace(n), 0, bits)
+		if err != nil {
+			return 0, nil, fmt.Errorf("TLV %s: want a number: %w", s.label(), err)
+		}
+		buf := make([]byte, 4)
+		binary.BigEndian.PutUint32(buf, uint32(v))
+		return def.Tag, buf[4-bits/8:], nil
+	case tlvCString, tlvOctets, tlvText, "":
+		var str string
+		if err := json.Unmarshal(s.Value, &str); err != nil {
+			return 0, nil, fmt.Errorf("TLV %s: want a string (or use hex): %w", s.label(), err)
+		}
+		switch def.Type {
+		case tlvCString:
+			return def.Tag, append([]byte(str), 0), nil
+		case tlvText:
+			if enc != nil {
+				v, err := enc.Encode(str)
+				if err != nil {
+					return 0, nil, fmt.Errorf("TLV %s: %w", s.label(), err)
+				}
+				return def.Tag, v, nil
+			}
+		}
+		return def.Tag, []byte(str), nil
+	}
+	return 0, nil, fmt.Errorf("TLV %s: unknown type %q", s.label(), def.Type)
+}
+
+// attachTLVs registers specs as optional parameters on p. gosmpp leaves
+// zero-length values off the wire, so they are refused rather than dropped.
+func attachTLVs(p pdu.PDU, specs []TLVSpec, enc data.Encoding) error {
+	for _, s := range specs {
+		tag, value, err := s.encode(enc)
+		if err != nil {
+			return err
+		}
+		if len(value) == 0 {
+			return fmt.Errorf("TLV %s: zero-length values cannot be sent", s.label())
+		}
+		p.RegisterOptionalParam(pdu.Field{Tag: pdu.Tag(tag), Data: value})
+	}
+	return nil
+}
+
+// checkTLVs compares expected TLVs with the optional parameters received.
+// A spec without a value only checks presence.
+func checkTLVs(expected []TLVSpec, got map[pdu.Tag]pdu.Field, enc data.Encoding) []string {
+	var mismatches []string
+	for _, s := range expected {
+		tag, want, err := s.encode(enc)
+		if err != nil && s.hasValue() {
+			mismatches = append(mismatches, err.Error())
+			continue
+		}
+		if !s.hasValue() {
+			def, err := s.definition()
+			if err != nil {
+				mismatches = append(mismatches, err.Error())
+				continue
+			}
+			tag = def.Tag
+		}
+		f, ok := got[pdu.Tag(tag)]
+		if !ok {
+			mismatches = append(mismatches, fmt.Sprintf("expected TLV %s (0x%04X) is missing", s.label(), tag))
+			continue
+		}
+		if s.hasValue() && !bytes.Equal(f.Data, want) {
+			mismatches = append(mismatches, fmt.Sprintf("TLV %s: expected %X, got %X", s.label(), want, f.Data))
+		}
+	}
+	return mismatches
+}
+
+// formatTLVs renders optional parameters for logging, by name where known.
+func formatTLVs(params map[pdu.Tag]pdu.Field) string {
+	tags := make([]int, 0, len(params))
+	for tag := range params {
+		tags = append(tags, int(tag))
+	}
+	sort.Ints(tags)
+	parts := make([]string, 0, len(tags))
+	for _, tag := range tags {
+		name := fmt.Sprintf("0x%04X", tag)
+		if d, ok := lookupTLVTag(uint16(tag)); ok {
+			name = d.Name
+		}
+		parts = append(parts, fmt.Sprintf("%s=%X", name, params[pdu.Tag(tag)].Data))
+	}
+	return strings.Join(parts, " ")
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/linxGnu/gosmpp/data"
+	"github.com/linxGnu/gosmpp/pdu"
+)
+
+func TestTLVSpecEncode(t *testing.T) {
+	cases := []struct {
+		spec string
+		tag  uint16
+		hex  string
+	}{
+		{`{"name": "user_message_reference", "value": 42}`, 0x0204, "002A"},
+		{`{"name": "SOURCE_PORT", "value": "0x0B84"}`, 0x020A, "0B84"},
+		{`{"name": "qos_time_to_live", "value": 3600}`, 0x0017, "00000E10"},
+		{`{"name": "receipted_message_id", "value": "abc"}`, 0x001E, "61626300"},
+		{`{"name": "callback_num", "hex": "01 02 03"}`, 0x0381, "010203"},
+		{`{"name": "alert_on_message_delivery"}`, 0x130C, ""},
+		{`{"name": "message_payload", "value": "Hi"}`, 0x0424, "00480069"},
+		{`{"tag": "0x1400", "type": "octets", "value": "ab"}`, 0x1400, "6162"},
+		{`{"tag": 5122, "hex": "FF"}`, 0x1402, "FF"},
+		{`{"tag": "0x0204", "value": 7}`, 0x0204, "0007"},
+		{`{"name": "vendor_flag", "tag": "0x1403", "type": "int8", "value": 1}`, 0x1403, "01"},
+	}
+	for _, tc := range cases {
+		var spec TLVSpec
+		if err := json.Unmarshal([]byte(tc.spec), &spec); err != nil {
+			t.Fatalf("%s: %v", tc.spec, err)
+		}
+		tag, value, err := spec.encode(data.UCS2)
+		if err != nil {
+			t.Errorf("%s: %v", tc.spec, err)
+			continue
+		}
+		if got := strings.ToUpper(hex.EncodeToString(value)); tag != tc.tag || got != tc.hex {
+			t.Errorf("%s encodes as 0x%04X %s, want 0x%04X %s", tc.spec, tag, got, tc.tag, tc.hex)
+		}
+	}
+
+	for _, spec := range []string{
+		`{"name": "no_such_tlv", "value": 1}`,
+		`{"name": "source_port", "tag": "0x020B", "value": 1}`,
+		`{"name": "user_response_code", "value": 256}`,
+		`{"name": "user_response_code", "value": "many"}`,
+		`{"name": "user_response_code", "value": true}`,
+		`{"name": "user_message_reference"}`,
+		`{"name": "callback_num", "hex": "0G"}`,
+		`{"name": "receipted_message_id", "value": 5}`,
+		`{"tag": "0x1400", "type": "float", "value": 1}`,
+		`{"value": 1}`,
+	} {
+		var s TLVSpec
+		if err := json.Unmarshal([]byte(spec), &s); err != nil {
+			continue
+		}
+		if tag, value, err := s.encode(nil); err == nil {
+			t.Errorf("%s encodes as 0x%04X %X", spec, tag, value)
+		}
+	}
+	var s TLVSpec
+	if err := json.Unmarshal([]byte(`{"tag": "0x10000"}`), &s); err == nil {
+		t.Error("a tag above 0xFFFF was accepted")
+	}
+}
+
+func TestTLVsOnTheWire(t *testing.T) {
+	var in InputPDU
+	input := `{"data_coding": 8, "tlvs": [
+		{"name": "source_port", "value": 2948},
+		{"name": "message_payload", "value": "Hi"},
+		{"tag": "0x1400", "hex": "CAFE"}
+	]}`
+	if err := json.Unmarshal([]byte(input), &in); err != nil {
+		t.Fatal(err)
+	}
+	sm := pdu.NewSubmitSM().(*pdu.SubmitSM)
+	if err := attachTLVs(sm, in.TLVs, data.UCS2); err != nil {
+		t.Fatal(err)
+	}
+	alert := []TLVSpec{{Name: "alert_on_message_delivery"}}
+	if err := attachTLVs(pdu.NewSubmitSM(), alert, nil); err == nil {
+		t.Error("a zero-length TLV was attached, but gosmpp does not send it")
+	}
+	buf := pdu.NewBuffer(nil)
+	sm.Marshal(buf)
+	parsed, err := pdu.Parse(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := parsed.(*pdu.SubmitSM).OptionalParameters
+
+	// The inputs match themselves, and presence-only specs match any value.
+	if m := checkTLVs(in.TLVs, got, data.UCS2); m != nil {
+		t.Errorf("received TLVs do not match the input: %v", m)
+	}
+	var expected ExpectedOutput
+	if err := json.Unmarshal([]byte(`{"tlvs": [
+		{"name": "source_port"},
+		{"name": "source_port", "value": 2949},
+		{"name": "message_payload", "value": "Hi"},
+		{"name": "destination_port"},
+		{"name": "no_such_tlv", "value": 1}
+	]}`), &expected); err != nil {
+		t.Fatal(err)
+	}
+	mismatches := checkTLVs(expected.TLVs, got, data.UCS2)
+	want := []string{
+		"TLV source_port: expected 0B85, got 0B84",
+		"expected TLV destination_port (0x020B) is missing",
+		`unknown TLV name "no_such_tlv" (give a tag for vendor-specific TLVs)`,
+	}
+	if strings.Join(mismatches, "\n") != strings.Join(want, "\n") {
+		t.Errorf("mismatches:\n%s\nwant:\n%s", strings.Join(mismatches, "\n"), strings.Join(want, "\n"))
+	}
+	// message_payload is text in the PDU's encoding: GSM 7-bit differs.
+	if m := checkTLVs(expected.TLVs[2:3], got, data.GSM7BIT); len(m) != 1 {
+		t.Errorf("message_payload in GSM 7-bit matched the UCS2 value: %v", m)
+	}
+
+	if s := formatTLVs(got); !strings.Contains(s, "source_port") || !strings.Contains(s, "0x1400") {
+		t.Errorf("formatTLVs = %s", s)
+	}
+}
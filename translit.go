@@ -0,0 +1,144 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"unicode/utf8"
+)
+
+// TranslitTable maps characters outside the GSM 7-bit alphabet to GSM
+// replacements. A replacement may be empty to drop the character.
+type TranslitTable map[rune]string
+
+// Substitution records one character replaced during transliteration.
+type Substitution struct {
+	// Offset is the rune index of the character in the original text.
+	Offset int    `json:"offset"`
+	From   string `json:"from"`
+	To     string `json:"to"`
+}
+
+func (s Substitution) String() string {
+	return fmt.Sprintf("%q -> %q at %d", s.From, s.To, s.Offset)
+}
+
+// defaultTranslitTable covers typographic punctuation, spaces and Latin
+// letters that have no GSM 7-bit code point.
+var defaultTranslitTable = TranslitTable{
+	// quotes and apostrophes
+	'‘': "'", '’': "'", '‚': "'", '‛': "'", '′': "'", '´': "'", '`': "'",
+	'“': "\"", '”': "\"", '„': "\"", '‟': "\"", '″': "\"", '«': "\"", '»': "\"",
+	'‹': "<", '›': ">",
+	// dashes and hyphens
+	'‐': "-", '‑': "-", '‒': "-", '–': "-", '—': "-", '―': "-", '−': "-",
+	// spaces
+	'\u00A0': " ", '\u2002': " ", '\u2003': " ", '\u2009': " ", '\u200A': " ",
+	'\u202F': " ", '\u3000': " ", '\u200B': "", '\uFEFF': "",
+	// punctuation and symbols
+	'…': "...", '•': "*", '·': ".", '×': "x", '÷': "/", '™': "TM", '©': "(c)", '®': "(R)",
+	'¢': "c", '¦': "|", '¨': "\"", '¯': "-", '°': "o", '±': "+/-", '²': "2", '³': "3",
+	'¹': "1", '¼': "1/4", '½': "1/2", '¾': "3/4", 'ª': "a", 'º': "o", '¬': "-", '¸': ",",
+	// upper case letters
+	'À': "A", 'Á': "A", 'Â': "A", 'Ã': "A", 'Ā': "A", 'Ă': "A", 'Ą': "A",
+	'Ć': "C", 'Č': "C", 'Ĉ': "C", 'Ċ': "C",
+	'Ď': "D", 'Đ': "D", 'Ð': "D",
+	'È': "E", 'Ê': "E", 'Ë': "E", 'Ē': "E", 'Ė': "E", 'Ę': "E", 'Ě': "E",
+	'Ğ': "G", 'Ġ': "G", 'Ģ': "G",
+	'Ì': "I", 'Í': "I", 'Î': "I", 'Ï': "I", 'Ī': "I", 'Į': "I", 'İ': "I",
+	'Ķ': "K", 'Ĺ': "L", 'Ļ': "L", 'Ľ': "L", 'Ł': "L",
+	'Ń': "N", 'Ņ': "N", 'Ň': "N",
+	'Ò': "O", 'Ó': "O", 'Ô': "O", 'Õ': "O", 'Ō': "O", 'Ő': "O", 'Œ': "OE",
+	'Ŕ': "R", 'Ř': "R",
+	'Ś': "S", 'Ş': "S", 'Š': "S", 'Ș': "S",
+	'Ţ': "T", 'Ť': "T", 'Ț': "T", 'Þ': "TH",
+	'Ù': "U", 'Ú': "U", 'Û': "U", 'Ū': "U", 'Ů': "U", 'Ű': "U", 'Ų': "U",
+	'Ý': "Y", 'Ÿ': "Y",
+	'Ź': "Z", 'Ż': "Z", 'Ž': "Z",
+	// lower case letters
+	'á': "a", 'â': "a", 'ã': "a", 'ā': "a", 'ă': "a", 'ą': "a",
+	'ç': "c", 'ć': "c", 'č': "c", 'ĉ': "c", 'ċ': "c",
+	'ď': "d", 'đ': "d", 'ð': "d",
+	'ê': "e", 'ë': "e", 'ē': "e", 'ė': "e", 'ę': "e", 'ě': "e",
+	'ğ': "g", 'ġ': "g", 'ģ': "g",
+	'í': "i", 'î': "i", 'ï': "i", 'ī': "i", 'į': "i", 'ı': "i",
+	'ķ': "k", 'ĺ': "l", 'ļ': "l", 'ľ': "l", 'ł': "l",
+	'ń': "n", 'ņ': "n", 'ň': "n",
+	'ó': "o", 'ô': "o", 'õ': "o", 'ō': "o", 'ő': "o", 'œ': "oe",
+	'ŕ': "r", 'ř': "r",
+	'ś': "s", 'ş': "s", 'š': "s", 'ș': "s",
+	'ţ': "t", 'ť': "t", 'ț': "t", 'þ': "th",
+	'ú': "u", 'û': "u", 'ū': "u", 'ů': "u", 'ű': "u", 'ų': "u",
+	'ý': "y", 'ÿ': "y",
+	'ź': "z", 'ż': "z", 'ž': "z",
+}
+
+// LoadTranslitTable reads a JSON object of character replacements, e.g.
+// {"’": "'", "€": "EUR"}, and merges it over the default table. Keys must be
+// single characters.
+func LoadTranslitTable(path string) (TranslitTable, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var raw map[string]string
+	if err := json.Unmarshal(b, &raw); err != nil {
+		return nil, fmt.Errorf("transliteration table %s: %w", path, err)
+	}
+	table := make(TranslitTable, len(defaultTranslitTable)+len(raw))
+	for r, to := range defaultTranslitTable {
+		table[r] = to
+	}
+	for from, to := range raw {
+		r, size := utf8.DecodeRuneInString(from)
+		if r == utf8.RuneError || size != len(from) {
+			return nil, fmt.Errorf("transliteration table %s: key %q is not a single character", path, from)
+		}
+		table[r] = to
+	}
+	return table, nil
+}
+
+// transliterate replaces characters that cannot be encoded with the default
+// GSM tables (or any table of the given languages) using table. Characters
+// without a mapping are left untouched so the caller can fall back to UCS2.
+func transliterate(s string, table TranslitTable, languages []GSMLanguage) (string, []Substitution) {
+	if table == nil {
+		table = defaultTranslitTable
+	}
+	var b strings.Builder
+	var subs []Substitution
+	i := 0
+	for _, r := range s {
+		to, ok := table[r]
+		if ok && !gsmRepresentable(r, languages) {
+			b.WriteString(to)
+			subs = append(subs, Substitution{Offset: i, From: string(r), To: to})
+		} else {
+			b.WriteRune(r)
+		}
+		i++
+	}
+	return b.String(), subs
+}
+
+// gsmRepresentable reports whether r is in the default tables or in a
+// locking or single shift table of one of languages.
+func gsmRepresentable(r rune, languages []GSMLanguage) bool {
+	for _, lang := range append([]GSMLanguage{GSMDefault}, languages...) {
+		for _, t := range []gsmTables{{Locking: lang}, {Single: lang}} {
+			cs, ok := gsmCharsets[t]
+			if !ok {
+				continue
+			}
+			if _, ok := cs.encode[r]; ok {
+				return true
+			}
+			if _, ok := cs.encExt[r]; ok {
+				return true
+			}
+		}
+	}
+	return false
+}
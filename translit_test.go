@@ -0,0 +1,88 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestTransliterate(t *testing.T) {
+	out, subs := transliterate("“Hi” — it’s 5\u00A0km…\u200B", nil, nil)
+	if want := "\"Hi\" - it's 5 km..."; out != want {
+		t.Errorf("transliterate = %q, want %q", out, want)
+	}
+	want := []Substitution{
+		{Offset: 0, From: "“", To: "\""},
+		{Offset: 3, From: "”", To: "\""},
+		{Offset: 5, From: "—", To: "-"},
+		{Offset: 9, From: "’", To: "'"},
+		{Offset: 13, From: "\u00A0", To: " "},
+		{Offset: 16, From: "…", To: "..."},
+		{Offset: 17, From: "\u200B", To: ""},
+	}
+	if !reflect.DeepEqual(subs, want) {
+		t.Errorf("substitutions = %v, want %v", subs, want)
+	}
+
+	// Characters a permitted national table can encode are kept.
+	if out, subs := transliterate("Kaş", nil, []GSMLanguage{GSMTurkish}); out != "Kaş" || subs != nil {
+		t.Errorf("with Turkish tables: %q, %v", out, subs)
+	}
+	if out, _ := transliterate("Kaş", nil, nil); out != "Kas" {
+		t.Errorf("without Turkish tables: %q", out)
+	}
+}
+
+func TestTransliterateForGSM(t *testing.T) {
+	// A character without a mapping leaves the message untouched for UCS2.
+	if out, subs := transliterateForGSM("“Hi” 中", nil, nil); out != "“Hi” 中" || subs != nil {
+		t.Errorf("partly mappable text = %q, %v", out, subs)
+	}
+	if out, subs := transliterateForGSM("plain", nil, nil); out != "plain" || subs != nil {
+		t.Errorf("GSM text = %q, %v", out, subs)
+	}
+
+	_, report, err := NewSubmitSM("ACME", "447700900001", "Café “Ōsaka”", SubmitOptions{Transliterate: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Substitution{{Offset: 5, From: "“", To: "\""}, {Offset: 6, From: "Ō", To: "O"}, {Offset: 11, From: "”", To: "\""}}
+	if report.Encoding != EncodingGSM7 || !reflect.DeepEqual(report.Substitutions, want) {
+		t.Errorf("NewSubmitSM chose %s, substitutions %v; want GSM 7-bit, %v", report.Encoding, report.Substitutions, want)
+	}
+	// Transliteration is for GSM 7-bit only.
+	_, report, err = NewSubmitSM("ACME", "447700900001", "“Hi”", SubmitOptions{Transliterate: true, Encoding: EncodingUCS2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Encoding != EncodingUCS2 || report.Substitutions != nil {
+		t.Errorf("forced UCS2 was transliterated: %v", report.Substitutions)
+	}
+}
+
+func TestLoadTranslitTable(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "table.json")
+	if err := os.WriteFile(path, []byte(`{"€": "EUR", "’": "`+"`"+`"}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	table, err := LoadTranslitTable(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if table['€'] != "EUR" || table['’'] != "`" || table['—'] != "-" {
+		t.Errorf("loaded table maps € to %q, ’ to %q and — to %q", table['€'], table['’'], table['—'])
+	}
+	// € is in the GSM extension table, so the mapping is not applied.
+	if out, _ := transliterate("5€ it’s", table, nil); out != "5€ it`s" {
+		t.Errorf("transliterate with the loaded table = %q", out)
+	}
+
+	if err := os.WriteFile(path, []byte(`{"ab": "x"}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadTranslitTable(path); err == nil {
+		t.Error("a key of two characters was accepted")
+	}
+}
@@ -0,0 +1,273 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/linxGnu/gosmpp/data"
+	"github.com/linxGnu/gosmpp/pdu"
+)
+
+// submit_sm field limits from SMPP 3.4 section 4.4.1, excluding C-string
+// terminators.
+const (
+	maxServiceTypeLen  = 5
+	maxShortMessageLen = 254
+)
+
+// esm_class bits (SMPP 3.4 section 5.2.12).
+const (
+	esmModeMask     = 0x03
+	esmModeDatagram = 0x01
+	esmModeForward  = 0x02
+	esmTypeMask     = 0x3C
+	esmTypeDelivAck = 0x08
+	esmTypeUserAck  = 0x10
+	esmUDHI         = 0x40
+)
+
+// ValidationOptions holds the profile settings validation depends on.
+type ValidationOptions struct {
+	// Packing is the GSM 7-bit packing the SMSC expects; it decides the
+	// octet length of GSM 7-bit messages.
+	Packing GSMPacking
+}
+
+// Violation is a submit_sm field an SMSC would reject, with the
+// command_status it is expected to answer with.
+type Violation struct {
+	Field   string        `json:"field"`
+	Message string        `json:"message"`
+	Status  CommandStatus `json:"command_status"`
+}
+
+func (v Violation) String() string {
+	return fmt.Sprintf("Invalid %s: %s (%s)", v.Field, v.Message, v.Status)
+}
+
+// validateSubmitSM checks every field of in against SMPP 3.4. Fields the
+// test case omits take the defaults newSubmitSM would send.
+func validateSubmitSM(in InputPDU, opts ValidationOptions) []Violation {
+	var vs []Violation
+	add := func(field string, status CommandStatus, format string, args ...interface{}) {
+		vs = append(vs, Violation{Field: field, Message: fmt.Sprintf(format, args...), Status: status})
+	}
+	octet := func(field string, p *int, status CommandStatus) (int, bool) {
+		if p == nil {
+			return 0, false
+		}
+		if *p < 0 || *p > 0xFF {
+			add(field, status, "%d does not fit in one octet", *p)
+			return *p, false
+		}
+		return *p, true
+	}
+
+	if in.CommandID != nil && !strings.EqualFold(*in.CommandID, "submit_sm") {
+		add("command_id", ESME_RINVCMDID, "%q is not submit_sm", *in.CommandID)
+	}
+	if in.ServiceType != nil && len(*in.ServiceType) > maxServiceTypeLen {
+		add("service_type", ESME_RINVSERTYP, "%q is longer than %d characters", *in.ServiceType, maxServiceTypeLen)
+	}
+
+	vs = append(vs, validateAddressFields("source_addr", in.SourceAddr, in.SourceAddrTON, in.SourceAddrNPI, false)...)
+	vs = append(vs, validateAddressFields("destination_addr", in.DestinationAddr, in.DestAddrTON, in.DestAddrNPI, true)...)
+
+	// esm_class: UDHI must match the presence of a UDH, and only the
+	// default, delivery acknowledgement and user acknowledgement types may
+	// be submitted.
+	udh, udhErr := testCaseUDH(in)
+	hasUDH := udhErr == nil && len(udh) > 0
+	esm, ok := octet("esm_class", in.EsmClass, ESME_RINVESMCLASS)
+	if ok || in.EsmClass == nil {
+		if hasUDH && in.EsmClass != nil && esm&esmUDHI == 0 {
+			add("esm_class", ESME_RINVESMCLASS, "0x%02X does not set UDHI (0x40) but the message has a UDH", esm)
+		}
+		if !hasUDH && esm&esmUDHI != 0 {
+			add("esm_class", ESME_RINVESMCLASS, "0x%02X sets UDHI (0x40) but the message has no UDH", esm)
+		}
+		switch esm & esmTypeMask {
+		case 0, esmTypeDelivAck, esmTypeUserAck:
+		default:
+			add("esm_class", ESME_RINVESMCLASS, "message type bits 0x%02X are not valid in submit_sm", esm&esmTypeMask)
+		}
+		if mode := esm & esmModeMask; (mode == esmModeDatagram || mode == esmModeForward) &&
+			in.ScheduleDeliveryTime != nil && *in.ScheduleDeliveryTime != "" {
+			add("esm_class", ESME_RINVESMCLASS, "datagram and forward modes cannot be scheduled")
+		}
+	}
+
+	// Any protocol_id octet is accepted; its meaning is network specific.
+	octet("protocol_id", in.ProtocolID, ESME_RSUBMITFAIL)
+	if p, ok := octet("priority_flag", in.PriorityFlag, ESME_RINVPRTFLG); ok && p > 3 {
+		add("priority_flag", ESME_RINVPRTFLG, "%d is not in the range 0-3", p)
+	}
+	if rd, ok := octet("registered_delivery", in.RegisteredDelivery, ESME_RINVREGDLVFLG); ok {
+		if rd&0x03 == 0x03 {
+			add("registered_delivery", ESME_RINVREGDLVFLG, "0x%02X uses the reserved SMSC delivery receipt value 3", rd)
+		}
+		if rd&0xE0 != 0 {
+			add("registered_delivery", ESME_RINVREGDLVFLG, "0x%02X sets reserved bits 5-7", rd)
+		}
+	}
+	if r, ok := octet("replace_if_present_flag", in.ReplaceIfPresentFlag, ESME_RINVREPFLAG); ok && r > 1 {
+		add("replace_if_present_flag", ESME_RINVREPFLAG, "%d is not 0 or 1", r)
+	}
+
+	dataCoding := 0
+	var enc data.Encoding // encodes text TLVs as newSubmitSM does
+	if dc, ok := octet("data_coding", in.DataCoding, ESME_RINVDCS); ok {
+		dataCoding = dc
+		dcs := DecodeDCS(byte(dc))
+		if dcs.Group == DCSGroupReserved || dcs.Alphabet == AlphabetReserved {
+			add("data_coding", ESME_RINVDCS, "0x%02X is a reserved value", dc)
+		}
+		enc, _ = byteToDataCoding(byte(dc))
+	}
+
+	if n, ok := shortMessageOctets(in, dataCoding, udh, opts.Packing); ok && n > maxShortMessageLen {
+		add("short_message", ESME_RINVMSGLEN, "%d octets exceed the maximum of %d (use message_payload or split the message)", n, maxShortMessageLen)
+	}
+	payload := false
+	for i, t := range in.TLVs {
+		if _, _, err := t.encode(enc); err != nil {
+			add(fmt.Sprintf("tlvs[%d]", i), ESME_RINVOPTPARAMVAL, "%v", err)
+			continue
+		}
+		if def, err := t.definition(); err == nil && def.Name == "message_payload" {
+			payload = true
+		}
+	}
+	if payload && hasShortMessage(in) {
+		add("message_payload", ESME_RINVMSGLEN, "short_message and message_payload are mutually exclusive (sm_length must be 0)")
+	}
+
+	vs = append(vs, validateDeliveryTimes(in)...)
+	return vs
+}
+
+// validateAddressFields checks an address and its TON/NPI.
+func validateAddressFields(field string, addr *string, ton, npi *int, required bool) []Violation {
+	addrStatus, tonStatus, npiStatus := ESME_RINVSRCADR, ESME_RINVSRCTON, ESME_RINVSRCNPI
+	if field == "destination_addr" {
+		addrStatus, tonStatus, npiStatus = ESME_RINVDSTADR, ESME_RINVDSTTON, ESME_RINVDSTNPI
+	}
+	if addr == nil || strings.TrimSpace(*addr) == "" {
+		if required {
+			return []Violation{{Field: field, Message: "missing required field", Status: addrStatus}}
+		}
+		return nil
+	}
+	var vs []Violation
+	if ton != nil && (*ton < 0 || *ton > int(TONAbbreviated)) {
+		vs = append(vs, Violation{Field: field + "_ton", Message: fmt.Sprintf("%d is not a defined type of number", *ton), Status: tonStatus})
+	}
+	if npi != nil && !validNPI(*npi) {
+		vs = append(vs, Violation{Field: field + "_npi", Message: fmt.Sprintf("%d is not a defined numbering plan", *npi), Status: npiStatus})
+	}
+	if len(vs) > 0 {
+		return vs
+	}
+	if err := ValidateAddress(testCaseAddress(*addr, ton, npi)); err != nil {
+		vs = append(vs, Violation{Field: field, Message: err.Error(), Status: addrStatus})
+	}
+	return vs
+}
+
+func validNPI(npi int) bool {
+	if npi < 0 || npi > 0xFF {
+		return false
+	}
+	switch byte(npi) {
+	case NPIUnknown, NPIISDN, NPIData, NPITelex, NPILand, NPINational, NPIPrivate, NPIERMES, NPIInternet, NPIWAP:
+		return true
+	}
+	return false
+}
+
+// validateDeliveryTimes checks schedule_delivery_time and validity_period.
+func validateDeliveryTimes(in InputPDU) []Violation {
+	var vs []Violation
+	now := time.Now()
+	var at, until time.Time
+	if in.ScheduleDeliveryTime != nil {
+		s, err := normalizeSMPPTime(*in.ScheduleDeliveryTime)
+		if err == nil {
+			at, err = ParseSMPPTime(s, now)
+		}
+		if err != nil {
+			vs = append(vs, Violation{Field: "schedule_delivery_time", Message: err.Error(), Status: ESME_RINVSCHED})
+		}
+	}
+	if in.ValidityPeriod != nil {
+		s, err := normalizeSMPPTime(*in.ValidityPeriod)
+		if err == nil {
+			until, err = ParseSMPPTime(s, now)
+		}
+		switch {
+		case err != nil:
+			vs = append(vs, Violation{Field: "validity_period", Message: err.Error(), Status: ESME_RINVEXPIRY})
+		case !until.IsZero() && until.Before(now):
+			vs = append(vs, Violation{Field: "validity_period", Message: fmt.Sprintf("%s is in the past", s), Status: ESME_RINVEXPIRY})
+		case !until.IsZero() && !at.IsZero() && until.Before(at):
+			vs = append(vs, Violation{Field: "validity_period", Message: "ends before schedule_delivery_time", Status: ESME_RINVEXPIRY})
+		}
+	}
+	return vs
+}
+
+// hasShortMessage reports whether in carries a non-empty short_message.
+func hasShortMessage(in InputPDU) bool {
+	for _, s := range []*string{in.ShortMessage, in.ShortMessageHex, in.ShortMessageBase64} {
+		if s != nil && *s != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// shortMessageOctets returns the size of the short_message field as
+// newSubmitSM encodes it, UDH included. ok is false when the text cannot be
+// encoded.
+func shortMessageOctets(in InputPDU, dataCoding int, udh pdu.UDH, packing GSMPacking) (int, bool) {
+	if payload, binary, err := testCasePayload(in); binary {
+		return len(payload) + udhLen(udh), err == nil
+	}
+	text := ""
+	if in.ShortMessage != nil {
+		text = *in.ShortMessage
+	}
+	n := len(text)
+	switch DecodeDCS(byte(dataCoding)).Alphabet {
+	case AlphabetGSM7:
+		if in.Transliterate != nil && *in.Transliterate {
+			text, _ = transliterateForGSM(text, nil, nil)
+		}
+		// National language IEs in the UDH select the shift tables.
+		septets, err := nationalTables(udh).encode(text)
+		if err != nil {
+			return 0, false
+		}
+		return len(gsmUserData(septets, udh, packing)), true
+	case AlphabetLatin1:
+		if !isLatin1(text) {
+			return 0, false
+		}
+		n = len([]rune(text))
+	case AlphabetUCS2:
+		n = ucs2ByteLength(text)
+	case AlphabetCyrillic, AlphabetHebrew:
+		// Single-octet code pages: measure what the encoder produces.
+		enc, err := byteToDataCoding(byte(dataCoding))
+		if err != nil {
+			return 0, false
+		}
+		b, err := enc.Encode(text)
+		if err != nil {
+			return 0, false
+		}
+		n = len(b)
+	}
+	return n + udhLen(udh), true
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// validInput is a submit_sm test input without violations.
+const validInput = `{
+	"source_addr": "ACME", "source_addr_ton": 5, "source_addr_npi": 0,
+	"destination_addr": "447700900001", "dest_addr_ton": 1, "dest_addr_npi": 1,
+	"data_coding": 0, "short_message": "Hello"
+}`
+
+// inputWith returns validInput with the fields of patch replaced; a null
+// removes the field.
+func inputWith(t *testing.T, patch string) InputPDU {
+	t.Helper()
+	var fields map[string]interface{}
+	if err := json.Unmarshal([]byte(validInput), &fields); err != nil {
+		t.Fatal(err)
+	}
+	var changes map[string]interface{}
+	if err := json.Unmarshal([]byte(patch), &changes); err != nil {
+		t.Fatalf("%s: %v", patch, err)
+	}
+	for k, v := range changes {
+		if v == nil {
+			delete(fields, k)
+		} else {
+			fields[k] = v
+		}
+	}
+	b, _ := json.Marshal(fields)
+	var in InputPDU
+	if err := json.Unmarshal(b, &in); err != nil {
+		t.Fatalf("%s: %v", patch, err)
+	}
+	return in
+}
+
+func TestValidateSubmitSM(t *testing.T) {
+	long := strings.Repeat("a", 255)
+	cases := []struct {
+		patch   string
+		packing GSMPacking
+		want    []string // field and command_status of each violation
+	}{
+		{`{}`, GSMUnpacked, nil},
+		{`{"source_addr": null, "source_addr_ton": null, "source_addr_npi": null}`, GSMUnpacked, nil},
+		{`{"command_id": "deliver_sm"}`, GSMUnpacked, []string{"command_id ESME_RINVCMDID"}},
+		{`{"service_type": "TOOLONG"}`, GSMUnpacked, []string{"service_type ESME_RINVSERTYP"}},
+		{`{"destination_addr": ""}`, GSMUnpacked, []string{"destination_addr ESME_RINVDSTADR"}},
+		{`{"dest_addr_ton": 9}`, GSMUnpacked, []string{"destination_addr_ton ESME_RINVDSTTON"}},
+		{`{"source_addr_npi": 7}`, GSMUnpacked, []string{"source_addr_npi ESME_RINVSRCNPI"}},
+		{`{"esm_class": 64}`, GSMUnpacked, []string{"esm_class ESME_RINVESMCLASS"}},
+		{`{"esm_class": 0, "udh_hex": "050003010201"}`, GSMUnpacked, []string{"esm_class ESME_RINVESMCLASS"}},
+		{`{"esm_class": 64, "udh_hex": "050003010201"}`, GSMUnpacked, nil},
+		{`{"esm_class": 4}`, GSMUnpacked, []string{"esm_class ESME_RINVESMCLASS"}},
+		{`{"esm_class": 8}`, GSMUnpacked, nil},
+		{`{"esm_class": 1, "schedule_delivery_time": "000001000000000R"}`, GSMUnpacked, []string{"esm_class ESME_RINVESMCLASS"}},
+		{`{"esm_class": 256}`, GSMUnpacked, []string{"esm_class ESME_RINVESMCLASS"}},
+		{`{"protocol_id": -1}`, GSMUnpacked, []string{"protocol_id ESME_RSUBMITFAIL"}},
+		{`{"priority_flag": 4}`, GSMUnpacked, []string{"priority_flag ESME_RINVPRTFLG"}},
+		{`{"registered_delivery": 3}`, GSMUnpacked, []string{"registered_delivery ESME_RINVREGDLVFLG"}},
+		{`{"registered_delivery": 33}`, GSMUnpacked, []string{"registered_delivery ESME_RINVREGDLVFLG"}},
+		{`{"replace_if_present_flag": 2}`, GSMUnpacked, []string{"replace_if_present_flag ESME_RINVREPFLAG"}},
+		{`{"data_coding": 154}`, GSMUnpacked, []string{"data_coding ESME_RINVDCS"}},
+		{`{"data_coding": 8, "short_message": "` + long[:127] + `"}`, GSMUnpacked, nil},
+		{`{"data_coding": 8, "short_message": "` + long[:128] + `"}`, GSMUnpacked, []string{"short_message ESME_RINVMSGLEN"}},
+		{`{"short_message": "` + long + `"}`, GSMUnpacked, []string{"short_message ESME_RINVMSGLEN"}},
+		{`{"short_message": "` + long + `"}`, GSMPacked, nil},
+		{`{"tlvs": [{"name": "message_payload", "value": "x"}]}`, GSMUnpacked, []string{"message_payload ESME_RINVMSGLEN"}},
+		{`{"short_message": null, "tlvs": [{"name": "message_payload", "value": "x"}]}`, GSMUnpacked, nil},
+		{`{"tlvs": [{"name": "source_port", "value": 1}, {"name": "no_such_tlv", "value": 1}]}`, GSMUnpacked, []string{"tlvs[1] ESME_RINVOPTPARAMVAL"}},
+		{`{"schedule_delivery_time": "tomorrow"}`, GSMUnpacked, []string{"schedule_delivery_time ESME_RINVSCHED"}},
+		{`{"validity_period": "200101000000000+"}`, GSMUnpacked, []string{"validity_period ESME_RINVEXPIRY"}},
+		{`{"schedule_delivery_time": "000002000000000R", "validity_period": "000001000000000R"}`, GSMUnpacked, []string{"validity_period ESME_RINVEXPIRY"}},
+		{`{"priority_flag": 9, "replace_if_present_flag": 5}`, GSMUnpacked, []string{"priority_flag ESME_RINVPRTFLG", "replace_if_present_flag ESME_RINVREPFLAG"}},
+	}
+	for _, tc := range cases {
+		var got []string
+		for _, v := range validateSubmitSM(inputWith(t, tc.patch), ValidationOptions{Packing: tc.packing}) {
+			got = append(got, fmt.Sprintf("%s %s", v.Field, v.Status))
+		}
+		if strings.Join(got, ", ") != strings.Join(tc.want, ", ") {
+			t.Errorf("%.80s (%s): violations %v, want %v", tc.patch, tc.packing, got, tc.want)
+		}
+	}
+}